@@ -3,14 +3,23 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	_ "subscribe_aggregation-main/docs"
 	"subscribe_aggregation-main/internal/api"
+	"subscribe_aggregation-main/internal/auth"
 	"subscribe_aggregation-main/internal/config"
+	"subscribe_aggregation-main/internal/graphqlapi"
+	"subscribe_aggregation-main/internal/grpcserver"
+	"subscribe_aggregation-main/internal/metrics"
+	"subscribe_aggregation-main/internal/migrations"
+	"subscribe_aggregation-main/internal/notify"
+	"subscribe_aggregation-main/internal/ratelimit"
 	"subscribe_aggregation-main/internal/storage"
 	"subscribe_aggregation-main/pkg/logging"
 
@@ -18,59 +27,186 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
+// @title       Subscription Aggregation API
+// @version     1.0
+// @BasePath    /
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 JWT токен в формате "Bearer <token>"
+// @securityDefinitions.apikey  APIKeyAuth
+// @in                          header
+// @name                        X-API-Key
+// @description                 API ключ сервиса
 func main() {
 	// Создаем корневой контекст с функцией отмены
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Конфигурация: YAML файл (-config или CONFIG_FILE), переменные окружения, затем флаги
+	config.Init(os.Args[1:])
+	cfg := config.ConfigInstance
+	// Без ключей проверки токенов сервер не запустится, поэтому сообщаем об этом до подключения к базе
+	if err := cfg.ValidateAuth(); err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+	logFile, err := logging.Setup(logging.Options{
+		Output:     cfg.LogOutput,
+		Format:     cfg.LogFormat,
+		Level:      cfg.LogLevel,
+		File:       cfg.LogFile,
+		MaxSizeMB:  cfg.LogMaxSizeMB,
+		MaxAge:     cfg.LogMaxAge,
+		MaxBackups: cfg.LogMaxBackups,
+
+		RedactFields:      cfg.LogRedactFields,
+		RedactQueryParams: cfg.LogRedactQueryParams,
+	})
+	if err != nil {
+		log.Fatalf("failed to configure logging: %v", err)
+	}
+	defer logFile.Close()
+
 	config.InitDB()
 
-	// Передаем только DB в конструктор, ctx передаем методам явно
+	if cfg.MigrateOnStartup {
+		applied, err := migrations.Up(ctx, config.DB.DB)
+		if err != nil {
+			log.Fatalf("Failed to apply migrations: %v", err)
+		}
+		log.Printf("Applied %d migrations\n", applied)
+	}
+
+	// Передаем только DB в конструктор, ctx приходит в методы через контекст запроса
 	store := storage.NewStorage(config.DB)
-	handler := api.NewHandler(store)
+	if cfg.DBRowLevelSecurity {
+		store.EnableRowLevelSecurity()
+	}
+
+	// API работают с хранилищем через интерфейс, поэтому при включенных метриках
+	// получают обертку, замеряющую каждый вызов
+	var apiStore storage.StorageInterface = store
+	var m *metrics.Metrics
+	if cfg.MetricsEnabled {
+		m = metrics.New()
+		m.RegisterDB(config.DB.DB, "postgres")
+		apiStore = m.InstrumentStorage(store)
+		go updateSubscriptionStats(ctx, store, m, cfg.MetricsStatsInterval)
+	}
+	// События об изменении подписок отправляются после успешной записи во всех API
+	notifier := notify.New(notifyConfig(cfg))
+	apiStore = notifier.Storage(apiStore)
+
+	handler := api.NewHandler(apiStore)
+	handler.RequireIfMatch = cfg.RequireIfMatch
+
+	limiters := newRateLimiters(ctx, cfg)
+	verifier := newVerifier(cfg)
+	go deleteExpiredIdempotencyKeys(ctx, store)
+	cors := api.NewCORS(cfg.CORSAllowedOrigins)
+	go watchConfig(ctx, cfg, limiters, cors, notifier)
 
 	r := chi.NewRouter()
 
 	// Добавляем middleware логирования и передачи контекста запроса
 	r.Use(logging.Middleware)
 
-	r.Route("/subscriptions", func(r chi.Router) {
-		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-			handler.ListSubscriptions(w, r.WithContext(ctx))
-		})
-		r.Post("/", func(w http.ResponseWriter, r *http.Request) {
-			handler.CreateSubscription(w, r.WithContext(ctx))
-		})
-		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
-			handler.GetSubscription(w, r.WithContext(ctx))
-		})
-		r.Put("/{id}", func(w http.ResponseWriter, r *http.Request) {
-			handler.UpdateSubscription(w, r.WithContext(ctx))
-		})
-		r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
-			handler.DeleteSubscription(w, r.WithContext(ctx))
-		})
-		r.Get("/sum", func(w http.ResponseWriter, r *http.Request) {
-			handler.SumSubscriptionsCostHandler(w, r.WithContext(ctx))
-		})
-	})
+	// Метрики считаются для всех запросов, а отдаются на отдельном внутреннем адресе
+	if m != nil {
+		r.Use(m.Middleware)
+	}
+
+	// Preflight запросы браузера приходят без токена, поэтому CORS проверяется до аутентификации
+	r.Use(cors.Middleware)
+
+	// Swagger UI доступен без токена
+	if cfg.SwaggerEnabled {
+		r.Get("/swagger/*", httpSwagger.Handler(
+			httpSwagger.URL("/swagger/doc.json"),
+		))
+	}
+
+	r.Group(func(r chi.Router) {
+		// Квота IP адреса проверяется до аутентификации, чтобы ограничить и неудачные попытки
+		r.Use(api.IPRateLimit(limiters.ip))
+		if verifier != nil {
+			r.Use(handler.AuthMiddleware(verifier))
+		}
+		// Организация определяется после аутентификации, так как берется из токена или ключа
+		r.Use(api.ResolveTenant)
+		// Квоты считаются по API ключу или пользователю, поэтому тоже после аутентификации
+		r.Use(api.RateLimit(limiters.api))
 
-	r.Get("/swagger/*", httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:8080/swagger/doc.json"),
-	))
+		registerRoutes(r, handler, api.RateLimit(limiters.sum), handler.Idempotency(cfg.IdempotencyTTL, cfg.IdempotencyLease))
+
+		// GraphQL проверяет права API ключей для каждого поля, а тяжелые запросы отсекает по стоимости.
+		// Суммы стоимости списываются с той же квоты, что и GET /subscriptions/sum
+		if cfg.GraphQLEnabled {
+			r.Method(http.MethodPost, "/graphql", newGraphQLHandler(apiStore, cfg, limiters.sum))
+		}
+	})
 
 	srv := &http.Server{
-		Addr:    ":" + config.ConfigInstance.ServerPort,
-		Handler: r,
+		Addr:              net.JoinHostPort(cfg.ServerHost, cfg.ServerPort),
+		Handler:           r,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+		// Контексты запросов наследуются от корневого, чтобы отмена при остановке доходила до хранилища
+		BaseContext: func(net.Listener) context.Context { return ctx },
 	}
 
 	go func() {
-		log.Printf("Start server :%s\n", config.ConfigInstance.ServerPort)
+		log.Printf("Start server %s\n", srv.Addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server error: %v", err)
 		}
 	}()
 
+	// /metrics без токена и с данными всех организаций, поэтому не на публичном порту
+	var metricsSrv *http.Server
+	if m != nil {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", m.Handler())
+		metricsSrv = &http.Server{
+			Addr:              net.JoinHostPort(cfg.MetricsHost, cfg.MetricsPort),
+			Handler:           mux,
+			ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		}
+		go func() {
+			log.Printf("Start metrics server %s\n", metricsSrv.Addr)
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Metrics server error: %v", err)
+			}
+		}()
+	}
+
+	// gRPC сервер использует то же хранилище и ту же аутентификацию на отдельном порту
+	var authenticator *auth.Authenticator
+	if verifier != nil {
+		authenticator = &auth.Authenticator{Verifier: verifier, Keys: apiStore}
+	}
+	grpcServer := grpcserver.New(apiStore, authenticator, grpcserver.RateLimits{
+		IP:     limiters.ip,
+		Client: limiters.api,
+		Sum:    limiters.sum,
+	}, cfg.RequireIfMatch)
+
+	if cfg.GRPCEnabled {
+		go func() {
+			addr := net.JoinHostPort(cfg.ServerHost, cfg.GRPCPort)
+			lis, err := net.Listen("tcp", addr)
+			if err != nil {
+				log.Fatalf("gRPC listen error: %v", err)
+			}
+			log.Printf("Start gRPC server %s\n", addr)
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Fatalf("gRPC server error: %v", err)
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	<-quit
@@ -79,12 +215,224 @@ func main() {
 
 	cancel() // уведомляем зависимости контекста для безопасного завершения
 
-	ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancelShutdown()
 
 	if err := srv.Shutdown(ctxShutdown); err != nil {
 		log.Fatalf("Server Shutdown Failed:%+v", err)
 	}
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(ctxShutdown); err != nil {
+			log.Printf("Metrics server shutdown failed: %v", err)
+		}
+	}
+	grpcServer.GracefulStop()
+	notifier.Wait()
 
 	log.Println("Server exited properly")
 }
+
+// newVerifier создает проверку JWT для REST и gRPC API.
+// Если аутентификация отключена, возвращает nil.
+func newVerifier(cfg *config.Config) *auth.Verifier {
+	if cfg.AuthDisabled {
+		log.Println("WARNING: authentication is disabled, all users' data is accessible")
+		return nil
+	}
+
+	verifier, err := auth.NewVerifier(auth.Config{
+		Secret:        cfg.JWTSecret,
+		PublicKeyFile: cfg.JWTPublicKeyFile,
+		JWKSFile:      cfg.JWTJWKSFile,
+		Issuer:        cfg.JWTIssuer,
+		Audience:      cfg.JWTAudience,
+	})
+	if err != nil {
+		log.Fatalf("failed to configure authentication: %v (set AUTH_DISABLED=true to run without it)", err)
+	}
+	return verifier
+}
+
+// newGraphQLHandler создает обработчик GraphQL с ограничениями запросов из конфигурации
+func newGraphQLHandler(store storage.StorageInterface, cfg *config.Config, sumLimiter *ratelimit.Limiter) *graphqlapi.Handler {
+	handler, err := graphqlapi.NewHandler(store, graphqlapi.Limits{
+		MaxDepth:      cfg.GraphQLMaxDepth,
+		MaxComplexity: cfg.GraphQLMaxComplexity,
+		SumLimiter:    sumLimiter,
+	})
+	if err != nil {
+		log.Fatalf("failed to build GraphQL schema: %v", err)
+	}
+	return handler
+}
+
+// rateLimiters — лимитеры общей квоты, отдельной квоты на сумму подписок
+// и квоты IP адреса до аутентификации. Общие для REST и gRPC API.
+type rateLimiters struct {
+	api, sum, ip *ratelimit.Limiter
+}
+
+// setLimits применяет квоты из конфигурации
+func (l rateLimiters) setLimits(cfg *config.Config) {
+	if l.api == nil {
+		return
+	}
+	l.api.SetLimit(ratelimit.Limit{Rate: cfg.RateLimitRPS, Burst: cfg.RateLimitBurst})
+	l.sum.SetLimit(ratelimit.Limit{Rate: cfg.RateLimitSumRPS, Burst: cfg.RateLimitSumBurst})
+	l.ip.SetLimit(ratelimit.Limit{Rate: cfg.RateLimitIPRPS, Burst: cfg.RateLimitIPBurst})
+}
+
+// newRateLimiters создает лимитеры квот.
+// Если ограничение отключено, лимитеры равны nil и middleware RateLimit ничего не проверяет.
+func newRateLimiters(ctx context.Context, cfg *config.Config) rateLimiters {
+	var store ratelimit.Store
+	switch cfg.RateLimitStore {
+	case "off":
+		log.Println("WARNING: rate limiting is disabled")
+		return rateLimiters{}
+	case "postgres":
+		pgStore := storage.NewRateLimitStore(config.DB)
+		go deleteIdleRateLimits(ctx, pgStore)
+		store = pgStore
+	case "", "memory":
+		store = ratelimit.NewMemoryStore()
+	default:
+		log.Fatalf("unknown RATE_LIMIT_STORE %q, expected memory, postgres or off", cfg.RateLimitStore)
+	}
+
+	return rateLimiters{
+		api: ratelimit.NewLimiter("api", store, ratelimit.Limit{Rate: cfg.RateLimitRPS, Burst: cfg.RateLimitBurst}),
+		sum: ratelimit.NewLimiter("sum", store, ratelimit.Limit{Rate: cfg.RateLimitSumRPS, Burst: cfg.RateLimitSumBurst}),
+		ip:  ratelimit.NewLimiter("ip", store, ratelimit.Limit{Rate: cfg.RateLimitIPRPS, Burst: cfg.RateLimitIPBurst}),
+	}
+}
+
+// deleteIdleRateLimits раз в час удаляет из базы квоты неактивных клиентов
+func deleteIdleRateLimits(ctx context.Context, store *storage.RateLimitStore) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := store.DeleteIdleRateLimits(ctx, time.Hour); err != nil {
+				log.Printf("failed to delete idle rate limits: %v", err)
+			}
+		}
+	}
+}
+
+// watchConfig перечитывает конфигурацию по SIGHUP или при изменении файла
+// и применяет уровень логов, квоты, разрешенные CORS origin и настройки вебхука без перезапуска
+func watchConfig(ctx context.Context, cfg *config.Config, limiters rateLimiters, cors *api.CORS, notifier *notify.Notifier) {
+	watcher := config.NewWatcher(cfg, os.Args[1:], os.Getenv)
+	watcher.OnReload(func(cfg *config.Config) {
+		logging.SetLevel(cfg.LogLevel)
+		limiters.setLimits(cfg)
+		cors.SetOrigins(cfg.CORSAllowedOrigins)
+		notifier.SetConfig(notifyConfig(cfg))
+	})
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	watcher.Run(ctx, hup)
+}
+
+// notifyConfig возвращает настройки вебхука из конфигурации
+func notifyConfig(cfg *config.Config) notify.Config {
+	return notify.Config{
+		URL:     cfg.NotifyWebhookURL,
+		Secret:  cfg.NotifyWebhookSecret,
+		Timeout: cfg.NotifyTimeout,
+	}
+}
+
+// deleteExpiredIdempotencyKeys раз в час удаляет сохраненные ответы с истекшим окном повтора
+func deleteExpiredIdempotencyKeys(ctx context.Context, store *storage.Storage) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := store.DeleteExpiredIdempotencyKeys(ctx, time.Now()); err != nil {
+				log.Printf("failed to delete expired idempotency keys: %v", err)
+			}
+		}
+	}
+}
+
+// updateSubscriptionStats сразу и затем с периодом interval пересчитывает
+// число активных подписок и ежемесячную сумму по организациям
+func updateSubscriptionStats(ctx context.Context, store *storage.Storage, m *metrics.Metrics, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		stats, err := store.SubscriptionStats(ctx, time.Now().UTC())
+		if err != nil {
+			log.Printf("failed to compute subscription metrics: %v", err)
+		} else {
+			m.SetSubscriptionStats(stats)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// registerRoutes регистрирует маршруты API.
+// Права API ключей проверяются для каждого маршрута, пользователей с JWT они не ограничивают.
+// sumLimit — дополнительная квота на расчет суммы, самый тяжелый для базы запрос.
+// idempotent повторяет сохраненный ответ на создание подписки с тем же Idempotency-Key.
+func registerRoutes(r chi.Router, handler *api.Handler, sumLimit, idempotent func(http.Handler) http.Handler) {
+	read := api.RequireScope(auth.ScopeSubscriptionsRead)
+	write := api.RequireScope(auth.ScopeSubscriptionsWrite)
+	reports := api.RequireScope(auth.ScopeReportsRead)
+
+	r.Route("/subscriptions", func(r chi.Router) {
+		r.With(read).Get("/", handler.ListSubscriptions)
+		r.With(write, idempotent).Post("/", handler.CreateSubscription)
+		r.With(reports, sumLimit).Get("/sum", handler.SumSubscriptionsCostHandler)
+		r.With(read).Get("/trials", handler.ListTrialsEndingSoon)
+		r.With(read).Get("/{id}", handler.GetSubscription)
+		r.With(write).Put("/{id}", handler.UpdateSubscription)
+		r.With(write).Patch("/{id}", handler.PatchSubscription)
+		r.With(write).Delete("/{id}", handler.DeleteSubscription)
+		r.With(write).Post("/{id}/pause", handler.PauseSubscription)
+		r.With(write).Post("/{id}/resume", handler.ResumeSubscription)
+	})
+
+	r.Route("/services", func(r chi.Router) {
+		r.With(read).Get("/", handler.ListServices)
+		r.With(read).Get("/{id}", handler.GetService)
+
+		// Каталог общий для всех пользователей и переименовывает подписки,
+		// поэтому изменять его может только администратор
+		r.Group(func(r chi.Router) {
+			r.Use(api.RequireRole(auth.RoleAdmin))
+			r.Post("/", handler.CreateService)
+			r.Put("/{id}", handler.UpdateService)
+			r.Delete("/{id}", handler.DeleteService)
+		})
+	})
+
+	r.With(read).Get("/tags", handler.ListTags)
+
+	// Ключи выпускает администратор; сами ключи роли не имеют и управлять ключами не могут
+	r.Route("/api-keys", func(r chi.Router) {
+		r.Use(api.RequireRole(auth.RoleAdmin))
+		r.Get("/", handler.ListAPIKeys)
+		r.Post("/", handler.CreateAPIKey)
+		r.Delete("/{id}", handler.RevokeAPIKey)
+		r.Post("/{id}/rotate", handler.RotateAPIKey)
+	})
+}
@@ -1,7 +1,60 @@
+// Команда migrations управляет схемой базы: применяет, откатывает и создает миграции
 package main
 
-import "subscribe_aggregation-main/internal/migrations"
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"subscribe_aggregation-main/internal/config"
+	"subscribe_aggregation-main/internal/migrations"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: migrations [--dir DIR] COMMAND [ARGS]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, c := range migrations.Commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", c.Name, c.Usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nThe database is configured like the server: CONFIG_FILE and POSTGRES_* variables.")
+}
 
 func main() {
-	migrations.RunMigrations()
+	dir := flag.String("dir", "internal/storage/.sql", "directory for new migrations (create only, others use the embedded ones)")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	command, args := flag.Arg(0), flag.Args()[1:]
+
+	if command == "create" {
+		if len(args) != 1 {
+			usage()
+			os.Exit(2)
+		}
+		path, err := migrations.Create(*dir, args[0], time.Now())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrations:", err)
+			os.Exit(1)
+		}
+		fmt.Println("created", path)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	config.InitDB()
+	defer config.DB.Close()
+
+	if err := migrations.Run(ctx, config.DB.DB, os.Stdout, command, args); err != nil {
+		fmt.Fprintln(os.Stderr, "migrations:", err)
+		stop()
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,17 @@
+// Команда subctl управляет подписками через HTTP API из командной строки
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"subscribe_aggregation-main/internal/subctl"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := subctl.Run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr, os.Getenv)
+	stop()
+	os.Exit(code)
+}
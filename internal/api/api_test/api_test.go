@@ -3,17 +3,23 @@ package api_test
 import (
 	"bytes"
 	"context"
-	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"subscribe_aggregation-main/internal/api"
+	"subscribe_aggregation-main/internal/auth"
 	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/ratelimit"
+	"subscribe_aggregation-main/internal/storage"
+	"subscribe_aggregation-main/internal/tenant"
+	"subscribe_aggregation-main/pkg/logging"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -33,8 +39,8 @@ func (m *MockStorage) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*m
 	return args.Get(0).(*models.Subscription), args.Error(1)
 }
 
-func (m *MockStorage) ListSubscriptions(ctx context.Context, page, limit int) ([]models.Subscription, error) {
-	args := m.Called(ctx, page, limit)
+func (m *MockStorage) ListSubscriptions(ctx context.Context, filter storage.ListFilter) ([]models.Subscription, error) {
+	args := m.Called(ctx, filter)
 	return args.Get(0).([]models.Subscription), args.Error(1)
 }
 
@@ -43,8 +49,8 @@ func (m *MockStorage) UpdateSubscription(ctx context.Context, sub *models.Subscr
 	return args.Error(0)
 }
 
-func (m *MockStorage) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
-	args := m.Called(ctx, id)
+func (m *MockStorage) DeleteSubscription(ctx context.Context, id uuid.UUID, version int) error {
+	args := m.Called(ctx, id, version)
 	return args.Error(0)
 }
 
@@ -53,6 +59,102 @@ func (m *MockStorage) SumSubscriptionsCost(ctx context.Context, userID, serviceN
 	return args.Get(0).(int64), args.Error(1)
 }
 
+func (m *MockStorage) SumSubscriptionsCostByCategory(ctx context.Context, userID, serviceName string, filterStart, filterEnd time.Time) (map[string]int64, error) {
+	args := m.Called(ctx, userID, serviceName, filterStart, filterEnd)
+	return args.Get(0).(map[string]int64), args.Error(1)
+}
+
+func (m *MockStorage) CreateService(ctx context.Context, svc *models.Service) error {
+	args := m.Called(ctx, svc)
+	return args.Error(0)
+}
+
+func (m *MockStorage) GetServiceByID(ctx context.Context, id uuid.UUID) (*models.Service, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Service), args.Error(1)
+}
+
+func (m *MockStorage) ListServices(ctx context.Context, category string) ([]models.Service, error) {
+	args := m.Called(ctx, category)
+	return args.Get(0).([]models.Service), args.Error(1)
+}
+
+func (m *MockStorage) UpdateService(ctx context.Context, svc *models.Service) error {
+	args := m.Called(ctx, svc)
+	return args.Error(0)
+}
+
+func (m *MockStorage) DeleteService(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockStorage) ListTags(ctx context.Context) ([]models.Tag, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.Tag), args.Error(1)
+}
+
+func (m *MockStorage) PauseSubscription(ctx context.Context, id uuid.UUID, date time.Time) (*models.Pause, int, error) {
+	args := m.Called(ctx, id, date)
+	return args.Get(0).(*models.Pause), args.Int(1), args.Error(2)
+}
+
+func (m *MockStorage) ResumeSubscription(ctx context.Context, id uuid.UUID, date time.Time) (*models.Pause, int, error) {
+	args := m.Called(ctx, id, date)
+	return args.Get(0).(*models.Pause), args.Int(1), args.Error(2)
+}
+
+func (m *MockStorage) ListTrialsEndingSoon(ctx context.Context, userID string, from, to time.Time) ([]models.Subscription, error) {
+	args := m.Called(ctx, userID, from, to)
+	return args.Get(0).([]models.Subscription), args.Error(1)
+}
+
+func (m *MockStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockStorage) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	args := m.Called(ctx, hash)
+	return args.Get(0).(*models.APIKey), args.Error(1)
+}
+
+func (m *MockStorage) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+func (m *MockStorage) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockStorage) RotateAPIKey(ctx context.Context, key *models.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockStorage) TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error {
+	args := m.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+func (m *MockStorage) ReserveIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	args := m.Called(ctx, rec)
+	existing, _ := args.Get(0).(*models.IdempotencyRecord)
+	return existing, args.Error(1)
+}
+
+func (m *MockStorage) CompleteIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) error {
+	args := m.Called(ctx, rec)
+	return args.Error(0)
+}
+
+func (m *MockStorage) ReleaseIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) error {
+	args := m.Called(ctx, rec)
+	return args.Error(0)
+}
+
 func TestCreateSubscription(t *testing.T) {
 	mockStore := new(MockStorage)
 	handler := &api.Handler{
@@ -95,6 +197,18 @@ func TestCreateSubscription(t *testing.T) {
 			mockResp:       assert.AnError,
 			expectedStatus: http.StatusInternalServerError,
 		},
+		{
+			name: "invalid_split",
+			requestBody: map[string]interface{}{
+				"user_id":      uuid.New().String(),
+				"service_name": "svc1",
+				"price":        100.0,
+				"start_date":   time.Now().Format("2006-01-02"),
+				"split_mode":   "custom",
+			},
+			mockResp:       storage.ErrInvalidSplit,
+			expectedStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
@@ -106,8 +220,8 @@ func TestCreateSubscription(t *testing.T) {
 						sub.Price == int(tt.requestBody["price"].(float64)) &&
 						sub.UserID.String() == tt.requestBody["user_id"].(string)
 				})).Return(tt.mockResp).Once()
-			} else if tt.name == "storage_error" {
-				// Для storage_error также ожидаем вызов CreateSubscription
+			} else if tt.name == "storage_error" || tt.name == "invalid_split" {
+				// Для ошибок хранилища также ожидаем вызов CreateSubscription
 				mockStore.On("CreateSubscription", mock.Anything, mock.MatchedBy(func(sub *models.Subscription) bool {
 					return sub.ServiceName == tt.requestBody["service_name"].(string) &&
 						sub.Price == int(tt.requestBody["price"].(float64)) &&
@@ -136,6 +250,29 @@ func TestCreateSubscription(t *testing.T) {
 	}
 }
 
+func TestCreateSubscriptionMonthEndDate(t *testing.T) {
+	mockStore := new(MockStorage)
+	handler := &api.Handler{
+		Storage: mockStore,
+	}
+
+	// Месяц без дня в end_date тела включает последний день месяца, как в параметрах запроса
+	wantEnd := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+	mockStore.On("CreateSubscription", mock.Anything, mock.MatchedBy(func(sub *models.Subscription) bool {
+		return sub.StartDate.ToTime().Equal(time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)) &&
+			sub.EndDate != nil && sub.EndDate.ToTime().Equal(wantEnd)
+	})).Return(nil).Once()
+
+	body := `{"user_id":"` + uuid.New().String() + `","service_name":"svc1","price":100,"start_date":"07-2025","end_date":"12-2025"}`
+	req, _ := http.NewRequest("POST", "/subscriptions", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	handler.CreateSubscription(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"end_date":"2025-12-31"`)
+	mockStore.AssertExpectations(t)
+}
+
 func TestDeleteSubscription(t *testing.T) {
 	mockStore := new(MockStorage)
 	handler := &api.Handler{
@@ -157,7 +294,7 @@ func TestDeleteSubscription(t *testing.T) {
 		{
 			name:           "not_found",
 			id:             "00000000-0000-0000-0000-000000000000",
-			mockResp:       sql.ErrNoRows,
+			mockResp:       storage.ErrNotFound,
 			expectedStatus: http.StatusNotFound,
 		},
 		{
@@ -171,7 +308,7 @@ func TestDeleteSubscription(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockID, _ := uuid.Parse(tt.id)
-			mockStore.On("DeleteSubscription", mock.Anything, mockID).Return(tt.mockResp).Once()
+			mockStore.On("DeleteSubscription", mock.Anything, mockID, 0).Return(tt.mockResp).Once()
 
 			req, _ := http.NewRequest("DELETE", "/subscriptions/"+tt.id, nil)
 			rctx := chi.NewRouteContext()
@@ -220,6 +357,13 @@ func TestGetSubscription(t *testing.T) {
 			mockErr:        nil,
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name:           "not_found",
+			id:             "00000000-0000-0000-0000-000000000000",
+			mockResp:       nil,
+			mockErr:        storage.ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
 		{
 			name:           "internal_error",
 			id:             "123e4567-e89b-12d3-a456-426614174000",
@@ -262,3 +406,1121 @@ func TestGetSubscription(t *testing.T) {
 		})
 	}
 }
+
+func TestProblemResponse(t *testing.T) {
+	handler := &api.Handler{Storage: new(MockStorage)}
+	server := logging.Middleware(http.HandlerFunc(handler.CreateSubscription))
+
+	req, _ := http.NewRequest("POST", "/subscriptions", bytes.NewBufferString(`{"price": 0}`))
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+
+	var problem api.Problem
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+	assert.Equal(t, api.CodeValidationFailed, problem.Code)
+	assert.Equal(t, "/subscriptions", problem.Instance)
+	assert.NotEmpty(t, problem.RequestID)
+
+	fields := make([]string, 0, len(problem.Errors))
+	for _, fieldErr := range problem.Errors {
+		fields = append(fields, fieldErr.Field)
+	}
+	assert.ElementsMatch(t, []string{"service_name", "price", "user_id", "start_date"}, fields)
+}
+
+func TestUpdateSubscriptionValidation(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		expectedCode  string
+		expectedField string
+	}{
+		{
+			name:          "end_before_start",
+			body:          `{"service_name": "Netflix", "price": 100, "start_date": "2024-05-01", "end_date": "2024-04-01"}`,
+			expectedCode:  api.CodeValidationFailed,
+			expectedField: "end_date",
+		},
+		{
+			name:          "price_too_high",
+			body:          `{"service_name": "Netflix", "price": 100000000, "start_date": "2024-05-01"}`,
+			expectedCode:  api.CodeValidationFailed,
+			expectedField: "price",
+		},
+		{
+			name:          "service_name_charset",
+			body:          `{"service_name": "<script>", "price": 100, "start_date": "2024-05-01"}`,
+			expectedCode:  api.CodeValidationFailed,
+			expectedField: "service_name",
+		},
+		{
+			name:          "unknown_field",
+			body:          `{"service_name": "Netflix", "price": 100, "start_date": "2024-05-01", "cost": 5}`,
+			expectedCode:  api.CodeInvalidBody,
+			expectedField: "cost",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Хранилище не должно вызываться для некорректных данных
+			mockStore := new(MockStorage)
+			handler := &api.Handler{Storage: mockStore}
+			id := uuid.New().String()
+
+			req, _ := http.NewRequest("PUT", "/subscriptions/"+id, bytes.NewBufferString(tt.body))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := httptest.NewRecorder()
+			handler.UpdateSubscription(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			var problem api.Problem
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+			assert.Equal(t, tt.expectedCode, problem.Code)
+			if assert.Len(t, problem.Errors, 1) {
+				assert.Equal(t, tt.expectedField, problem.Errors[0].Field)
+			}
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUpdateSubscriptionIgnoresServerFields(t *testing.T) {
+	mockStore := new(MockStorage)
+	handler := &api.Handler{Storage: mockStore}
+	id := uuid.New()
+
+	// Тело — подписка, прочитанная GET, с полями, которые заполняет сервер
+	body := `{"id": "` + uuid.NewString() + `", "service_name": "Netflix", "price": 100, "start_date": "2024-05-01",
+		"version": 7, "created_at": "2020-01-01", "updated_at": "2020-01-01", "trial_end_date": "2020-02-01",
+		"pauses": [{"id": "` + uuid.NewString() + `", "subscription_id": "` + id.String() + `", "start_date": "2024-06-01"}]}`
+	mockStore.On("UpdateSubscription", mock.Anything, mock.MatchedBy(func(sub *models.Subscription) bool {
+		return sub.ID == id && sub.Version == 0 && sub.Pauses == nil && sub.TrialEndDate == nil &&
+			time.Time(sub.CreatedAt).IsZero() && time.Time(sub.UpdatedAt).IsZero()
+	})).Run(func(args mock.Arguments) {
+		sub := args.Get(1).(*models.Subscription)
+		sub.Version = 2
+		sub.CreatedAt = models.DataOnly(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	}).Return(nil).Once()
+
+	req, _ := http.NewRequest("PUT", "/subscriptions/"+id.String(), bytes.NewBufferString(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", id.String())
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	rr := httptest.NewRecorder()
+	handler.UpdateSubscription(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got map[string]any
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, id.String(), got["id"])
+	assert.Equal(t, float64(2), got["version"])
+	assert.Equal(t, "2024-05-01", got["created_at"])
+	assert.NotContains(t, got, "pauses")
+	assert.NotContains(t, got, "trial_end_date")
+	mockStore.AssertExpectations(t)
+}
+
+func TestUpdateSubscriptionOwner(t *testing.T) {
+	id := uuid.New()
+	owner := uuid.New()
+	request := func(body string) *http.Request {
+		req, _ := http.NewRequest("PUT", "/subscriptions/"+id.String(), bytes.NewBufferString(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id.String())
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	t.Run("changed", func(t *testing.T) {
+		mockStore := new(MockStorage)
+		handler := &api.Handler{Storage: mockStore}
+		mockStore.On("UpdateSubscription", mock.Anything, mock.Anything).Return(storage.ErrOwnerChanged).Once()
+
+		rr := httptest.NewRecorder()
+		handler.UpdateSubscription(rr, request(`{"user_id": "`+uuid.NewString()+`", "service_name": "Netflix", "price": 100, "start_date": "2024-05-01"}`))
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		var problem api.Problem
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+		assert.Equal(t, api.CodeValidationFailed, problem.Code)
+		if assert.Len(t, problem.Errors, 1) {
+			assert.Equal(t, "user_id", problem.Errors[0].Field)
+		}
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("omitted", func(t *testing.T) {
+		// Хранилище заполняет владельца, и ответ содержит сохраненное значение
+		mockStore := new(MockStorage)
+		handler := &api.Handler{Storage: mockStore}
+		mockStore.On("UpdateSubscription", mock.Anything, mock.MatchedBy(func(sub *models.Subscription) bool {
+			return sub.UserID == uuid.Nil
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*models.Subscription).UserID = owner
+		}).Return(nil).Once()
+
+		rr := httptest.NewRecorder()
+		handler.UpdateSubscription(rr, request(`{"service_name": "Netflix", "price": 100, "start_date": "2024-05-01"}`))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var got models.Subscription
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+		assert.Equal(t, owner, got.UserID)
+		mockStore.AssertExpectations(t)
+	})
+}
+
+func TestStorageErrorCodes(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"not_found", storage.ErrNotFound, http.StatusNotFound, api.CodeNotFound},
+		{"already_paused", storage.ErrAlreadyPaused, http.StatusConflict, api.CodeAlreadyPaused},
+		{"invalid_pause_date", storage.ErrInvalidPauseDate, http.StatusBadRequest, api.CodeInvalidPauseDate},
+		{"internal_error", assert.AnError, http.StatusInternalServerError, api.CodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockStorage)
+			handler := &api.Handler{Storage: mockStore}
+			id := uuid.New()
+			mockStore.On("PauseSubscription", mock.Anything, id, mock.Anything).Return((*models.Pause)(nil), 0, tt.err).Once()
+
+			req, _ := http.NewRequest("POST", "/subscriptions/"+id.String()+"/pause", bytes.NewBufferString(`{}`))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", id.String())
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := httptest.NewRecorder()
+			handler.PauseSubscription(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			var problem api.Problem
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+			assert.Equal(t, tt.expectedCode, problem.Code)
+			if tt.err == assert.AnError {
+				// Текст внутренней ошибки не должен попадать в ответ
+				assert.NotContains(t, problem.Detail, assert.AnError.Error())
+			}
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateService(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    map[string]interface{}
+		callStorage    bool
+		mockResp       error
+		expectedStatus int
+	}{
+		{
+			name: "success",
+			requestBody: map[string]interface{}{
+				"name":     "Yandex Plus",
+				"aliases":  []string{"Яндекс Плюс"},
+				"category": "video",
+			},
+			callStorage:    true,
+			mockResp:       nil,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "missing_name",
+			requestBody: map[string]interface{}{
+				"name": "  ",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "alias_conflict",
+			requestBody: map[string]interface{}{
+				"name":    "Yandex Plus",
+				"aliases": []string{"кинопоиск"},
+			},
+			callStorage:    true,
+			mockResp:       storage.ErrServiceAliasTaken,
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockStorage)
+			handler := &api.Handler{
+				Storage: mockStore,
+			}
+			if tt.callStorage {
+				mockStore.On("CreateService", mock.Anything, mock.MatchedBy(func(svc *models.Service) bool {
+					return svc.Name == tt.requestBody["name"].(string) && svc.ID != uuid.Nil
+				})).Return(tt.mockResp).Once()
+			}
+
+			jsonBody, _ := json.Marshal(tt.requestBody)
+			req, _ := http.NewRequest("POST", "/services", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			handler.CreateService(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteService(t *testing.T) {
+	mockStore := new(MockStorage)
+	handler := &api.Handler{
+		Storage: mockStore,
+	}
+
+	tests := []struct {
+		name           string
+		id             string
+		mockResp       error
+		expectedStatus int
+	}{
+		{
+			name:           "success",
+			id:             "123e4567-e89b-12d3-a456-426614174000",
+			mockResp:       nil,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "not_found",
+			id:             "00000000-0000-0000-0000-000000000000",
+			mockResp:       storage.ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockID, _ := uuid.Parse(tt.id)
+			mockStore.On("DeleteService", mock.Anything, mockID).Return(tt.mockResp).Once()
+
+			req, _ := http.NewRequest("DELETE", "/services/"+tt.id, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := httptest.NewRecorder()
+			handler.DeleteService(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestListSubscriptionsFilters(t *testing.T) {
+	mockStore := new(MockStorage)
+	handler := &api.Handler{
+		Storage: mockStore,
+	}
+
+	userID := uuid.New().String()
+	expectedFilter := storage.ListFilter{
+		Page:        2,
+		Limit:       5,
+		UserID:      userID,
+		ServiceName: "Netflix",
+		Tags:        []string{"video", "family"},
+		From:        time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(2025, 9, 30, 0, 0, 0, 0, time.UTC),
+	}
+	mockStore.On("ListSubscriptions", mock.Anything, expectedFilter).Return([]models.Subscription{}, nil).Once()
+
+	req, _ := http.NewRequest("GET", "/subscriptions?page=2&limit=5&user_id="+userID+"&service_name=Netflix&tag=video&tag=family&start_date=07-2025&end_date=2025-09", nil)
+	rr := httptest.NewRecorder()
+	handler.ListSubscriptions(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockStore.AssertExpectations(t)
+
+	for _, query := range []string{"user_id=not-a-uuid", "start_date=2025/07", "start_date=09-2025&end_date=07-2025"} {
+		req, _ = http.NewRequest("GET", "/subscriptions?"+query, nil)
+		rr = httptest.NewRecorder()
+		handler.ListSubscriptions(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+
+	// Ошибки хранилища преобразуются так же, как в остальных обработчиках
+	mockStore.On("ListSubscriptions", mock.Anything, mock.Anything).Return([]models.Subscription(nil), storage.ErrInvalidInput).Once()
+	req, _ = http.NewRequest("GET", "/subscriptions", nil)
+	rr = httptest.NewRecorder()
+	handler.ListSubscriptions(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+}
+
+func TestSumSubscriptionsCostByCategory(t *testing.T) {
+	mockStore := new(MockStorage)
+	handler := &api.Handler{
+		Storage: mockStore,
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Месяц без дня в end_date включает последний день месяца
+	end := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	mockStore.On("SumSubscriptionsCostByCategory", mock.Anything, "", "", start, end).
+		Return(map[string]int64{"video": 1200, "music": 600}, nil).Once()
+
+	req, _ := http.NewRequest("GET", "/subscriptions/sum?start_date=01-2024&end_date=06-2024&group_by=category", nil)
+	rr := httptest.NewRecorder()
+	handler.SumSubscriptionsCostHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response api.CategoryCostResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, int64(1200), response.Categories["video"])
+	mockStore.AssertExpectations(t)
+
+	for _, query := range []string{"group_by=service", "user_id=not-a-uuid"} {
+		req, _ = http.NewRequest("GET", "/subscriptions/sum?"+query, nil)
+		rr = httptest.NewRecorder()
+		handler.SumSubscriptionsCostHandler(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestPauseSubscription(t *testing.T) {
+	mockStore := new(MockStorage)
+	handler := &api.Handler{
+		Storage: mockStore,
+	}
+
+	id := uuid.MustParse("123e4567-e89b-12d3-a456-426614174000")
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		mockResp       *models.Pause
+		mockErr        error
+		expectedStatus int
+		expectedETag   string
+	}{
+		{
+			name:           "success",
+			mockResp:       &models.Pause{ID: uuid.New(), SubscriptionID: id, StartDate: models.DataOnly(date)},
+			expectedStatus: http.StatusCreated,
+			expectedETag:   `"3"`,
+		},
+		{
+			name:           "already_paused",
+			mockResp:       nil,
+			mockErr:        storage.ErrAlreadyPaused,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "not_found",
+			mockResp:       nil,
+			mockErr:        storage.ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore.On("PauseSubscription", mock.Anything, id, date).Return(tt.mockResp, 3, tt.mockErr).Once()
+
+			req, _ := http.NewRequest("POST", "/subscriptions/"+id.String()+"/pause", bytes.NewBufferString(`{"date":"2024-03-01"}`))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", id.String())
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := httptest.NewRecorder()
+			handler.PauseSubscription(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedETag, rr.Header().Get("ETag"))
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestListTrialsEndingSoon(t *testing.T) {
+	mockStore := new(MockStorage)
+	handler := &api.Handler{
+		Storage: mockStore,
+	}
+
+	mockStore.On("ListTrialsEndingSoon", mock.Anything, "", mock.Anything, mock.Anything).
+		Return([]models.Subscription{}, nil).
+		Run(func(args mock.Arguments) {
+			from, to := args.Get(2).(time.Time), args.Get(3).(time.Time)
+			assert.Equal(t, from.AddDate(0, 0, 14), to)
+		}).Once()
+
+	req, _ := http.NewRequest("GET", "/subscriptions/trials?days=14", nil)
+	rr := httptest.NewRecorder()
+	handler.ListTrialsEndingSoon(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockStore.AssertExpectations(t)
+
+	for _, days := range []string{"-1", "366", "99999999"} {
+		req, _ = http.NewRequest("GET", "/subscriptions/trials?days="+days, nil)
+		rr = httptest.NewRecorder()
+		handler.ListTrialsEndingSoon(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "days=%s", days)
+	}
+
+	// Ошибки хранилища отвечают так же, как в остальных обработчиках
+	mockStore.On("ListTrialsEndingSoon", mock.Anything, "", mock.Anything, mock.Anything).
+		Return([]models.Subscription(nil), storage.ErrInvalidInput).Once()
+	req, _ = http.NewRequest("GET", "/subscriptions/trials?days=365", nil)
+	rr = httptest.NewRecorder()
+	handler.ListTrialsEndingSoon(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	var problem api.Problem
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+	assert.Equal(t, api.CodeValidationFailed, problem.Code)
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	verifier, err := auth.NewVerifier(auth.Config{Secret: "test-secret"})
+	assert.NoError(t, err)
+
+	userID := uuid.New()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": userID.String(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("test-secret"))
+	assert.NoError(t, err)
+
+	var got *auth.Principal
+	handler := &api.Handler{Storage: new(MockStorage)}
+	server := handler.AuthMiddleware(verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = auth.FromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name           string
+		header         string
+		expectedStatus int
+	}{
+		{name: "missing_token", header: "", expectedStatus: http.StatusUnauthorized},
+		{name: "invalid_token", header: "Bearer not-a-token", expectedStatus: http.StatusUnauthorized},
+		{name: "valid_token", header: "Bearer " + token, expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			req, _ := http.NewRequest("GET", "/subscriptions", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusUnauthorized {
+				assert.NotEmpty(t, rr.Header().Get("WWW-Authenticate"))
+				assert.Nil(t, got)
+				return
+			}
+			if assert.NotNil(t, got) {
+				assert.Equal(t, userID, got.UserID)
+			}
+		})
+	}
+}
+
+func TestSubscriptionScoping(t *testing.T) {
+	mockStore := new(MockStorage)
+	handler := &api.Handler{Storage: mockStore}
+
+	owner := &auth.Principal{UserID: uuid.New()}
+	other := uuid.New()
+	withPrincipal := func(req *http.Request, p *auth.Principal) *http.Request {
+		return req.WithContext(auth.NewContext(req.Context(), p))
+	}
+
+	// Без user_id список ограничивается подписками пользователя
+	mockStore.On("ListSubscriptions", mock.Anything, storage.ListFilter{Page: 1, Limit: 10, UserID: owner.UserID.String()}).
+		Return([]models.Subscription{}, nil).Once()
+	req, _ := http.NewRequest("GET", "/subscriptions", nil)
+	rr := httptest.NewRecorder()
+	handler.ListSubscriptions(rr, withPrincipal(req, owner))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Чужой user_id запрещен
+	req, _ = http.NewRequest("GET", "/subscriptions?user_id="+other.String(), nil)
+	rr = httptest.NewRecorder()
+	handler.ListSubscriptions(rr, withPrincipal(req, owner))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	// Чужая подписка выглядит как несуществующая
+	subID := uuid.New()
+	foreign := &models.Subscription{ID: subID, UserID: other, ServiceName: "svc", Price: 100}
+	mockStore.On("GetSubscriptionByID", mock.Anything, subID).Return(foreign, nil).Twice()
+
+	for _, call := range []struct {
+		method string
+		serve  http.HandlerFunc
+	}{
+		{method: "GET", serve: handler.GetSubscription},
+		{method: "DELETE", serve: handler.DeleteSubscription},
+	} {
+		req, _ = http.NewRequest(call.method, "/subscriptions/"+subID.String(), nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", subID.String())
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rr = httptest.NewRecorder()
+		call.serve(rr, withPrincipal(req, owner))
+		assert.Equal(t, http.StatusNotFound, rr.Code, call.method)
+	}
+
+	// Управление сервисами доступно только администратору
+	req, _ = http.NewRequest("POST", "/services", bytes.NewBufferString(`{}`))
+	rr = httptest.NewRecorder()
+	api.RequireRole(auth.RoleAdmin)(http.HandlerFunc(handler.CreateService)).
+		ServeHTTP(rr, withPrincipal(req, owner))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	// Администратор видит подписки всех пользователей
+	admin := &auth.Principal{UserID: uuid.New(), Roles: []string{auth.RoleAdmin}}
+	mockStore.On("ListSubscriptions", mock.Anything, storage.ListFilter{Page: 1, Limit: 10, UserID: other.String()}).
+		Return([]models.Subscription{*foreign}, nil).Once()
+	req, _ = http.NewRequest("GET", "/subscriptions?user_id="+other.String(), nil)
+	rr = httptest.NewRecorder()
+	handler.ListSubscriptions(rr, withPrincipal(req, admin))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	mockStore := new(MockStorage)
+	handler := &api.Handler{Storage: mockStore}
+	verifier, err := auth.NewVerifier(auth.Config{Secret: "test-secret"})
+	assert.NoError(t, err)
+
+	key, _, hash, err := auth.NewAPIKey()
+	assert.NoError(t, err)
+	stored := &models.APIKey{ID: uuid.New(), Name: "billing-export", Hash: hash, Scopes: []string{auth.ScopeReportsRead}}
+
+	mockStore.On("GetAPIKeyByHash", mock.Anything, hash).Return(stored, nil).Twice()
+	mockStore.On("GetAPIKeyByHash", mock.Anything, auth.HashAPIKey("sak_revoked")).Return((*models.APIKey)(nil), storage.ErrNotFound).Once()
+	mockStore.On("TouchAPIKey", mock.Anything, stored.ID, mock.AnythingOfType("time.Time")).Return(nil).Twice()
+	mockStore.On("SumSubscriptionsCost", mock.Anything, "", "", time.Time{}, mock.Anything).Return(int64(0), nil).Once()
+
+	router := chi.NewRouter()
+	router.Use(handler.AuthMiddleware(verifier))
+	router.With(api.RequireScope(auth.ScopeReportsRead)).Get("/subscriptions/sum", handler.SumSubscriptionsCostHandler)
+	router.With(api.RequireScope(auth.ScopeSubscriptionsRead)).Get("/subscriptions", handler.ListSubscriptions)
+
+	tests := []struct {
+		name           string
+		key            string
+		path           string
+		expectedStatus int
+	}{
+		{name: "scope_granted", key: key, path: "/subscriptions/sum", expectedStatus: http.StatusOK},
+		{name: "scope_missing", key: key, path: "/subscriptions", expectedStatus: http.StatusForbidden},
+		{name: "revoked_key", key: "sak_revoked", path: "/subscriptions/sum", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			req.Header.Set(api.APIKeyHeader, tt.key)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	mockStore := new(MockStorage)
+	handler := &api.Handler{Storage: mockStore}
+
+	var stored *models.APIKey
+	mockStore.On("CreateAPIKey", mock.Anything, mock.AnythingOfType("*models.APIKey")).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*models.APIKey) }).
+		Return(nil).Once()
+
+	body := `{"name": "billing-export", "scopes": ["subscriptions:read", "reports:read"]}`
+	req, _ := http.NewRequest("POST", "/api-keys", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	handler.CreateAPIKey(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var response models.APIKey
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	// Открытый ключ возвращается клиенту, а в хранилище попадает только его хеш
+	assert.NotEmpty(t, response.Key)
+	assert.Equal(t, response.Key[:len(response.Prefix)], response.Prefix)
+	assert.Equal(t, auth.HashAPIKey(response.Key), stored.Hash)
+	assert.NotContains(t, rr.Body.String(), stored.Hash)
+
+	req, _ = http.NewRequest("POST", "/api-keys", bytes.NewBufferString(`{"name": "job", "scopes": ["admin"]}`))
+	rr = httptest.NewRecorder()
+	handler.CreateAPIKey(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestResolveTenant(t *testing.T) {
+	var got string
+	server := api.ResolveTenant(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = tenant.FromContext(r.Context())
+	}))
+
+	member := &auth.Principal{UserID: uuid.New(), TenantID: "acme"}
+	legacy := &auth.Principal{UserID: uuid.New()}
+	operator := &auth.Principal{UserID: uuid.New(), Roles: []string{auth.RoleAdmin}}
+
+	tests := []struct {
+		name           string
+		principal      *auth.Principal
+		header         string
+		expectedStatus int
+		expectedTenant string
+	}{
+		{name: "no_auth_default", expectedStatus: http.StatusOK, expectedTenant: tenant.Default},
+		{name: "no_auth_header", header: "acme", expectedStatus: http.StatusOK, expectedTenant: "acme"},
+		{name: "invalid_header", header: "Acme Inc", expectedStatus: http.StatusBadRequest},
+		{name: "token_tenant", principal: member, expectedStatus: http.StatusOK, expectedTenant: "acme"},
+		{name: "token_tenant_matching_header", principal: member, header: "acme", expectedStatus: http.StatusOK, expectedTenant: "acme"},
+		{name: "token_tenant_other_header", principal: member, header: "globex", expectedStatus: http.StatusForbidden},
+		{name: "token_without_tenant", principal: legacy, expectedStatus: http.StatusOK, expectedTenant: tenant.Default},
+		{name: "token_without_tenant_header", principal: legacy, header: "globex", expectedStatus: http.StatusForbidden},
+		{name: "operator_header", principal: operator, header: "globex", expectedStatus: http.StatusOK, expectedTenant: "globex"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = ""
+			req, _ := http.NewRequest("GET", "/subscriptions", nil)
+			if tt.header != "" {
+				req.Header.Set(api.TenantHeader, tt.header)
+			}
+			if tt.principal != nil {
+				req = req.WithContext(auth.NewContext(req.Context(), tt.principal))
+			}
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedTenant, got)
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter := ratelimit.NewLimiter("api", ratelimit.NewMemoryStore(), ratelimit.Limit{Rate: 0.5, Burst: 2})
+	server := api.RateLimit(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(remoteAddr string, principal *auth.Principal) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/subscriptions/sum", nil)
+		req.RemoteAddr = remoteAddr
+		if principal != nil {
+			req = req.WithContext(auth.NewContext(req.Context(), principal))
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, remaining := range []string{"1", "0"} {
+		rr := request("10.0.0.1:1234", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "2", rr.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, remaining, rr.Header().Get("RateLimit-Remaining"))
+	}
+
+	rr := request("10.0.0.1:5678", nil)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("Retry-After"))
+	assert.Equal(t, "4", rr.Header().Get("RateLimit-Reset"))
+	var problem api.Problem
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+	assert.Equal(t, api.CodeRateLimited, problem.Code)
+
+	// Пользователь с токеном получает свою квоту независимо от адреса
+	rr = request("10.0.0.1:5678", &auth.Principal{UserID: uuid.New()})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Без лимитера запросы не ограничиваются и заголовки не добавляются
+	rr = httptest.NewRecorder()
+	api.RateLimit(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+}
+
+func TestIPRateLimitBeforeAuth(t *testing.T) {
+	verifier, err := auth.NewVerifier(auth.Config{Secret: "test-secret"})
+	assert.NoError(t, err)
+	handler := &api.Handler{Storage: new(MockStorage)}
+	limiter := ratelimit.NewLimiter("ip", ratelimit.NewMemoryStore(), ratelimit.Limit{Rate: 0.001, Burst: 2})
+	server := api.IPRateLimit(limiter)(handler.AuthMiddleware(verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	// Неудачные попытки аутентификации тоже расходуют квоту адреса
+	for _, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		req := httptest.NewRequest("GET", "/subscriptions", nil)
+		req.RemoteAddr = "10.0.0.2:1234"
+		req.Header.Set("Authorization", "Bearer not-a-token")
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		assert.Equal(t, want, rr.Code)
+	}
+}
+
+func TestRequestBodyTooLarge(t *testing.T) {
+	handler := &api.Handler{Storage: new(MockStorage)}
+
+	large := `{"service_name":"` + strings.Repeat("s", 1<<20) + `"}`
+	req, _ := http.NewRequest("POST", "/subscriptions", strings.NewReader(large))
+	rr := httptest.NewRecorder()
+	handler.CreateSubscription(rr, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	var problem api.Problem
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+	assert.Equal(t, api.CodeBodyTooLarge, problem.Code)
+}
+
+func TestCORS(t *testing.T) {
+	cors := api.NewCORS([]string{"https://app.example.com"})
+	server := cors.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	request := func(method, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/subscriptions", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := request(http.MethodGet, "https://app.example.com")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rr.Header().Get("Access-Control-Expose-Headers"), "ETag")
+
+	// Preflight обрабатывается без передачи дальше, в том числе без аутентификации
+	rr = request(http.MethodOptions, "https://app.example.com")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Contains(t, rr.Header().Get("Access-Control-Allow-Methods"), http.MethodPut)
+	assert.Contains(t, rr.Header().Get("Access-Control-Allow-Headers"), "If-Match")
+
+	rr = request(http.MethodGet, "https://evil.example.com")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Origin", rr.Header().Get("Vary"))
+
+	rr = request(http.MethodGet, "")
+	assert.Empty(t, rr.Header().Get("Vary"))
+
+	// Список меняется без перезапуска
+	cors.SetOrigins([]string{"*"})
+	rr = request(http.MethodGet, "https://evil.example.com")
+	assert.Equal(t, "https://evil.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	cors.SetOrigins(nil)
+	rr = request(http.MethodGet, "https://app.example.com")
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestIdempotency(t *testing.T) {
+	body := `{"user_id":"` + uuid.New().String() + `","service_name":"svc1","price":100,"start_date":"2025-01-01"}`
+	calls := 0
+	newServer := func(store *MockStorage) http.Handler {
+		handler := &api.Handler{Storage: store}
+		return handler.Idempotency(time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("ETag", `"1"`)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"1"}`))
+		}))
+	}
+	request := func(server http.Handler, key, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/subscriptions", bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set(api.IdempotencyKeyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Первый запрос выполняется, ответ сохраняется
+	store := new(MockStorage)
+	var reserved *models.IdempotencyRecord
+	store.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		reserved = args.Get(1).(*models.IdempotencyRecord)
+	}).Return(nil, nil).Once()
+	store.On("CompleteIdempotencyKey", mock.Anything, mock.MatchedBy(func(rec *models.IdempotencyRecord) bool {
+		return rec.StatusCode == http.StatusCreated && rec.ETag == `"1"` && string(rec.ResponseBody) == `{"id":"1"}`
+	})).Return(nil).Once()
+
+	rr := request(newServer(store), "key-1", body)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, time.Minute, reserved.Lease)
+	store.AssertExpectations(t)
+
+	stored := *reserved
+	stored.StatusCode = http.StatusCreated
+	stored.ContentType = "application/json"
+	stored.ETag = `"1"`
+	stored.ResponseBody = []byte(`{"id":"1"}`)
+
+	t.Run("replay", func(t *testing.T) {
+		store := new(MockStorage)
+		store.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(&stored, nil).Once()
+
+		// Пробелы в JSON не делают запрос другим
+		rr := request(newServer(store), "key-1", strings.ReplaceAll(body, ",", ", "))
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, `{"id":"1"}`, rr.Body.String())
+		assert.Equal(t, "true", rr.Header().Get(api.IdempotentReplayedHeader))
+		assert.Equal(t, `"1"`, rr.Header().Get("ETag"))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("client_disconnected", func(t *testing.T) {
+		store := new(MockStorage)
+		store.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(nil, nil).Once()
+		// Ответ сохраняется с контекстом, который не отменяется вместе с запросом
+		store.On("CompleteIdempotencyKey", mock.MatchedBy(func(ctx context.Context) bool {
+			return ctx.Err() == nil
+		}), mock.Anything).Return(nil).Once()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		handler := &api.Handler{Storage: store}
+		server := handler.Idempotency(time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cancel()
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+		req := httptest.NewRequest("POST", "/subscriptions", bytes.NewBufferString(body)).WithContext(ctx)
+		req.Header.Set(api.IdempotencyKeyHeader, "key-3")
+		server.ServeHTTP(httptest.NewRecorder(), req)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("different_body", func(t *testing.T) {
+		store := new(MockStorage)
+		store.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(&stored, nil).Once()
+
+		rr := request(newServer(store), "key-1", strings.Replace(body, "100", "200", 1))
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		var problem api.Problem
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+		assert.Equal(t, api.CodeIdempotencyKeyReused, problem.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("in_progress", func(t *testing.T) {
+		inProgress := stored
+		inProgress.StatusCode, inProgress.ContentType, inProgress.ResponseBody = 0, "", nil
+		store := new(MockStorage)
+		store.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(&inProgress, nil).Once()
+
+		rr := request(newServer(store), "key-1", body)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		var problem api.Problem
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+		assert.Equal(t, api.CodeIdempotencyInProgress, problem.Code)
+	})
+
+	t.Run("server_error_releases_key", func(t *testing.T) {
+		store := new(MockStorage)
+		store.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(nil, nil).Once()
+		store.On("ReleaseIdempotencyKey", mock.Anything, mock.Anything).Return(nil).Once()
+		handler := &api.Handler{Storage: store}
+		server := handler.Idempotency(time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+
+		rr := request(server, "key-2", body)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("without_key", func(t *testing.T) {
+		store := new(MockStorage)
+		rr := request(newServer(store), "", body)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		store.AssertNotCalled(t, "ReserveIdempotencyKey", mock.Anything, mock.Anything)
+	})
+
+	t.Run("key_too_long", func(t *testing.T) {
+		rr := request(newServer(new(MockStorage)), strings.Repeat("k", 256), body)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("body_too_large", func(t *testing.T) {
+		store := new(MockStorage)
+		large := `{"service_name":"` + strings.Repeat("s", 1<<20) + `"}`
+		rr := request(newServer(store), "key-4", large)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+		var problem api.Problem
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+		assert.Equal(t, api.CodeBodyTooLarge, problem.Code)
+		store.AssertNotCalled(t, "ReserveIdempotencyKey", mock.Anything, mock.Anything)
+	})
+}
+
+func TestSubscriptionETag(t *testing.T) {
+	subID := uuid.New()
+	current := func() *models.Subscription {
+		return &models.Subscription{
+			ID:          subID,
+			UserID:      uuid.New(),
+			ServiceName: "svc",
+			Price:       100,
+			StartDate:   models.DataOnly(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)),
+			Tags:        []string{"video"},
+			Version:     3,
+		}
+	}
+	request := func(method, body string, headers map[string]string) *http.Request {
+		req, _ := http.NewRequest(method, "/subscriptions/"+subID.String(), bytes.NewBufferString(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", subID.String())
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+	putBody := `{"service_name":"svc","price":200,"start_date":"2025-01-01"}`
+
+	t.Run("get", func(t *testing.T) {
+		mockStore := new(MockStorage)
+		handler := &api.Handler{Storage: mockStore}
+		mockStore.On("GetSubscriptionByID", mock.Anything, subID).Return(current(), nil).Twice()
+
+		rr := httptest.NewRecorder()
+		handler.GetSubscription(rr, request("GET", "", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `"3"`, rr.Header().Get("ETag"))
+
+		rr = httptest.NewRecorder()
+		handler.GetSubscription(rr, request("GET", "", map[string]string{"If-None-Match": `W/"3"`}))
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+		assert.Empty(t, rr.Body.String())
+	})
+
+	t.Run("put_if_match", func(t *testing.T) {
+		mockStore := new(MockStorage)
+		handler := &api.Handler{Storage: mockStore}
+		mockStore.On("UpdateSubscription", mock.Anything, mock.MatchedBy(func(sub *models.Subscription) bool {
+			return sub.Version == 3
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*models.Subscription).Version = 4
+		}).Return(nil).Once()
+
+		rr := httptest.NewRecorder()
+		handler.UpdateSubscription(rr, request("PUT", putBody, map[string]string{"If-Match": `"3"`}))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `"4"`, rr.Header().Get("ETag"))
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("put_stale_version", func(t *testing.T) {
+		mockStore := new(MockStorage)
+		handler := &api.Handler{Storage: mockStore}
+		mockStore.On("UpdateSubscription", mock.Anything, mock.Anything).Return(storage.ErrVersionMismatch).Once()
+
+		rr := httptest.NewRecorder()
+		handler.UpdateSubscription(rr, request("PUT", putBody, map[string]string{"If-Match": `"2"`}))
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+		var problem api.Problem
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+		assert.Equal(t, api.CodePreconditionFailed, problem.Code)
+	})
+
+	t.Run("invalid_if_match", func(t *testing.T) {
+		handler := &api.Handler{Storage: new(MockStorage)}
+		for value, status := range map[string]int{
+			`W/"3"`:   http.StatusPreconditionFailed,
+			`3`:       http.StatusPreconditionFailed,
+			`"3","4"`: http.StatusBadRequest,
+		} {
+			rr := httptest.NewRecorder()
+			handler.UpdateSubscription(rr, request("PUT", putBody, map[string]string{"If-Match": value}))
+			assert.Equal(t, status, rr.Code, value)
+		}
+	})
+
+	t.Run("require_if_match", func(t *testing.T) {
+		mockStore := new(MockStorage)
+		handler := &api.Handler{Storage: mockStore, RequireIfMatch: true}
+
+		rr := httptest.NewRecorder()
+		handler.DeleteSubscription(rr, request("DELETE", "", nil))
+		assert.Equal(t, http.StatusPreconditionRequired, rr.Code)
+
+		mockStore.On("DeleteSubscription", mock.Anything, subID, 3).Return(nil).Once()
+		rr = httptest.NewRecorder()
+		handler.DeleteSubscription(rr, request("DELETE", "", map[string]string{"If-Match": `"3"`}))
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("patch", func(t *testing.T) {
+		mockStore := new(MockStorage)
+		handler := &api.Handler{Storage: mockStore}
+		mockStore.On("GetSubscriptionByID", mock.Anything, subID).Return(current(), nil)
+		mockStore.On("UpdateSubscription", mock.Anything, mock.MatchedBy(func(sub *models.Subscription) bool {
+			return sub.Price == 250 && sub.ServiceName == "svc" && sub.Tags == nil && sub.Version == 3
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*models.Subscription).Version = 4
+		}).Return(nil).Once()
+
+		rr := httptest.NewRecorder()
+		handler.PatchSubscription(rr, request("PATCH", `{"price":250,"tags":null}`, nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, `"4"`, rr.Header().Get("ETag"))
+
+		// Устаревшая версия отклоняется до записи
+		rr = httptest.NewRecorder()
+		handler.PatchSubscription(rr, request("PATCH", `{"price":300}`, map[string]string{"If-Match": `"2"`}))
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+		rr = httptest.NewRecorder()
+		handler.PatchSubscription(rr, request("PATCH", `{"unknown":1}`, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+		rr = httptest.NewRecorder()
+		handler.PatchSubscription(rr, request("PATCH", `[]`, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockStore.AssertExpectations(t)
+	})
+}
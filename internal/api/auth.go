@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"subscribe_aggregation-main/internal/auth"
+	"subscribe_aggregation-main/internal/storage"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyHeader — заголовок с API ключом сервиса
+const APIKeyHeader = "X-API-Key"
+
+// AuthMiddleware проверяет API ключ или Bearer токен и кладет пользователя в контекст запроса
+func (h *Handler) AuthMiddleware(verifier *auth.Verifier) func(http.Handler) http.Handler {
+	authenticator := &auth.Authenticator{Verifier: verifier, Keys: h.Storage}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticator.Authenticate(r.Context(), r.Header.Get("Authorization"), r.Header.Get(APIKeyHeader))
+			if err != nil {
+				writeAuthError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), principal)))
+		})
+	}
+}
+
+// writeAuthError отвечает 401 на неверные учетные данные и 500 на ошибку хранилища ключей
+func writeAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := logging.GetLogger()
+
+	switch {
+	case errors.Is(err, auth.ErrNoCredentials):
+		writeUnauthorized(w, r, err.Error())
+	case errors.Is(err, auth.ErrInvalidToken):
+		logger.InfoContext(r.Context(), "AuthMiddleware: token rejected", slog.String("error", err.Error()))
+		writeUnauthorized(w, r, "invalid or expired token")
+	case errors.Is(err, storage.ErrNotFound):
+		logger.InfoContext(r.Context(), "AuthMiddleware: API key rejected")
+		writeUnauthorized(w, r, "invalid or revoked API key")
+	default:
+		writeStorageError(w, r, "AuthMiddleware", "API key", err)
+	}
+}
+
+// RequireRole пропускает только пользователей с указанной ролью.
+// Без аутентификации запрос пропускается, как и остальные проверки доступа.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if principal := auth.FromContext(r.Context()); principal != nil && !principal.HasRole(role) {
+				writeProblem(w, r, http.StatusForbidden, CodeForbidden, "role "+role+" required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScope пропускает только API ключи с указанным правом.
+// Пользователи с JWT и запросы без аутентификации не ограничиваются.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if principal := auth.FromContext(r.Context()); principal != nil && !principal.HasScope(scope) {
+				writeProblem(w, r, http.StatusForbidden, CodeForbidden, "scope "+scope+" required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeUnauthorized(w http.ResponseWriter, r *http.Request, detail string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="subscriptions"`)
+	writeProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, detail)
+}
+
+// scopeUserID возвращает user_id, которым ограничен список или сумма подписок (см. auth.ScopeUserID).
+// На чужой user_id отвечает 403 и возвращает false.
+func scopeUserID(w http.ResponseWriter, r *http.Request, requested string) (string, bool) {
+	userID, err := auth.ScopeUserID(auth.FromContext(r.Context()), requested)
+	if err != nil {
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, err.Error(),
+			FieldError{Field: "user_id", Code: FieldInvalid, Message: "user_id must match the authenticated user"})
+		return "", false
+	}
+	return userID, true
+}
+
+// authorizeSubscription загружает подписку и проверяет доступ к ней.
+// Чужая подписка выглядит как несуществующая, чтобы не раскрывать ее наличие.
+func (h *Handler) authorizeSubscription(w http.ResponseWriter, r *http.Request, op string, id uuid.UUID, write bool) bool {
+	principal := auth.FromContext(r.Context())
+	if principal == nil || principal.AllUsers() {
+		return true
+	}
+
+	sub, err := h.Storage.GetSubscriptionByID(r.Context(), id)
+	if err != nil {
+		writeStorageError(w, r, op, "subscription", err)
+		return false
+	}
+	if !auth.CanAccessSubscription(principal, sub, write) {
+		writeProblem(w, r, http.StatusNotFound, CodeNotFound, "subscription not found")
+		return false
+	}
+	return true
+}
+
+// requestClient возвращает клиента, от имени которого выполняется запрос:
+// API ключ, пользователь из токена или IP адрес для запросов без аутентификации
+func requestClient(r *http.Request) string {
+	if principal := auth.FromContext(r.Context()); principal != nil {
+		return principal.ClientKey()
+	}
+	return ipClient(r)
+}
+
+// ipClient возвращает IP адрес, с которого пришел запрос
+func ipClient(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
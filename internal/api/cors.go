@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"subscribe_aggregation-main/pkg/logging"
+	"sync/atomic"
+)
+
+// corsMaxAge — сколько секунд браузер может не повторять preflight запрос
+const corsMaxAge = "600"
+
+var (
+	corsAllowedMethods = strings.Join([]string{
+		http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+	}, ", ")
+	corsAllowedHeaders = strings.Join([]string{
+		"Authorization", "Content-Type", APIKeyHeader, TenantHeader, "If-Match",
+		IdempotencyKeyHeader, logging.RequestIDHeader, logging.TraceparentHeader,
+	}, ", ")
+	// corsExposedHeaders — заголовки ответа, которые скрипт в браузере может прочитать
+	corsExposedHeaders = strings.Join([]string{
+		"ETag", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset",
+		IdempotentReplayedHeader, logging.RequestIDHeader,
+	}, ", ")
+)
+
+// CORS разрешает запросы из браузера со страниц с origin из списка. "*" разрешает любой origin.
+// Пустой список запрещает запросы с других origin, как без CORS.
+// Список меняется без перезапуска через SetOrigins.
+type CORS struct {
+	origins atomic.Pointer[[]string]
+}
+
+// NewCORS создает CORS со списком разрешенных origin
+func NewCORS(origins []string) *CORS {
+	c := &CORS{}
+	c.SetOrigins(origins)
+	return c
+}
+
+// SetOrigins заменяет список разрешенных origin, например при перезагрузке конфигурации
+func (c *CORS) SetOrigins(origins []string) {
+	origins = slices.Clone(origins)
+	c.origins.Store(&origins)
+}
+
+// allowed проверяет, разрешен ли origin
+func (c *CORS) allowed(origin string) bool {
+	origins := *c.origins.Load()
+	return slices.Contains(origins, "*") || slices.Contains(origins, origin)
+}
+
+// Middleware добавляет заголовки CORS к ответам на запросы с разрешенного origin и сам
+// отвечает на preflight запросы, поэтому должен стоять до аутентификации
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Ответ зависит от Origin, даже если origin не разрешен
+		w.Header().Add("Vary", "Origin")
+		if !c.allowed(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
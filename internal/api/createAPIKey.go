@@ -0,0 +1,65 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"subscribe_aggregation-main/internal/auth"
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/validation"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyRequest — параметры выпускаемого API ключа
+type APIKeyRequest struct {
+	Name   string   `json:"name" example:"billing-export"`
+	Scopes []string `json:"scopes" example:"subscriptions:read,reports:read"`
+}
+
+// CreateAPIKey godoc
+// @Summary      Issue an API key
+// @Description  Выпускает API ключ для сервиса. Ключ возвращается в поле key только в этом ответе, сохраните его
+// @Tags         api-keys
+// @Accept       json
+// @Produce      json
+// @Param        key  body      APIKeyRequest  true  "Key name and scopes"
+// @Success      201  {object}  models.APIKey
+// @Failure      400  {object}  Problem "Invalid request payload"
+// @Failure      403  {object}  Problem "Admin role required"
+// @Failure      500  {object}  Problem "Internal server error"
+// @Security     BearerAuth
+// @Router       /api-keys [post]
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLogger()
+
+	var req APIKeyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	apiKey := models.APIKey{ID: uuid.New(), Name: req.Name, Scopes: req.Scopes}
+	if err := validation.APIKey(&apiKey); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	key, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		logger.ErrorContext(r.Context(), "CreateAPIKey: failed to generate key", slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "internal server error")
+		return
+	}
+	apiKey.Prefix, apiKey.Hash = prefix, hash
+
+	if err := h.Storage.CreateAPIKey(r.Context(), &apiKey); err != nil {
+		writeStorageError(w, r, "CreateAPIKey", "API key", err)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "CreateAPIKey: API key issued",
+		slog.String("api_key_id", apiKey.ID.String()),
+		slog.String("prefix", apiKey.Prefix))
+	apiKey.Key = key
+	writeJSON(w, http.StatusCreated, apiKey)
+}
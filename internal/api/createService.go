@@ -0,0 +1,49 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/validation"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/google/uuid"
+)
+
+// CreateService godoc
+// @Summary      Create a catalog service
+// @Description  Добавляет сервис в каталог. Подписки с совпадающими алиасами переводятся на каноническое название
+// @Tags         services
+// @Accept       json
+// @Produce      json
+// @Param        service  body      models.Service  true  "Service data"
+// @Success      201  {object}  models.Service
+// @Failure      400  {object}  Problem "Invalid request payload"
+// @Failure      409  {object}  Problem "Alias belongs to another service"
+// @Failure      500  {object}  Problem "Internal server error"
+// @Security     BearerAuth
+// @Router       /services [post]
+func (h *Handler) CreateService(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLogger()
+
+	var svc models.Service
+	if !decodeJSON(w, r, &svc) {
+		return
+	}
+
+	// Генерация ID
+	svc.ID = uuid.New()
+
+	if err := validation.Service(&svc); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	if err := h.Storage.CreateService(r.Context(), &svc); err != nil {
+		writeStorageError(w, r, "CreateService", "service", err)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "CreateService: service created", slog.String("service_id", svc.ID.String()))
+	writeJSON(w, http.StatusCreated, svc)
+}
@@ -1,46 +1,62 @@
 package api
 
 import (
-	"encoding/json"
 	"net/http"
+	"subscribe_aggregation-main/internal/auth"
 	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/validation"
 
 	"github.com/google/uuid"
 )
 
 // CreateSubscription godoc
 // @Summary      Create a new subscription
-// @Description  Создает новую подписку с уникальным UUID
+// @Description  Создает новую подписку с уникальным UUID. Для совместной подписки передаются members и split_mode (equal или custom), для пробных периодов — phases
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
-// @Param        subscription  body      models.Subscription  true  "Subscription data"
+// @Param        subscription     body      models.Subscription  true   "Subscription data"
+// @Param        Idempotency-Key  header    string               false  "Ключ повтора: ответ на первый запрос с этим ключом возвращается повторно"
 // @Success      201  {object}  models.Subscription
-// @Failure      400  {string}  string "Invalid request payload"
-// @Failure      500  {string}  string "Internal server error"
+// @Failure      400  {object}  Problem "Invalid request payload or members"
+// @Failure      409  {object}  Problem "Idempotency key reused with a different body or still in progress"
+// @Failure      500  {object}  Problem "Internal server error"
+// @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Router       /subscriptions [post]
 func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
 	var sub models.Subscription
-	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+	if !decodeJSON(w, r, &sub) {
 		return
 	}
 
 	// Генерация ID
 	sub.ID = uuid.New()
 
-	// Валидация обязательных полей
-	if sub.ServiceName == "" || sub.Price <= 0 || sub.UserID == uuid.Nil {
-		http.Error(w, "missing required fields", http.StatusBadRequest)
+	// Обычный пользователь создает подписки только на себя
+	if principal := auth.FromContext(r.Context()); principal != nil && !principal.AllUsers() {
+		if sub.UserID == uuid.Nil {
+			sub.UserID = principal.UserID
+		}
+		if sub.UserID != principal.UserID {
+			writeProblem(w, r, http.StatusForbidden, CodeForbidden, "subscriptions can be created only for the authenticated user",
+				FieldError{Field: "user_id", Code: FieldInvalid, Message: "user_id must match the authenticated user"})
+			return
+		}
+	}
+
+	// Валидация полей
+	if err := validation.NewSubscription(&sub); err != nil {
+		writeValidationError(w, r, err)
 		return
 	}
 
 	// Сохранение подписки
 	if err := h.Storage.CreateSubscription(r.Context(), &sub); err != nil {
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		writeStorageError(w, r, "CreateSubscription", "subscription", err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(sub)
+	w.Header().Set("ETag", subscriptionETag(sub.Version))
+	writeJSON(w, http.StatusCreated, sub)
 }
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// DeleteService godoc
+// @Summary Delete catalog service by ID
+// @Description Удаляет сервис из каталога, подписки сохраняют текущее название
+// @Tags services
+// @Param id path string true "Service ID UUID"
+// @Success 204 "No content"
+// @Failure 400 {object} Problem "Invalid UUID"
+// @Failure 404 {object} Problem "Service not found"
+// @Failure 500 {object} Problem "Server error"
+// @Security BearerAuth
+// @Router /services/{id} [delete]
+func (h *Handler) DeleteService(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLogger()
+	idStr := chi.URLParam(r, "id")
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "DeleteService: invalid UUID", slog.String("uuid", idStr), slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid UUID", FieldError{Field: "id", Code: FieldInvalid, Message: "id must be a UUID"})
+		return
+	}
+
+	err = h.Storage.DeleteService(r.Context(), id)
+	if err != nil {
+		writeStorageError(w, r, "DeleteService", "service", err)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "DeleteService: service deleted", slog.String("service_id", id.String()))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,8 +1,6 @@
 package api
 
 import (
-	"database/sql"
-	"errors"
 	"log/slog"
 	"net/http"
 	"subscribe_aggregation-main/pkg/logging"
@@ -15,10 +13,15 @@ import (
 // @Summary Delete subscription by ID
 // @Tags subscriptions
 // @Param id path string true "Subscription ID UUID"
+// @Param If-Match header string false "ETag подписки: удаление выполняется, только если подписка не изменилась"
 // @Success 204 "No content"
-// @Failure 400 {string} string "Invalid UUID"
-// @Failure 404 {string} string "Subscription not found"
-// @Failure 500 {string} string "Server error"
+// @Failure 400 {object} Problem "Invalid UUID"
+// @Failure 404 {object} Problem "Subscription not found"
+// @Failure 412 {object} Problem "Subscription was modified"
+// @Failure 428 {object} Problem "If-Match is required"
+// @Failure 500 {object} Problem "Server error"
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /subscriptions/{id} [delete]
 func (h *Handler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
 	logger := logging.GetLogger()
@@ -26,23 +29,26 @@ func (h *Handler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
 
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		logger.Error("DeleteSubscription: invalid UUID", slog.String("uuid", idStr), slog.String("error", err.Error()))
-		http.Error(w, "invalid UUID", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "DeleteSubscription: invalid UUID", slog.String("uuid", idStr), slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid UUID", FieldError{Field: "id", Code: FieldInvalid, Message: "id must be a UUID"})
 		return
 	}
 
-	err = h.Storage.DeleteSubscription(r.Context(), id)
+	version, ok := h.ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	if !h.authorizeSubscription(w, r, "DeleteSubscription", id, true) {
+		return
+	}
+
+	err = h.Storage.DeleteSubscription(r.Context(), id, version)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			logger.Info("DeleteSubscription: subscription not found", slog.String("subscription_id", id.String()))
-			http.Error(w, "subscription not found", http.StatusNotFound)
-			return
-		}
-		logger.Error("DeleteSubscription: failed to delete subscription", slog.String("subscription_id", id.String()), slog.String("error", err.Error()))
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		writeStorageError(w, r, "DeleteSubscription", "subscription", err)
 		return
 	}
 
-	logger.Info("DeleteSubscription: subscription deleted", slog.String("subscription_id", id.String()))
+	logger.InfoContext(r.Context(), "DeleteSubscription: subscription deleted", slog.String("subscription_id", id.String()))
 	w.WriteHeader(http.StatusNoContent)
 }
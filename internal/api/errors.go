@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"subscribe_aggregation-main/internal/storage"
+	"subscribe_aggregation-main/pkg/logging"
+)
+
+// Problem — описание ошибки в формате RFC 7807 (application/problem+json)
+type Problem struct {
+	Type      string       `json:"type" example:"/problems/not_found"`
+	Title     string       `json:"title" example:"Not Found"`
+	Status    int          `json:"status" example:"404"`
+	Detail    string       `json:"detail,omitempty" example:"subscription not found"`
+	Instance  string       `json:"instance,omitempty" example:"/subscriptions/2f1c8e0a-7d7b-4c5e-9a53-0c6f2b7f4e11"`
+	Code      string       `json:"code" example:"not_found"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError — ошибка валидации отдельного поля запроса
+type FieldError struct {
+	Field   string `json:"field" example:"price"`
+	Code    string `json:"code" example:"required"`
+	Message string `json:"message" example:"price must be positive"`
+}
+
+// Стабильные коды ошибок. Клиенты сравнивают их, а не текст detail
+const (
+	CodeInvalidBody           = "invalid_body"
+	CodeBodyTooLarge          = "body_too_large"
+	CodeInvalidParameter      = "invalid_parameter"
+	CodeValidationFailed      = "validation_failed"
+	CodeNotFound              = "not_found"
+	CodeConflict              = "conflict"
+	CodeServiceAliasTaken     = "service_alias_taken"
+	CodeAlreadyPaused         = "subscription_already_paused"
+	CodeNotPaused             = "subscription_not_paused"
+	CodeInvalidPauseDate      = "invalid_pause_date"
+	CodeInvalidSplit          = "invalid_split"
+	CodeInvalidPhases         = "invalid_phases"
+	CodeUnauthorized          = "unauthorized"
+	CodeForbidden             = "forbidden"
+	CodeRateLimited           = "rate_limited"
+	CodeIdempotencyKeyReused  = "idempotency_key_reused"
+	CodeIdempotencyInProgress = "idempotency_request_in_progress"
+	CodePreconditionFailed    = "precondition_failed"
+	CodePreconditionRequired  = "precondition_required"
+	CodeInternal              = "internal_error"
+)
+
+// Коды ошибок отдельных полей
+const (
+	FieldRequired = "required"
+	FieldInvalid  = "invalid"
+)
+
+const problemContentType = "application/problem+json"
+
+// problemTypeBase — префикс URI типа ошибки, к нему добавляется код
+const problemTypeBase = "/problems/"
+
+// storageErrors сопоставляет ошибки хранилища с HTTP статусами и кодами.
+// Конкретные ошибки проверяются раньше общих видов, к которым они относятся.
+var storageErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{storage.ErrOwnerChanged, http.StatusBadRequest, CodeValidationFailed},
+	{storage.ErrServiceAliasTaken, http.StatusConflict, CodeServiceAliasTaken},
+	{storage.ErrAlreadyPaused, http.StatusConflict, CodeAlreadyPaused},
+	{storage.ErrNotPaused, http.StatusConflict, CodeNotPaused},
+	{storage.ErrInvalidPauseDate, http.StatusBadRequest, CodeInvalidPauseDate},
+	{storage.ErrInvalidSplit, http.StatusBadRequest, CodeInvalidSplit},
+	{storage.ErrInvalidPhases, http.StatusBadRequest, CodeInvalidPhases},
+	{storage.ErrVersionMismatch, http.StatusPreconditionFailed, CodePreconditionFailed},
+	{storage.ErrNotFound, http.StatusNotFound, CodeNotFound},
+	{storage.ErrConflict, http.StatusConflict, CodeConflict},
+	{storage.ErrInvalidInput, http.StatusBadRequest, CodeValidationFailed},
+}
+
+// storageErrorFields — поля запроса, к которым относятся ошибки хранилища
+var storageErrorFields = map[error]string{
+	storage.ErrOwnerChanged: "user_id",
+}
+
+// writeJSON отправляет ответ в формате JSON с указанным статусом
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeProblem отправляет ошибку в формате application/problem+json
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string, fields ...FieldError) {
+	problem := Problem{
+		Type:      problemTypeBase + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: logging.RequestIDFromContext(r.Context()),
+		Errors:    fields,
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// writeStorageError преобразует ошибку хранилища в problem+json ответ.
+// resource используется в тексте ошибки для ненайденной записи.
+// Неизвестные ошибки логируются, а клиент получает 500 без подробностей.
+func writeStorageError(w http.ResponseWriter, r *http.Request, op, resource string, err error) {
+	logger := logging.GetLogger()
+
+	for _, known := range storageErrors {
+		if !errors.Is(err, known.err) {
+			continue
+		}
+		detail := err.Error()
+		if err == storage.ErrNotFound {
+			detail = resource + " not found"
+		}
+		logger.InfoContext(r.Context(), op+": "+detail, slog.String("code", known.code))
+		if field, ok := storageErrorFields[known.err]; ok {
+			writeProblem(w, r, known.status, known.code, detail,
+				FieldError{Field: field, Code: FieldInvalid, Message: detail})
+			return
+		}
+		writeProblem(w, r, known.status, known.code, detail)
+		return
+	}
+
+	logger.ErrorContext(r.Context(), op+": internal error", slog.String("error", err.Error()))
+	writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "internal server error")
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// subscriptionETag возвращает сильный ETag для версии подписки
+func subscriptionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatchVersion читает версию из заголовка If-Match.
+// Возвращает 0, если заголовка нет или передан "*": тогда изменение не проверяет версию.
+// Без заголовка при h.RequireIfMatch отвечает 428, на тег, который не может совпасть
+// ни с одной версией (слабый или не число), — 412. В этих случаях возвращает false.
+func (h *Handler) ifMatchVersion(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" {
+		if h.RequireIfMatch {
+			writeProblem(w, r, http.StatusPreconditionRequired, CodePreconditionRequired, "If-Match header with the subscription ETag is required")
+			return 0, false
+		}
+		return 0, true
+	}
+	if value == "*" {
+		return 0, true
+	}
+
+	// Поддерживается один тег: версия у подписки одна, список тегов на практике не нужен
+	if strings.Contains(value, ",") {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid If-Match",
+			FieldError{Field: "If-Match", Code: FieldInvalid, Message: "If-Match must contain a single entity tag"})
+		return 0, false
+	}
+
+	unquoted, ok := strings.CutPrefix(value, `"`)
+	if ok {
+		unquoted, ok = strings.CutSuffix(unquoted, `"`)
+	}
+	version, err := strconv.Atoi(unquoted)
+	if !ok || err != nil || version <= 0 {
+		writeProblem(w, r, http.StatusPreconditionFailed, CodePreconditionFailed, "If-Match does not match the current subscription version")
+		return 0, false
+	}
+	return version, true
+}
+
+// etagMatches проверяет, совпадает ли заголовок If-None-Match с etag.
+// Для If-None-Match используется слабое сравнение, поэтому префикс W/ не учитывается.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// GetService godoc
+// @Summary      Get catalog service by ID
+// @Tags         services
+// @Produce      json
+// @Param        id   path      string  true  "Service ID (UUID)"
+// @Success      200  {object}  models.Service
+// @Failure      400  {object}  Problem "Invalid UUID"
+// @Failure      404  {object}  Problem "Service not found"
+// @Failure      500  {object}  Problem "Internal server error"
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Router       /services/{id} [get]
+func (h *Handler) GetService(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLogger()
+	idStr := chi.URLParam(r, "id")
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "GetService: invalid UUID", slog.String("uuid", idStr), slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid UUID", FieldError{Field: "id", Code: FieldInvalid, Message: "id must be a UUID"})
+		return
+	}
+
+	svc, err := h.Storage.GetServiceByID(r.Context(), id)
+	if err != nil {
+		writeStorageError(w, r, "GetService", "service", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, svc)
+}
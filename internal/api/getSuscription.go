@@ -1,9 +1,9 @@
 package api
 
 import (
-	"encoding/json"
 	"log/slog"
 	"net/http"
+	"subscribe_aggregation-main/internal/auth"
 	"subscribe_aggregation-main/pkg/logging"
 
 	"github.com/go-chi/chi/v5"
@@ -12,15 +12,20 @@ import (
 
 // GetSubscription godoc
 // @Summary      Get subscription by ID
-// @Description  Получить подписку по её уникальному UUID
+// @Description  Получить подписку по её уникальному UUID. Заголовок ETag содержит версию подписки для If-Match
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
-// @Param        id   path      string  true  "Subscription ID (UUID)"
+// @Param        id             path      string  true   "Subscription ID (UUID)"
+// @Param        If-None-Match  header    string  false  "ETag известной клиенту версии"
 // @Success      200  {object}  models.Subscription
-// @Failure      400  {string}  string "Invalid UUID"
-// @Failure      404  {string}  string "Subscription not found"
-// @Failure      500  {string}  string "Internal server error"
+// @Header       200  {string}  ETag  "Версия подписки"
+// @Success      304  "Subscription not modified"
+// @Failure      400  {object}  Problem "Invalid UUID"
+// @Failure      404  {object}  Problem "Subscription not found"
+// @Failure      500  {object}  Problem "Internal server error"
+// @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Router       /subscriptions/{id} [get]
 func (h *Handler) GetSubscription(w http.ResponseWriter, r *http.Request) {
 	logger := logging.GetLogger()
@@ -28,24 +33,30 @@ func (h *Handler) GetSubscription(w http.ResponseWriter, r *http.Request) {
 
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		logger.Error("GetSubscription: invalid UUID", slog.String("uuid", idStr), slog.String("error", err.Error()))
-		http.Error(w, "invalid UUID", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "GetSubscription: invalid UUID", slog.String("uuid", idStr), slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid UUID", FieldError{Field: "id", Code: FieldInvalid, Message: "id must be a UUID"})
 		return
 	}
 
 	sub, err := h.Storage.GetSubscriptionByID(r.Context(), id)
 	if err != nil {
-		logger.Error("GetSubscription: internal error", slog.String("error", err.Error()))
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		writeStorageError(w, r, "GetSubscription", "subscription", err)
 		return
 	}
 
-	if sub == nil {
-		logger.Info("GetSubscription: subscription not found", slog.String("subscription_id", id.String()))
-		http.Error(w, "subscription not found", http.StatusNotFound)
+	// Чужая подписка выглядит как несуществующая
+	if !auth.CanAccessSubscription(auth.FromContext(r.Context()), sub, false) {
+		writeProblem(w, r, http.StatusNotFound, CodeNotFound, "subscription not found")
 		return
 	}
 
-	logger.Info("GetSubscription: subscription retrieved", slog.String("subscription_id", id.String()))
-	json.NewEncoder(w).Encode(sub)
+	etag := subscriptionETag(sub.Version)
+	w.Header().Set("ETag", etag)
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "GetSubscription: subscription retrieved", slog.String("subscription_id", id.String()))
+	writeJSON(w, http.StatusOK, sub)
 }
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/pkg/logging"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// IdempotencyKeyHeader — заголовок с ключом, по которому повторный запрос получает первый ответ
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader отмечает ответ, повторенный из сохраненного
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength совпадает с размером колонки idempotency_keys.key
+	maxIdempotencyKeyLength = 255
+	// idempotencyStoreTimeout ограничивает сохранение ответа, которое продолжается
+	// и после отключения клиента
+	idempotencyStoreTimeout = 5 * time.Second
+)
+
+// Idempotency сохраняет первый ответ на запрос с заголовком Idempotency-Key на время ttl
+// и возвращает его на повторы с тем же ключом и телом. Повтор с другим телом или
+// во время выполнения первого запроса получает 409. Ответы 5xx не сохраняются,
+// чтобы клиент мог повторить запрос после временной ошибки. Если первый запрос
+// не сохранил ответ за lease, например из-за падения сервера, ключ занимает повтор.
+func (h *Handler) Idempotency(ttl, lease time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if strings.TrimSpace(key) == "" || utf8.RuneCountInString(key) > maxIdempotencyKeyLength {
+				writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid "+IdempotencyKeyHeader,
+					FieldError{Field: IdempotencyKeyHeader, Code: FieldInvalid, Message: "idempotency key must be 1 to 255 characters"})
+				return
+			}
+
+			body, err := io.ReadAll(limitBody(w, r))
+			if err != nil {
+				if writeBodyTooLarge(w, r, err) {
+					return
+				}
+				writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "failed to read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// Postgres хранит время с точностью до микросекунд, а Complete и Release
+			// находят резервацию по created_at
+			now := time.Now().UTC().Truncate(time.Microsecond)
+			rec := &models.IdempotencyRecord{
+				Client:      requestClient(r),
+				Key:         key,
+				RequestHash: requestHash(r, body),
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(ttl),
+				Lease:       lease,
+			}
+
+			existing, err := h.Storage.ReserveIdempotencyKey(r.Context(), rec)
+			if err != nil {
+				writeStorageError(w, r, "Idempotency", "idempotency key", err)
+				return
+			}
+			if existing != nil {
+				replayIdempotent(w, r, rec, existing)
+				return
+			}
+
+			recorder := &recordingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			// Ответ сохраняется, даже если клиент уже отключился: иначе ключ остался бы
+			// занятым и повторы получали бы 409 до истечения lease
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), idempotencyStoreTimeout)
+			defer cancel()
+
+			logger := logging.GetLogger()
+			if recorder.statusCode >= http.StatusInternalServerError {
+				if err := h.Storage.ReleaseIdempotencyKey(ctx, rec); err != nil {
+					logger.ErrorContext(r.Context(), "Idempotency: failed to release key", slog.String("error", err.Error()))
+				}
+				return
+			}
+
+			rec.StatusCode = recorder.statusCode
+			rec.ContentType = recorder.Header().Get("Content-Type")
+			rec.ETag = recorder.Header().Get("ETag")
+			rec.ResponseBody = recorder.body.Bytes()
+			if err := h.Storage.CompleteIdempotencyKey(ctx, rec); err != nil {
+				logger.ErrorContext(r.Context(), "Idempotency: failed to store response", slog.String("error", err.Error()))
+			}
+		})
+	}
+}
+
+// replayIdempotent отвечает на повтор запроса сохраненным ответом
+func replayIdempotent(w http.ResponseWriter, r *http.Request, rec, existing *models.IdempotencyRecord) {
+	if existing.RequestHash != rec.RequestHash {
+		writeProblem(w, r, http.StatusConflict, CodeIdempotencyKeyReused, "idempotency key was already used with a different request")
+		return
+	}
+	if existing.StatusCode == 0 {
+		w.Header().Set("Retry-After", "1")
+		writeProblem(w, r, http.StatusConflict, CodeIdempotencyInProgress, "a request with this idempotency key is still in progress")
+		return
+	}
+
+	logging.GetLogger().InfoContext(r.Context(), "Idempotency: replaying stored response",
+		slog.Int("status", existing.StatusCode))
+	if existing.ContentType != "" {
+		w.Header().Set("Content-Type", existing.ContentType)
+	}
+	if existing.ETag != "" {
+		w.Header().Set("ETag", existing.ETag)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(existing.StatusCode)
+	w.Write(existing.ResponseBody)
+}
+
+// requestHash отличает запросы с одним ключом. JSON сравнивается без учета пробелов
+func requestHash(r *http.Request, body []byte) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, body); err == nil {
+		body = compact.Bytes()
+	}
+
+	sum := sha256.New()
+	sum.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// recordingResponseWriter передает ответ клиенту и сохраняет его копию
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rw *recordingResponseWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingResponseWriter) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"subscribe_aggregation-main/pkg/logging"
+)
+
+// ListAPIKeys godoc
+// @Summary      List API keys
+// @Description  Возвращает выпущенные ключи с префиксом, правами и временем последнего использования. Сами ключи не возвращаются
+// @Tags         api-keys
+// @Produce      json
+// @Success      200  {array}   models.APIKey
+// @Failure      403  {object}  Problem "Admin role required"
+// @Failure      500  {object}  Problem "Internal server error"
+// @Security     BearerAuth
+// @Router       /api-keys [get]
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLogger()
+
+	keys, err := h.Storage.ListAPIKeys(r.Context())
+	if err != nil {
+		logger.ErrorContext(r.Context(), "ListAPIKeys: failed to list API keys", slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "internal server error")
+		return
+	}
+
+	logger.InfoContext(r.Context(), "ListAPIKeys: retrieved API keys", slog.Int("count", len(keys)))
+	writeJSON(w, http.StatusOK, keys)
+}
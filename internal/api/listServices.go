@@ -0,0 +1,31 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"subscribe_aggregation-main/pkg/logging"
+)
+
+// ListServices godoc
+// @Summary      List catalog services
+// @Tags         services
+// @Produce      json
+// @Param        category  query  string  false  "Filter by category"
+// @Success      200  {array}   models.Service
+// @Failure      500  {object}  Problem "Internal server error"
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Router       /services [get]
+func (h *Handler) ListServices(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLogger()
+
+	services, err := h.Storage.ListServices(r.Context(), r.URL.Query().Get("category"))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "ListServices: failed to list services", slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "internal server error")
+		return
+	}
+
+	logger.InfoContext(r.Context(), "ListServices: retrieved services", slog.Int("count", len(services)))
+	writeJSON(w, http.StatusOK, services)
+}
@@ -1,19 +1,31 @@
 package api
 
 import (
-	"encoding/json"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"subscribe_aggregation-main/internal/storage"
 	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/google/uuid"
 )
 
 // ListSubscriptions godoc
 // @Summary      List all subscriptions
 // @Tags         subscriptions
 // @Produce      json
+// @Param        page          query  int     false  "Page number"
+// @Param        limit         query  int     false  "Page size"
+// @Param        user_id       query  string  false  "User ID UUID"
+// @Param        service_name  query  string  false  "Service Name"
+// @Param        tag           query  []string  false  "Tags (categories), subscription must have all of them"  collectionFormat(multi)
+// @Param        start_date    query  string  false  "Active on or after: MM-YYYY, YYYY-MM, YYYY-MM-DD or RFC 3339"
+// @Param        end_date      query  string  false  "Active on or before: MM-YYYY, YYYY-MM, YYYY-MM-DD or RFC 3339, month includes its last day"
 // @Success      200  {array}   models.Subscription
-// @Failure      500  {string}  string "Internal server error"
+// @Failure      400  {object}  Problem "Invalid parameter"
+// @Failure      500  {object}  Problem "Internal server error"
+// @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Router       /subscriptions [get]
 func (h *Handler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
 	logger := logging.GetLogger()
@@ -28,13 +40,43 @@ func (h *Handler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
 		limit = 10
 	}
 
-	subs, err := h.Storage.ListSubscriptions(r.Context(), page, limit)
+	userID := query.Get("user_id")
+	if userID != "" {
+		if _, err := uuid.Parse(userID); err != nil {
+			logger.ErrorContext(r.Context(), "ListSubscriptions: invalid user_id", slog.String("user_id", userID))
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid user_id",
+				FieldError{Field: "user_id", Code: FieldInvalid, Message: "user_id must be a UUID"})
+			return
+		}
+	}
+
+	// Обычный пользователь видит только свои подписки
+	userID, ok := scopeUserID(w, r, userID)
+	if !ok {
+		return
+	}
+
+	from, to, ok := parsePeriodQuery(w, r)
+	if !ok {
+		return
+	}
+
+	filter := storage.ListFilter{
+		Page:        page,
+		Limit:       limit,
+		UserID:      userID,
+		ServiceName: query.Get("service_name"),
+		Tags:        query["tag"],
+		From:        from,
+		To:          to,
+	}
+
+	subs, err := h.Storage.ListSubscriptions(r.Context(), filter)
 	if err != nil {
-		logger.Error("ListSubscriptions: failed to list subscriptions", slog.String("error", err.Error()))
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+		writeStorageError(w, r, "ListSubscriptions", "subscription", err)
 		return
 	}
 
-	logger.Info("ListSubscriptions: retrieved subscriptions", slog.Int("count", len(subs)))
-	json.NewEncoder(w).Encode(subs)
+	logger.InfoContext(r.Context(), "ListSubscriptions: retrieved subscriptions", slog.Int("count", len(subs)))
+	writeJSON(w, http.StatusOK, subs)
 }
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"subscribe_aggregation-main/pkg/logging"
+)
+
+// ListTags godoc
+// @Summary      List subscription tags (categories)
+// @Tags         tags
+// @Produce      json
+// @Success      200  {array}   models.Tag
+// @Failure      500  {object}  Problem "Internal server error"
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Router       /tags [get]
+func (h *Handler) ListTags(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLogger()
+
+	tags, err := h.Storage.ListTags(r.Context())
+	if err != nil {
+		logger.ErrorContext(r.Context(), "ListTags: failed to list tags", slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "internal server error")
+		return
+	}
+
+	logger.InfoContext(r.Context(), "ListTags: retrieved tags", slog.Int("count", len(tags)))
+	writeJSON(w, http.StatusOK, tags)
+}
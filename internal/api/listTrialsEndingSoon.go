@@ -0,0 +1,75 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"subscribe_aggregation-main/pkg/logging"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultTrialDays — горизонт поиска заканчивающихся пробных периодов по умолчанию
+	defaultTrialDays = 7
+	// maxTrialDays ограничивает горизонт поиска, чтобы дата окончания оставалась в пределах дат Postgres
+	maxTrialDays = 365
+)
+
+// ListTrialsEndingSoon godoc
+// @Summary      List trials ending soon
+// @Description  Возвращает подписки, у которых пробный период заканчивается в ближайшие days дней, чтобы их можно было отменить до первого списания полной цены
+// @Tags         subscriptions
+// @Produce      json
+// @Param        days     query  int     false  "Days ahead, default 7, max 365"
+// @Param        user_id  query  string  false  "User ID UUID"
+// @Success      200  {array}   models.Subscription
+// @Failure      400  {object}  Problem "Invalid parameter"
+// @Failure      500  {object}  Problem "Internal server error"
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Router       /subscriptions/trials [get]
+func (h *Handler) ListTrialsEndingSoon(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLogger()
+	query := r.URL.Query()
+
+	days := defaultTrialDays
+	if daysStr := query.Get("days"); daysStr != "" {
+		parsed, err := strconv.Atoi(daysStr)
+		if err != nil || parsed < 0 || parsed > maxTrialDays {
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid days",
+				FieldError{Field: "days", Code: FieldInvalid, Message: "days must be an integer from 0 to " + strconv.Itoa(maxTrialDays)})
+			return
+		}
+		days = parsed
+	}
+
+	userID := query.Get("user_id")
+	if userID != "" {
+		if _, err := uuid.Parse(userID); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid user_id",
+				FieldError{Field: "user_id", Code: FieldInvalid, Message: "user_id must be a UUID"})
+			return
+		}
+	}
+
+	// Обычный пользователь видит только свои подписки
+	userID, ok := scopeUserID(w, r, userID)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, days)
+
+	subs, err := h.Storage.ListTrialsEndingSoon(r.Context(), userID, from, to)
+	if err != nil {
+		writeStorageError(w, r, "ListTrialsEndingSoon", "subscription", err)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "ListTrialsEndingSoon: retrieved subscriptions", slog.Int("count", len(subs)), slog.Int("days", days))
+	writeJSON(w, http.StatusOK, subs)
+}
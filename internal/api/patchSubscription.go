@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"subscribe_aggregation-main/internal/auth"
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/validation"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// PatchSubscription godoc
+// @Summary      Partially update subscription
+// @Description  Изменяет только переданные поля подписки (JSON Merge Patch, RFC 7396): null удаляет необязательное поле, массивы заменяются целиком. С If-Match подписка изменяется, только если ее версия совпадает с ETag; без него — если подписку не изменили между чтением и записью
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id        path      string               true   "Subscription ID (UUID)"
+// @Param        patch     body      models.Subscription  true   "Изменяемые поля"
+// @Param        If-Match  header    string               false  "ETag подписки"
+// @Success      200   {object}  models.Subscription
+// @Header       200   {string}  ETag  "Новая версия подписки"
+// @Failure      400   {object}  Problem "Invalid input or UUID"
+// @Failure      404   {object}  Problem "Subscription not found"
+// @Failure      412   {object}  Problem "Subscription was modified"
+// @Failure      428   {object}  Problem "If-Match is required"
+// @Failure      500   {object}  Problem "Internal server error"
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Router       /subscriptions/{id} [patch]
+func (h *Handler) PatchSubscription(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLogger()
+	idStr := chi.URLParam(r, "id")
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "PatchSubscription: invalid UUID", slog.String("uuid", idStr), slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid UUID", FieldError{Field: "id", Code: FieldInvalid, Message: "id must be a UUID"})
+		return
+	}
+
+	version, ok := h.ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	var patch map[string]any
+	decoder := json.NewDecoder(limitBody(w, r))
+	decoder.UseNumber()
+	if err := decoder.Decode(&patch); err != nil || patch == nil {
+		if writeBodyTooLarge(w, r, err) {
+			return
+		}
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "request body must be a JSON object")
+		return
+	}
+
+	current, err := h.Storage.GetSubscriptionByID(r.Context(), id)
+	if err != nil {
+		writeStorageError(w, r, "PatchSubscription", "subscription", err)
+		return
+	}
+	if !auth.CanAccessSubscription(auth.FromContext(r.Context()), current, true) {
+		writeProblem(w, r, http.StatusNotFound, CodeNotFound, "subscription not found")
+		return
+	}
+	if version > 0 && version != current.Version {
+		writeProblem(w, r, http.StatusPreconditionFailed, CodePreconditionFailed, "subscription was modified since it was read")
+		return
+	}
+
+	merged, err := mergeSubscriptionPatch(current, patch)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "PatchSubscription: failed to apply patch", slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "internal server error")
+		return
+	}
+
+	// Результат проверяется так же строго, как тело PUT
+	var sub models.Subscription
+	if !decodeJSONFrom(w, r, bytes.NewReader(merged), &sub) {
+		return
+	}
+
+	if err := validation.Subscription(&sub); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	clearServerFields(&sub)
+	sub.ID = id
+	// Без If-Match изменение все равно не затрет правку, сделанную после чтения текущей версии
+	sub.Version = current.Version
+
+	if err := h.Storage.UpdateSubscription(r.Context(), &sub); err != nil {
+		writeStorageError(w, r, "PatchSubscription", "subscription", err)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "PatchSubscription: subscription updated", slog.String("subscription_id", id.String()))
+	w.Header().Set("ETag", subscriptionETag(sub.Version))
+	writeJSON(w, http.StatusOK, sub)
+}
+
+// mergeSubscriptionPatch применяет patch к JSON представлению подписки
+func mergeSubscriptionPatch(sub *models.Subscription, patch map[string]any) ([]byte, error) {
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mergePatch(doc, patch))
+}
+
+// mergePatch применяет JSON Merge Patch (RFC 7396) к документу target
+func mergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/pkg/logging"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// PauseRequest — тело запроса паузы и возобновления. Без даты используется текущий день
+type PauseRequest struct {
+	Date *models.DataOnly `json:"date,omitempty"`
+}
+
+// parsePauseDate читает необязательную дату из тела запроса
+func parsePauseDate(r *http.Request) (time.Time, error) {
+	var req PauseRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return time.Time{}, err
+	}
+	if req.Date != nil {
+		return req.Date.ToTime(), nil
+	}
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// PauseSubscription godoc
+// @Summary      Pause subscription
+// @Description  Приостанавливает подписку с указанной даты (по умолчанию с сегодняшнего дня). Месяцы паузы не входят в стоимость
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id    path      string        true   "Subscription ID (UUID)"
+// @Param        body  body      PauseRequest  false  "Pause start date"
+// @Success      201   {object}  models.Pause
+// @Header       201   {string}  ETag  "Новая версия подписки"
+// @Failure      400   {object}  Problem "Invalid UUID or date"
+// @Failure      404   {object}  Problem "Subscription not found"
+// @Failure      409   {object}  Problem "Subscription is already paused"
+// @Failure      500   {object}  Problem "Internal server error"
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Router       /subscriptions/{id}/pause [post]
+func (h *Handler) PauseSubscription(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLogger()
+	idStr := chi.URLParam(r, "id")
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "PauseSubscription: invalid UUID", slog.String("uuid", idStr), slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid UUID", FieldError{Field: "id", Code: FieldInvalid, Message: "id must be a UUID"})
+		return
+	}
+
+	date, err := parsePauseDate(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "invalid request body")
+		return
+	}
+
+	if !h.authorizeSubscription(w, r, "PauseSubscription", id, true) {
+		return
+	}
+
+	pause, version, err := h.Storage.PauseSubscription(r.Context(), id, date)
+	if err != nil {
+		writeStorageError(w, r, "PauseSubscription", "subscription", err)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "PauseSubscription: subscription paused", slog.String("subscription_id", id.String()))
+	w.Header().Set("ETag", subscriptionETag(version))
+	writeJSON(w, http.StatusCreated, pause)
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"subscribe_aggregation-main/internal/ratelimit"
+	"subscribe_aggregation-main/pkg/logging"
+	"time"
+)
+
+// RateLimit ограничивает частоту запросов клиента квотой limiter и сообщает
+// состояние квоты заголовками RateLimit-Limit, RateLimit-Remaining и RateLimit-Reset.
+// Если хранилище квот недоступно, запрос пропускается: лимит не должен ронять API.
+// С nil limiter middleware ничего не ограничивает.
+func RateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return rateLimit(limiter, requestClient)
+}
+
+// IPRateLimit работает как RateLimit, но считает запросы по IP адресу и ставится
+// до аутентификации: иначе подбор токенов и API ключей, включая поиск ключа в базе,
+// не ограничивался бы ничем.
+func IPRateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return rateLimit(limiter, ipClient)
+}
+
+// rateLimit ограничивает запросы квотой limiter для клиента, которого возвращает client
+func rateLimit(limiter *ratelimit.Limiter, client func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := limiter.Allow(r.Context(), client(r))
+			if err != nil {
+				logging.GetLogger().WarnContext(r.Context(), "RateLimit: failed to check quota",
+					slog.String("limiter", limiter.Name),
+					slog.String("error", err.Error()))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", ceilSeconds(result.Reset))
+
+			if !result.Allowed {
+				w.Header().Set("Retry-After", ceilSeconds(result.RetryAfter))
+				writeProblem(w, r, http.StatusTooManyRequests, CodeRateLimited, "rate limit exceeded, retry later")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ceilSeconds округляет длительность вверх до целых секунд, как требуют заголовки
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
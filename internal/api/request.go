@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/validation"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FieldUnknown — код ошибки для поля, которого нет в схеме запроса
+const FieldUnknown = "unknown_field"
+
+// maxBodyBytes ограничивает тело запроса, которое сервер читает в память
+const maxBodyBytes = 1 << 20
+
+// limitBody ограничивает тело запроса maxBodyBytes. Чтение сверх лимита возвращает
+// *http.MaxBytesError, а соединение закрывается после ответа
+func limitBody(w http.ResponseWriter, r *http.Request) io.Reader {
+	return http.MaxBytesReader(w, r.Body, maxBodyBytes)
+}
+
+// writeBodyTooLarge отправляет 413, если err вызван превышением maxBodyBytes
+func writeBodyTooLarge(w http.ResponseWriter, r *http.Request, err error) bool {
+	var maxErr *http.MaxBytesError
+	if !errors.As(err, &maxErr) {
+		return false
+	}
+	writeProblem(w, r, http.StatusRequestEntityTooLarge, CodeBodyTooLarge,
+		"request body must not exceed "+strconv.FormatInt(maxErr.Limit, 10)+" bytes")
+	return true
+}
+
+// decodeJSON читает тело запроса в v, отклоняя неизвестные поля.
+// При ошибке отправляет problem+json ответ и возвращает false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	return decodeJSONFrom(w, r, limitBody(w, r), v)
+}
+
+// decodeJSONFrom работает как decodeJSON, но читает JSON из body, а не из тела запроса
+func decodeJSONFrom(w http.ResponseWriter, r *http.Request, body io.Reader, v any) bool {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(v)
+	if err == nil {
+		return true
+	}
+	if writeBodyTooLarge(w, r, err) {
+		return false
+	}
+
+	// encoding/json не экспортирует тип ошибки для неизвестного поля
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field := strings.Trim(name, `"`)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "unknown field "+field,
+			FieldError{Field: field, Code: FieldUnknown, Message: field + " is not allowed"})
+		return false
+	}
+
+	var dateErr *models.DateError
+	if errors.As(err, &dateErr) {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, dateErr.Error())
+		return false
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "invalid request body",
+			FieldError{Field: typeErr.Field, Code: FieldInvalid, Message: typeErr.Field + " must be " + typeErr.Type.String()})
+		return false
+	}
+
+	writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "invalid request body")
+	return false
+}
+
+// clearServerFields сбрасывает поля подписки, которые заполняет сервер. Клиенты отправляют
+// в PUT подписку, прочитанную GET, целиком, поэтому эти поля в теле допустимы, но не применяются:
+// в ответе они содержат сохраненные значения.
+func clearServerFields(sub *models.Subscription) {
+	sub.ID = uuid.Nil
+	sub.Version = 0
+	sub.CreatedAt = models.DataOnly{}
+	sub.UpdatedAt = models.DataOnly{}
+	sub.Pauses = nil
+	sub.TrialEndDate = nil
+}
+
+// writeValidationError отправляет все нарушения проверки одним ответом
+func writeValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, err.Error())
+		return
+	}
+
+	fields := make([]FieldError, 0, len(errs))
+	for _, v := range errs {
+		fields = append(fields, FieldError{Field: v.Field, Code: v.Code, Message: v.Message})
+	}
+	writeProblem(w, r, http.StatusBadRequest, CodeValidationFailed, "request validation failed", fields...)
+}
+
+// parseDateQuery разбирает дату из параметра запроса функцией parse.
+// Пустой параметр не считается ошибкой, в этом случае возвращается нулевое время.
+func parseDateQuery(w http.ResponseWriter, r *http.Request, name string, parse func(string) (time.Time, error)) (time.Time, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	t, err := parse(value)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid "+name,
+			FieldError{Field: name, Code: FieldInvalid, Message: err.Error()})
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// parsePeriodQuery разбирает параметры start_date и end_date.
+// Месяц без дня в end_date означает последний день этого месяца.
+func parsePeriodQuery(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	start, ok := parseDateQuery(w, r, "start_date", models.ParseDate)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	end, ok := parseDateQuery(w, r, "end_date", models.ParseEndDate)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
+	if !end.IsZero() && end.Before(start) {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid end_date",
+			FieldError{Field: "end_date", Code: validation.CodeOrder, Message: "end_date must not be before start_date"})
+		return time.Time{}, time.Time{}, false
+	}
+	return start, end, true
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// ResumeSubscription godoc
+// @Summary      Resume paused subscription
+// @Description  Завершает текущую паузу подписки указанной датой (по умолчанию сегодняшним днем)
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id    path      string        true   "Subscription ID (UUID)"
+// @Param        body  body      PauseRequest  false  "Resume date"
+// @Success      200   {object}  models.Pause
+// @Header       200   {string}  ETag  "Новая версия подписки"
+// @Failure      400   {object}  Problem "Invalid UUID or date"
+// @Failure      404   {object}  Problem "Subscription not found"
+// @Failure      409   {object}  Problem "Subscription is not paused"
+// @Failure      500   {object}  Problem "Internal server error"
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Router       /subscriptions/{id}/resume [post]
+func (h *Handler) ResumeSubscription(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLogger()
+	idStr := chi.URLParam(r, "id")
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "ResumeSubscription: invalid UUID", slog.String("uuid", idStr), slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid UUID", FieldError{Field: "id", Code: FieldInvalid, Message: "id must be a UUID"})
+		return
+	}
+
+	date, err := parsePauseDate(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidBody, "invalid request body")
+		return
+	}
+
+	if !h.authorizeSubscription(w, r, "ResumeSubscription", id, true) {
+		return
+	}
+
+	pause, version, err := h.Storage.ResumeSubscription(r.Context(), id, date)
+	if err != nil {
+		writeStorageError(w, r, "ResumeSubscription", "subscription", err)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "ResumeSubscription: subscription resumed", slog.String("subscription_id", id.String()))
+	w.Header().Set("ETag", subscriptionETag(version))
+	writeJSON(w, http.StatusOK, pause)
+}
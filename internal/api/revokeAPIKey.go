@@ -0,0 +1,42 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// RevokeAPIKey godoc
+// @Summary Revoke API key by ID
+// @Description Отзывает ключ: запросы с ним сразу получают 401, запись остается в списке
+// @Tags api-keys
+// @Param id path string true "API key ID UUID"
+// @Success 204 "No content"
+// @Failure 400 {object} Problem "Invalid UUID"
+// @Failure 403 {object} Problem "Admin role required"
+// @Failure 404 {object} Problem "API key not found or already revoked"
+// @Failure 500 {object} Problem "Server error"
+// @Security BearerAuth
+// @Router /api-keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLogger()
+	idStr := chi.URLParam(r, "id")
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "RevokeAPIKey: invalid UUID", slog.String("uuid", idStr), slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid UUID", FieldError{Field: "id", Code: FieldInvalid, Message: "id must be a UUID"})
+		return
+	}
+
+	if err := h.Storage.RevokeAPIKey(r.Context(), id); err != nil {
+		writeStorageError(w, r, "RevokeAPIKey", "API key", err)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "RevokeAPIKey: API key revoked", slog.String("api_key_id", id.String()))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"subscribe_aggregation-main/internal/auth"
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// RotateAPIKey godoc
+// @Summary Rotate API key
+// @Description Выпускает новый ключ с теми же правами, старый перестает работать сразу. Новый ключ возвращается в поле key только в этом ответе
+// @Tags api-keys
+// @Produce json
+// @Param id path string true "API key ID UUID"
+// @Success 200 {object} models.APIKey
+// @Failure 400 {object} Problem "Invalid UUID"
+// @Failure 403 {object} Problem "Admin role required"
+// @Failure 404 {object} Problem "API key not found or revoked"
+// @Failure 500 {object} Problem "Server error"
+// @Security BearerAuth
+// @Router /api-keys/{id}/rotate [post]
+func (h *Handler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLogger()
+	idStr := chi.URLParam(r, "id")
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "RotateAPIKey: invalid UUID", slog.String("uuid", idStr), slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid UUID", FieldError{Field: "id", Code: FieldInvalid, Message: "id must be a UUID"})
+		return
+	}
+
+	key, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		logger.ErrorContext(r.Context(), "RotateAPIKey: failed to generate key", slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "internal server error")
+		return
+	}
+
+	apiKey := models.APIKey{ID: id, Prefix: prefix, Hash: hash}
+	if err := h.Storage.RotateAPIKey(r.Context(), &apiKey); err != nil {
+		writeStorageError(w, r, "RotateAPIKey", "API key", err)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "RotateAPIKey: API key rotated",
+		slog.String("api_key_id", apiKey.ID.String()),
+		slog.String("prefix", apiKey.Prefix))
+	apiKey.Key = key
+	writeJSON(w, http.StatusOK, apiKey)
+}
@@ -1,68 +1,97 @@
 package api
 
 import (
-	"encoding/json"
 	"log/slog"
 	"net/http"
 	"subscribe_aggregation-main/pkg/logging"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // SumSubscriptionsCostHandler godoc
 // @Summary Calculate total subscription cost filtered by user, service and period
+// @Description Для user_id совместные подписки учитываются в размере доли пользователя.
+// @Description С group_by=category возвращает {"categories": {"<tag>": total}}, подписки без тегов попадают в "uncategorized"
 // @Tags subscription
 // @Accept json
 // @Produce json
 // @Param user_id query string false "User ID UUID"
 // @Param service_name query string false "Service Name"
-// @Param start_date query string false "Start month-year MM-YYYY"
-// @Param end_date query string false "End month-year MM-YYYY"
+// @Param start_date query string false "Period start: MM-YYYY, YYYY-MM, YYYY-MM-DD or RFC 3339"
+// @Param end_date query string false "Period end: MM-YYYY, YYYY-MM, YYYY-MM-DD or RFC 3339, month includes its last day"
+// @Param group_by query string false "Group totals, supported value: category"
 // @Success 200 {object} map[string]int64
-// @Failure 400 {string} string "Invalid parameter"
-// @Failure 500 {string} string "Server error"
+// @Failure 400 {object} Problem "Invalid parameter"
+// @Failure 429 {object} Problem "Rate limit exceeded"
+// @Failure 500 {object} Problem "Server error"
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /subscriptions/sum [get]
 func (h *Handler) SumSubscriptionsCostHandler(w http.ResponseWriter, r *http.Request) {
 	logger := logging.GetLogger()
 
 	userID := r.URL.Query().Get("user_id")
 	serviceName := r.URL.Query().Get("service_name")
-	startStr := r.URL.Query().Get("start_date")
-	endStr := r.URL.Query().Get("end_date")
+	groupBy := r.URL.Query().Get("group_by")
 
-	var start, end time.Time
-	var err error
+	if groupBy != "" && groupBy != "category" {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid group_by, expected category",
+			FieldError{Field: "group_by", Code: FieldInvalid, Message: "group_by must be category"})
+		return
+	}
 
-	if startStr != "" {
-		start, err = time.Parse("01-2006", startStr)
-		if err != nil {
-			logger.Error("SumSubscriptionsCostHandler: invalid start_date format", slog.String("error", err.Error()))
-			http.Error(w, "invalid start_date format, expected MM-YYYY", http.StatusBadRequest)
+	if userID != "" {
+		if _, err := uuid.Parse(userID); err != nil {
+			logger.ErrorContext(r.Context(), "SumSubscriptionsCostHandler: invalid user_id", slog.String("user_id", userID))
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid user_id",
+				FieldError{Field: "user_id", Code: FieldInvalid, Message: "user_id must be a UUID"})
 			return
 		}
-	} else {
-		start = time.Time{}
 	}
 
-	if endStr != "" {
-		end, err = time.Parse("01-2006", endStr)
+	// Обычный пользователь считает только свои подписки
+	userID, ok := scopeUserID(w, r, userID)
+	if !ok {
+		return
+	}
+
+	start, end, ok := parsePeriodQuery(w, r)
+	if !ok {
+		return
+	}
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	if groupBy == "category" {
+		categories, err := h.Storage.SumSubscriptionsCostByCategory(r.Context(), userID, serviceName, start, end)
 		if err != nil {
-			logger.Error("SumSubscriptionsCostHandler: invalid end_date format", slog.String("error", err.Error()))
-			http.Error(w, "invalid end_date format, expected MM-YYYY", http.StatusBadRequest)
+			writeStorageError(w, r, "SumSubscriptionsCostHandler", "subscription", err)
 			return
 		}
-	} else {
-		end = time.Now()
+
+		logger.InfoContext(r.Context(), "SumSubscriptionsCostHandler: category totals calculated",
+			slog.String("user_id", userID), slog.String("service_name", serviceName), slog.Int("categories", len(categories)))
+
+		writeJSON(w, http.StatusOK, CategoryCostResponse{Categories: categories})
+		return
 	}
 
 	total, err := h.Storage.SumSubscriptionsCost(r.Context(), userID, serviceName, start, end)
 	if err != nil {
-		logger.Error("SumSubscriptionsCostHandler: failed to sum subscriptions cost", slog.String("error", err.Error()))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStorageError(w, r, "SumSubscriptionsCostHandler", "subscription", err)
 		return
 	}
 
-	logger.Info("SumSubscriptionsCostHandler: total price calculated",
+	logger.InfoContext(r.Context(), "SumSubscriptionsCostHandler: total price calculated",
 		slog.String("user_id", userID), slog.String("service_name", serviceName), slog.Int64("total_price", total))
 
-	json.NewEncoder(w).Encode(map[string]int64{"total_price": total})
+	writeJSON(w, http.StatusOK, map[string]int64{"total_price": total})
+}
+
+// CategoryCostResponse — стоимость подписок по категориям (тегам).
+// Подписка с несколькими тегами входит в сумму каждого из них.
+type CategoryCostResponse struct {
+	Categories map[string]int64 `json:"categories"`
 }
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"subscribe_aggregation-main/internal/auth"
+	"subscribe_aggregation-main/internal/tenant"
+)
+
+// TenantHeader — заголовок с организацией, к данным которой относится запрос
+const TenantHeader = "X-Tenant-ID"
+
+// ResolveTenant определяет организацию запроса и кладет ее в контекст.
+// Правила выбора описаны в auth.ResolveTenant: заголовком выбирают организацию
+// администраторы без организации в токене и запросы без аутентификации.
+func ResolveTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := auth.ResolveTenant(auth.FromContext(r.Context()), strings.TrimSpace(r.Header.Get(TenantHeader)))
+		if errors.Is(err, auth.ErrInvalidTenant) {
+			writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid "+TenantHeader,
+				FieldError{Field: TenantHeader, Code: FieldInvalid, Message: "tenant id may contain only lowercase letters, digits, - and _"})
+			return
+		}
+		if err != nil {
+			writeProblem(w, r, http.StatusForbidden, CodeForbidden, err.Error())
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(tenant.NewContext(r.Context(), id)))
+	})
+}
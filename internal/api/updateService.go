@@ -0,0 +1,60 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/validation"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// UpdateService godoc
+// @Summary      Update catalog service by ID
+// @Description  Обновляет сервис каталога. Подписки со старым названием или совпадающими алиасами переименовываются
+// @Tags         services
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string          true  "Service ID (UUID)"
+// @Param        service  body      models.Service  true  "Service data"
+// @Success      200  {object}  models.Service
+// @Failure      400  {object}  Problem "Invalid input or UUID"
+// @Failure      404  {object}  Problem "Service not found"
+// @Failure      409  {object}  Problem "Alias belongs to another service"
+// @Failure      500  {object}  Problem "Internal server error"
+// @Security     BearerAuth
+// @Router       /services/{id} [put]
+func (h *Handler) UpdateService(w http.ResponseWriter, r *http.Request) {
+	logger := logging.GetLogger()
+	idStr := chi.URLParam(r, "id")
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "UpdateService: invalid UUID", slog.String("uuid", idStr), slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid UUID", FieldError{Field: "id", Code: FieldInvalid, Message: "id must be a UUID"})
+		return
+	}
+
+	var svc models.Service
+	if !decodeJSON(w, r, &svc) {
+		return
+	}
+
+	if err := validation.Service(&svc); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	svc.ID = id
+
+	err = h.Storage.UpdateService(r.Context(), &svc)
+	if err != nil {
+		writeStorageError(w, r, "UpdateService", "service", err)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "UpdateService: service updated", slog.String("service_id", id.String()))
+	writeJSON(w, http.StatusOK, svc)
+}
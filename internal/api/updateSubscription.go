@@ -1,12 +1,10 @@
 package api
 
 import (
-	"database/sql"
-	"encoding/json"
-	"errors"
 	"log/slog"
 	"net/http"
 	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/validation"
 	"subscribe_aggregation-main/pkg/logging"
 
 	"github.com/go-chi/chi/v5"
@@ -15,16 +13,22 @@ import (
 
 // UpdateSubscription godoc
 // @Summary      Update subscription by ID
-// @Description  Update subscription record by UUID with new data
+// @Description  Update subscription record by UUID with new data. С If-Match подписка изменяется, только если ее версия совпадает с ETag. Поля id, version, pauses, trial_end_date, created_at и updated_at заполняет сервер: в теле они игнорируются, а в ответе содержат сохраненные значения. Владельца подписки изменить нельзя: без user_id сохраняется текущий, другой user_id отклоняется с 400
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
 // @Param        id    path      string             true  "Subscription ID (UUID)"
 // @Param        sub   body      models.Subscription true  "Subscription object"
+// @Param        If-Match  header  string  false  "ETag подписки"
 // @Success      200   {object}  models.Subscription
-// @Failure      400   {string}  string "Invalid input or UUID"
-// @Failure      404   {string}  string "Subscription not found"
-// @Failure      500   {string}  string "Internal server error"
+// @Header       200   {string}  ETag  "Новая версия подписки"
+// @Failure      400   {object}  Problem "Invalid input or UUID"
+// @Failure      404   {object}  Problem "Subscription not found"
+// @Failure      412   {object}  Problem "Subscription was modified"
+// @Failure      428   {object}  Problem "If-Match is required"
+// @Failure      500   {object}  Problem "Internal server error"
+// @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Router       /subscriptions/{id} [put]
 
 func (h *Handler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
@@ -33,32 +37,42 @@ func (h *Handler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		logger.Error("UpdateSubscription: invalid UUID", slog.String("uuid", idStr), slog.String("error", err.Error()))
-		http.Error(w, "invalid UUID", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "UpdateSubscription: invalid UUID", slog.String("uuid", idStr), slog.String("error", err.Error()))
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, "invalid UUID", FieldError{Field: "id", Code: FieldInvalid, Message: "id must be a UUID"})
 		return
 	}
 
 	var sub models.Subscription
-	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
-		logger.Error("UpdateSubscription: invalid request body", slog.String("error", err.Error()))
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &sub) {
 		return
 	}
 
+	if err := validation.Subscription(&sub); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	clearServerFields(&sub)
 	sub.ID = id
 
+	// Версия берется только из If-Match
+	version, ok := h.ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+	sub.Version = version
+
+	if !h.authorizeSubscription(w, r, "UpdateSubscription", id, true) {
+		return
+	}
+
 	err = h.Storage.UpdateSubscription(r.Context(), &sub)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			logger.Info("UpdateSubscription: subscription not found", slog.String("subscription_id", id.String()))
-			http.Error(w, "subscription not found", http.StatusNotFound)
-			return
-		}
-		logger.Error("UpdateSubscription: failed to update subscription", slog.String("subscription_id", id.String()), slog.String("error", err.Error()))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStorageError(w, r, "UpdateSubscription", "subscription", err)
 		return
 	}
 
-	logger.Info("UpdateSubscription: subscription updated", slog.String("subscription_id", id.String()))
-	json.NewEncoder(w).Encode(sub)
+	logger.InfoContext(r.Context(), "UpdateSubscription: subscription updated", slog.String("subscription_id", id.String()))
+	w.Header().Set("ETag", subscriptionETag(sub.Version))
+	writeJSON(w, http.StatusOK, sub)
 }
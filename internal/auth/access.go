@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/tenant"
+)
+
+var (
+	// ErrForbidden возвращается при обращении к данным другого пользователя
+	ErrForbidden = errors.New("access to another user's subscriptions is not allowed")
+	// ErrInvalidTenant возвращается для организации в неверном формате
+	ErrInvalidTenant = errors.New("invalid tenant id")
+	// ErrTenantForbidden возвращается при обращении к другой организации
+	ErrTenantForbidden = errors.New("access to another tenant is not allowed")
+)
+
+// ScopeUserID возвращает user_id, которым ограничен список или сумма подписок.
+// Обычный пользователь работает только со своими данными: пустой фильтр
+// заменяется его ID, а чужой ID запрещен. Администратор, сервисы с API ключом
+// и запросы без аутентификации не ограничиваются.
+func ScopeUserID(p *Principal, requested string) (string, error) {
+	if p == nil || p.AllUsers() {
+		return requested, nil
+	}
+
+	own := p.UserID.String()
+	if requested != "" && !strings.EqualFold(requested, own) {
+		return "", ErrForbidden
+	}
+	return own, nil
+}
+
+// CanAccessSubscription проверяет доступ пользователя к подписке.
+// Читать подписку могут владелец и участники, изменять — только владелец.
+func CanAccessSubscription(p *Principal, sub *models.Subscription, write bool) bool {
+	if p == nil || p.AllUsers() || sub.UserID == p.UserID {
+		return true
+	}
+	if write {
+		return false
+	}
+	for _, member := range sub.Members {
+		if member.UserID == p.UserID {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveTenant определяет организацию запроса.
+// Организация из токена или API ключа главнее запрошенной, и запрос другой
+// организации запрещен. Запрошенную организацию выбирают администраторы без
+// организации в токене и запросы без аутентификации. Токены без организации
+// у остальных пользователей относятся к организации по умолчанию.
+func ResolveTenant(p *Principal, requested string) (string, error) {
+	if requested != "" && !tenant.Valid(requested) {
+		return "", ErrInvalidTenant
+	}
+
+	id := requested
+	if p != nil {
+		own := p.TenantID
+		if own == "" && !p.IsAdmin() {
+			own = tenant.Default
+		}
+		if own != "" {
+			if requested != "" && requested != own {
+				return "", ErrTenantForbidden
+			}
+			id = own
+		}
+	}
+	if id == "" {
+		id = tenant.Default
+	}
+	return id, nil
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+)
+
+// Права API ключей. Ключ получает доступ только к маршрутам с выданными ему правами
+const (
+	ScopeSubscriptionsRead  = "subscriptions:read"
+	ScopeSubscriptionsWrite = "subscriptions:write"
+	ScopeReportsRead        = "reports:read"
+)
+
+// Scopes — все права, которые можно выдать ключу
+var Scopes = []string{ScopeSubscriptionsRead, ScopeSubscriptionsWrite, ScopeReportsRead}
+
+// IsValidScope проверяет, что право известно сервису
+func IsValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+const (
+	// apiKeyPrefix отличает ключи сервиса от других секретов, например в сканерах утечек
+	apiKeyPrefix = "sak_"
+	// apiKeyDisplayLength — длина начала ключа, которое хранится открыто для поиска ключа в списке
+	apiKeyDisplayLength = 12
+)
+
+// NewAPIKey генерирует ключ и возвращает его вместе с отображаемым префиксом и хешем для хранения
+func NewAPIKey() (key, prefix, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", fmt.Errorf("generate API key: %w", err)
+	}
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return key, key[:apiKeyDisplayLength], HashAPIKey(key), nil
+}
+
+// HashAPIKey возвращает SHA-256 ключа. Ключи случайные и длинные,
+// поэтому медленное хеширование, как для паролей, не требуется.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
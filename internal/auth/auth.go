@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// RoleAdmin — роль с доступом к подпискам всех пользователей
+const RoleAdmin = "admin"
+
+// Principal — аутентифицированный пользователь или сервис, от имени которого выполняется запрос
+type Principal struct {
+	UserID uuid.UUID
+	Roles  []string
+	// APIKeyID задан для запросов сервисов по API ключу
+	APIKeyID uuid.UUID
+	// Scopes — права API ключа. Пользователи с JWT ограничены ролями, а не правами
+	Scopes []string
+	// TenantID — организация из токена или ключа. Пустая у администраторов,
+	// которые выбирают организацию заголовком запроса
+	TenantID string
+}
+
+// HasRole проверяет наличие роли у пользователя
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// IsAdmin проверяет наличие роли администратора
+func (p *Principal) IsAdmin() bool {
+	return p.HasRole(RoleAdmin)
+}
+
+// IsService проверяет, что запрос выполняется по API ключу
+func (p *Principal) IsService() bool {
+	return p.APIKeyID != uuid.Nil
+}
+
+// HasScope проверяет право API ключа. Для пользователей права не ограничиваются
+func (p *Principal) HasScope(scope string) bool {
+	return !p.IsService() || slices.Contains(p.Scopes, scope)
+}
+
+// ClientKey возвращает ключ клиента для квот: API ключ или пользователь из токена
+func (p *Principal) ClientKey() string {
+	if p.IsService() {
+		return "key:" + p.APIKeyID.String()
+	}
+	return "user:" + p.UserID.String()
+}
+
+// AllUsers проверяет, что запрос может работать с данными всех пользователей:
+// это администраторы и сервисы, доступ которых ограничен правами ключа
+func (p *Principal) AllUsers() bool {
+	return p.IsAdmin() || p.IsService()
+}
+
+// ctxKeyPrincipal — ключ пользователя в контексте запроса
+type ctxKeyPrincipal struct{}
+
+// NewContext возвращает контекст с аутентифицированным пользователем
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKeyPrincipal{}, p)
+}
+
+// FromContext возвращает пользователя из контекста запроса.
+// Если аутентификация отключена, возвращает nil.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(ctxKeyPrincipal{}).(*Principal)
+	return p
+}
@@ -0,0 +1,43 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+
+	"subscribe_aggregation-main/internal/auth"
+
+	"github.com/google/uuid"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	key, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		t.Fatalf("NewAPIKey() error = %v", err)
+	}
+	if !strings.HasPrefix(key, prefix) || !strings.HasPrefix(key, "sak_") {
+		t.Errorf("key %q does not start with prefix %q", key, prefix)
+	}
+	if hash != auth.HashAPIKey(key) || len(hash) != 64 {
+		t.Errorf("unexpected hash %q", hash)
+	}
+
+	other, _, _, _ := auth.NewAPIKey()
+	if other == key {
+		t.Error("NewAPIKey() returned the same key twice")
+	}
+}
+
+func TestPrincipalScopes(t *testing.T) {
+	user := &auth.Principal{UserID: uuid.New()}
+	if !user.HasScope(auth.ScopeSubscriptionsWrite) || user.AllUsers() {
+		t.Errorf("user principal: HasScope = %v, AllUsers = %v", user.HasScope(auth.ScopeSubscriptionsWrite), user.AllUsers())
+	}
+
+	service := &auth.Principal{APIKeyID: uuid.New(), Scopes: []string{auth.ScopeReportsRead}}
+	if !service.HasScope(auth.ScopeReportsRead) || service.HasScope(auth.ScopeSubscriptionsWrite) {
+		t.Error("service principal must have only granted scopes")
+	}
+	if !service.AllUsers() || service.IsAdmin() {
+		t.Error("service principal must access all users without admin role")
+	}
+}
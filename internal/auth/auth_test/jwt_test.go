@@ -0,0 +1,172 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"subscribe_aggregation-main/internal/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const secret = "test-secret"
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func writeJWKS(t *testing.T, kid string, key *rsa.PublicKey) string {
+	t.Helper()
+	set := map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+	data, _ := json.Marshal(set)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write JWKS: %v", err)
+	}
+	return path
+}
+
+func TestNewVerifierRequiresKeys(t *testing.T) {
+	if _, err := auth.NewVerifier(auth.Config{}); !errors.Is(err, auth.ErrNoKeys) {
+		t.Errorf("expected ErrNoKeys, got %v", err)
+	}
+}
+
+func TestVerifyHS256(t *testing.T) {
+	verifier, err := auth.NewVerifier(auth.Config{Secret: secret, Issuer: "subscriptions"})
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	userID := uuid.New()
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name      string
+		token     string
+		wantErr   bool
+		wantAdmin bool
+	}{
+		{
+			name:  "valid_sub",
+			token: sign(t, jwt.SigningMethodHS256, []byte(secret), "", jwt.MapClaims{"sub": userID.String(), "iss": "subscriptions", "exp": exp}),
+		},
+		{
+			name:      "admin_role",
+			token:     sign(t, jwt.SigningMethodHS256, []byte(secret), "", jwt.MapClaims{"user_id": userID.String(), "roles": []string{"admin"}, "iss": "subscriptions", "exp": exp}),
+			wantAdmin: true,
+		},
+		{
+			name:  "tenant_claim",
+			token: sign(t, jwt.SigningMethodHS256, []byte(secret), "", jwt.MapClaims{"sub": userID.String(), "tenant_id": "acme", "iss": "subscriptions", "exp": exp}),
+		},
+		{
+			name:    "invalid_tenant",
+			token:   sign(t, jwt.SigningMethodHS256, []byte(secret), "", jwt.MapClaims{"sub": userID.String(), "tenant_id": "Acme Inc", "iss": "subscriptions", "exp": exp}),
+			wantErr: true,
+		},
+		{
+			name:    "wrong_secret",
+			token:   sign(t, jwt.SigningMethodHS256, []byte("other"), "", jwt.MapClaims{"sub": userID.String(), "iss": "subscriptions", "exp": exp}),
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			token:   sign(t, jwt.SigningMethodHS256, []byte(secret), "", jwt.MapClaims{"sub": userID.String(), "iss": "subscriptions", "exp": time.Now().Add(-time.Minute).Unix()}),
+			wantErr: true,
+		},
+		{
+			name:    "no_expiration",
+			token:   sign(t, jwt.SigningMethodHS256, []byte(secret), "", jwt.MapClaims{"sub": userID.String(), "iss": "subscriptions"}),
+			wantErr: true,
+		},
+		{
+			name:    "wrong_issuer",
+			token:   sign(t, jwt.SigningMethodHS256, []byte(secret), "", jwt.MapClaims{"sub": userID.String(), "iss": "other", "exp": exp}),
+			wantErr: true,
+		},
+		{
+			name:    "subject_not_uuid",
+			token:   sign(t, jwt.SigningMethodHS256, []byte(secret), "", jwt.MapClaims{"sub": "alice", "iss": "subscriptions", "exp": exp}),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := verifier.Verify(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, auth.ErrInvalidToken) {
+					t.Errorf("expected ErrInvalidToken, got %v", err)
+				}
+				return
+			}
+			if principal.UserID != userID {
+				t.Errorf("UserID = %s, want %s", principal.UserID, userID)
+			}
+			if principal.IsAdmin() != tt.wantAdmin {
+				t.Errorf("IsAdmin() = %v, want %v", principal.IsAdmin(), tt.wantAdmin)
+			}
+		})
+	}
+}
+
+func TestVerifyRS256WithJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	verifier, err := auth.NewVerifier(auth.Config{JWKSFile: writeJWKS(t, "key-1", &key.PublicKey)})
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	userID := uuid.New()
+	claims := jwt.MapClaims{"sub": userID.String(), "role": "admin", "exp": time.Now().Add(time.Hour).Unix()}
+
+	principal, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, key, "key-1", claims))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if principal.UserID != userID || !principal.IsAdmin() {
+		t.Errorf("unexpected principal %+v", principal)
+	}
+
+	if _, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, key, "unknown", claims)); err == nil {
+		t.Error("expected error for unknown kid")
+	}
+
+	// Без секрета HS256 токены не принимаются, даже если подписаны публичным ключом
+	if _, err := verifier.Verify(sign(t, jwt.SigningMethodHS256, key.PublicKey.N.Bytes(), "key-1", claims)); err == nil {
+		t.Error("expected error for HS256 token when only RSA keys are configured")
+	}
+}
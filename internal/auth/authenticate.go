@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/google/uuid"
+)
+
+// ErrNoCredentials возвращается, если в запросе нет ни Bearer токена, ни API ключа
+var ErrNoCredentials = errors.New("missing bearer token or API key")
+
+// KeyStore находит API ключи сервисов и отмечает их использование
+type KeyStore interface {
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+// Authenticator проверяет учетные данные запроса одинаково для REST и gRPC
+type Authenticator struct {
+	Verifier *Verifier
+	Keys     KeyStore
+}
+
+// Authenticate определяет пользователя по API ключу или значению заголовка Authorization.
+// API ключ проверяется первым. Ошибка поиска ключа возвращается без изменений,
+// чтобы вызывающий мог отличить отозванный ключ от недоступного хранилища.
+func (a *Authenticator) Authenticate(ctx context.Context, authorization, apiKey string) (*Principal, error) {
+	if key := strings.TrimSpace(apiKey); key != "" {
+		return a.authenticateAPIKey(ctx, key)
+	}
+
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || strings.TrimSpace(token) == "" {
+		return nil, ErrNoCredentials
+	}
+	return a.Verifier.Verify(strings.TrimSpace(token))
+}
+
+// authenticateAPIKey ищет действующий ключ по хешу и отмечает его использование
+func (a *Authenticator) authenticateAPIKey(ctx context.Context, key string) (*Principal, error) {
+	apiKey, err := a.Keys.GetAPIKeyByHash(ctx, HashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+
+	// Ошибка отметки использования не должна мешать запросу
+	if err := a.Keys.TouchAPIKey(ctx, apiKey.ID, time.Now().UTC()); err != nil {
+		logging.GetLogger().WarnContext(ctx, "Authenticate: failed to update API key usage",
+			slog.String("api_key_id", apiKey.ID.String()),
+			slog.String("error", err.Error()))
+	}
+
+	return &Principal{APIKeyID: apiKey.ID, Scopes: apiKey.Scopes, TenantID: apiKey.TenantID}, nil
+}
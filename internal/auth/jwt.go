@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"subscribe_aggregation-main/internal/tenant"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNoKeys возвращается, если не задан ни один ключ для проверки токенов
+	ErrNoKeys = errors.New("no JWT verification keys configured")
+	// ErrInvalidToken возвращается для неподписанных, просроченных или некорректных токенов
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Config — ключи и ограничения для проверки JWT.
+// HS256 проверяется секретом, RS256 — публичным ключом PEM или ключами из JWKS файла.
+type Config struct {
+	Secret        string
+	PublicKeyFile string
+	JWKSFile      string
+	Issuer        string
+	Audience      string
+}
+
+// Claims — поля токена. Пользователь берется из user_id, а если его нет — из sub.
+// Роли передаются списком roles или одной строкой role, организация — в tenant_id.
+type Claims struct {
+	jwt.RegisteredClaims
+	UserID   string   `json:"user_id,omitempty"`
+	Role     string   `json:"role,omitempty"`
+	Roles    []string `json:"roles,omitempty"`
+	TenantID string   `json:"tenant_id,omitempty"`
+}
+
+// Verifier проверяет подпись и срок действия токенов
+type Verifier struct {
+	secret  []byte
+	rsaKeys map[string]*rsa.PublicKey
+	options []jwt.ParserOption
+}
+
+// NewVerifier загружает ключи из конфигурации
+func NewVerifier(cfg Config) (*Verifier, error) {
+	v := &Verifier{rsaKeys: make(map[string]*rsa.PublicKey)}
+	if cfg.Secret != "" {
+		v.secret = []byte(cfg.Secret)
+	}
+
+	if cfg.PublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read public key: %w", err)
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse public key: %w", err)
+		}
+		// Ключ без kid подходит для токенов без заголовка kid
+		v.rsaKeys[""] = key
+	}
+
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		for kid, key := range keys {
+			v.rsaKeys[kid] = key
+		}
+	}
+
+	if v.secret == nil && len(v.rsaKeys) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	v.options = []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		v.options = append(v.options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		v.options = append(v.options, jwt.WithAudience(cfg.Audience))
+	}
+	return v, nil
+}
+
+// keyFunc выбирает ключ по алгоритму токена. Секрет HS256 никогда не используется
+// для RS256 и наоборот, чтобы нельзя было подменить алгоритм в заголовке.
+func (v *Verifier) keyFunc(token *jwt.Token) (any, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		if v.secret == nil {
+			return nil, errors.New("HS256 tokens are not accepted")
+		}
+		return v.secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := v.rsaKeys[kid]; ok {
+			return key, nil
+		}
+		// Единственный ключ подходит для токена без kid
+		if kid == "" && len(v.rsaKeys) == 1 {
+			for _, key := range v.rsaKeys {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+}
+
+// Verify проверяет токен и возвращает пользователя
+func (v *Verifier) Verify(tokenString string) (*Principal, error) {
+	var claims Claims
+	if _, err := jwt.ParseWithClaims(tokenString, &claims, v.keyFunc, v.options...); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	subject := claims.UserID
+	if subject == "" {
+		subject = claims.Subject
+	}
+	userID, err := uuid.Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: user id must be a UUID", ErrInvalidToken)
+	}
+
+	if claims.TenantID != "" && !tenant.Valid(claims.TenantID) {
+		return nil, fmt.Errorf("%w: invalid tenant_id", ErrInvalidToken)
+	}
+
+	roles := claims.Roles
+	if claims.Role != "" {
+		roles = append(roles, claims.Role)
+	}
+	return &Principal{UserID: userID, Roles: roles, TenantID: claims.TenantID}, nil
+}
+
+// jwks — набор публичных ключей в формате RFC 7517
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// loadJWKS читает RSA ключи подписи из локального JWKS файла
+func loadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read JWKS: %w", err)
+	}
+
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("parse JWKS key %q modulus: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("parse JWKS key %q exponent: %w", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS %s contains no RSA signing keys", path)
+	}
+	return keys, nil
+}
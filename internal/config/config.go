@@ -1,14 +1,24 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	"subscribe_aggregation-main/pkg/logging"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -22,9 +32,537 @@ var (
 
 // Config содержит настройки приложения
 type Config struct {
-	ServerHost  string
-	ServerPort  string
-	PostgresDSN string
+	// ConfigFile — YAML файл, из которого загружена конфигурация, если он задан
+	ConfigFile string
+	// Overridden — настройки из ConfigFile, значения которых переопределены окружением или флагами.
+	// Изменения таких настроек в файле не применяются, в том числе при перезагрузке.
+	Overridden []string
+	// ConfigWatchInterval — как часто проверять изменение ConfigFile, 0 отключает проверку
+	ConfigWatchInterval time.Duration
+
+	ServerHost string
+	ServerPort string
+
+	// Postgres* — параметры подключения к базе, из них собирается PostgresDSN
+	PostgresHost     string
+	PostgresPort     string
+	PostgresUser     string
+	PostgresPassword string
+	PostgresDB       string
+	SSLMode          string
+	PostgresDSN      string
+
+	// DBMaxOpenConns, DBMaxIdleConns и DBConnMaxLifetime — настройки пула соединений.
+	// Нулевое DBConnMaxLifetime не ограничивает время жизни соединения.
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+
+	// HTTP* — таймауты HTTP сервера, ShutdownTimeout — сколько ждать завершения запросов при остановке
+	HTTPReadHeaderTimeout time.Duration
+	HTTPReadTimeout       time.Duration
+	HTTPWriteTimeout      time.Duration
+	HTTPIdleTimeout       time.Duration
+	ShutdownTimeout       time.Duration
+
+	// LogLevel — минимальный уровень логов: debug, info, warn или error
+	LogLevel string
+	// LogOutput — stdout, stderr или file, LogFormat — json или text
+	LogOutput string
+	LogFormat string
+	// LogFile, LogMaxSizeMB, LogMaxAge и LogMaxBackups — файл логов и его ротация
+	LogFile       string
+	LogMaxSizeMB  int
+	LogMaxAge     time.Duration
+	LogMaxBackups int
+	// LogRedactFields и LogRedactQueryParams — атрибуты и параметры URL, скрываемые в логах
+	LogRedactFields      []string
+	LogRedactQueryParams []string
+
+	// GRPCPort — порт gRPC API, отдельный от REST
+	GRPCPort string
+
+	// GRPCEnabled, GraphQLEnabled и SwaggerEnabled включают соответствующие API
+	GRPCEnabled    bool
+	GraphQLEnabled bool
+	SwaggerEnabled bool
+
+	// MetricsEnabled включает /metrics для Prometheus, MetricsStatsInterval — период
+	// пересчета показателей подписок. Метрики без аутентификации и с данными по
+	// организациям, поэтому отдаются на отдельном внутреннем адресе MetricsHost:MetricsPort.
+	MetricsEnabled       bool
+	MetricsHost          string
+	MetricsPort          string
+	MetricsStatsInterval time.Duration
+
+	// DBRowLevelSecurity включает передачу организации в политики row-level security PostgreSQL
+	DBRowLevelSecurity bool
+
+	// AuthDisabled отключает проверку JWT, например для локальной разработки
+	AuthDisabled bool
+	// JWT — ключи для проверки токенов: секрет HS256, публичный ключ RS256 или JWKS файл
+	JWTSecret        string
+	JWTPublicKeyFile string
+	JWTJWKSFile      string
+	JWTIssuer        string
+	JWTAudience      string
+
+	// RateLimitStore — где хранятся квоты: memory (по умолчанию) или postgres для нескольких экземпляров.
+	// Значение off отключает ограничение частоты запросов.
+	RateLimitStore string
+	// RateLimitRPS и RateLimitBurst — квота клиента на все запросы
+	RateLimitRPS   float64
+	RateLimitBurst int
+	// RateLimitSumRPS и RateLimitSumBurst — отдельная квота на тяжелый запрос суммы
+	RateLimitSumRPS   float64
+	RateLimitSumBurst int
+	// RateLimitIPRPS и RateLimitIPBurst — квота IP адреса, проверяется до аутентификации
+	RateLimitIPRPS   float64
+	RateLimitIPBurst int
+
+	// IdempotencyTTL — сколько хранится ответ на запрос с Idempotency-Key
+	IdempotencyTTL time.Duration
+	// IdempotencyLease — через сколько ключ незавершенного запроса может занять повтор.
+	// Должен быть больше HTTP_WRITE_TIMEOUT, чтобы не выполнить медленный запрос дважды
+	IdempotencyLease time.Duration
+
+	// RequireIfMatch требует заголовок If-Match при изменении и удалении подписок
+	RequireIfMatch bool
+
+	// CORSAllowedOrigins — origin страниц, которым браузер разрешит запросы к API, "*" — любым
+	CORSAllowedOrigins []string
+
+	// NotifyWebhookURL — адрес, на который отправляются события об изменении подписок, пустой отключает их.
+	// NotifyWebhookSecret подписывает тело события, NotifyTimeout ограничивает время отправки
+	NotifyWebhookURL    string
+	NotifyWebhookSecret string
+	NotifyTimeout       time.Duration
+
+	// GraphQLMaxDepth и GraphQLMaxComplexity — ограничения вложенности и стоимости запросов GraphQL
+	GraphQLMaxDepth      int
+	GraphQLMaxComplexity int
+
+	// MigrateOnStartup применяет новые миграции при запуске сервера
+	MigrateOnStartup bool
+}
+
+// defaults возвращает конфигурацию по умолчанию
+func defaults() *Config {
+	return &Config{
+		ConfigWatchInterval: 10 * time.Second,
+
+		ServerHost: "0.0.0.0",
+		ServerPort: "8080",
+		GRPCPort:   "9090",
+
+		PostgresPort: "5432",
+		SSLMode:      "disable",
+
+		DBMaxOpenConns:    25,
+		DBMaxIdleConns:    5,
+		DBConnMaxLifetime: 30 * time.Minute,
+
+		HTTPReadHeaderTimeout: 5 * time.Second,
+		HTTPReadTimeout:       15 * time.Second,
+		HTTPWriteTimeout:      30 * time.Second,
+		HTTPIdleTimeout:       time.Minute,
+		ShutdownTimeout:       5 * time.Second,
+
+		LogLevel:      "info",
+		LogOutput:     "stdout",
+		LogFormat:     "json",
+		LogFile:       "app.log",
+		LogMaxSizeMB:  100,
+		LogMaxAge:     24 * time.Hour,
+		LogMaxBackups: 7,
+
+		LogRedactFields:      slices.Clone(logging.DefaultRedactFields),
+		LogRedactQueryParams: slices.Clone(logging.DefaultRedactQueryParams),
+
+		GRPCEnabled:    true,
+		GraphQLEnabled: true,
+		SwaggerEnabled: true,
+
+		MetricsHost:          "127.0.0.1",
+		MetricsPort:          "9100",
+		MetricsStatsInterval: time.Minute,
+
+		RateLimitRPS:      10,
+		RateLimitBurst:    20,
+		RateLimitSumRPS:   1,
+		RateLimitSumBurst: 5,
+		RateLimitIPRPS:    20,
+		RateLimitIPBurst:  40,
+
+		IdempotencyTTL:   24 * time.Hour,
+		IdempotencyLease: time.Minute,
+
+		NotifyTimeout: 5 * time.Second,
+
+		GraphQLMaxDepth:      8,
+		GraphQLMaxComplexity: 1000,
+	}
+}
+
+// setting связывает поле Config с переменной окружения. Ключ в YAML — имя переменной
+// в нижнем регистре (server_port), флаг — то же имя через дефис (-server-port).
+type setting struct {
+	env   string
+	ptr   any // *string, *[]string, *int, *float64, *bool или *time.Duration
+	usage string
+}
+
+func (s setting) key() string  { return strings.ToLower(s.env) }
+func (s setting) flag() string { return strings.ReplaceAll(s.key(), "_", "-") }
+
+// set разбирает значение из строки в поле настройки
+func (s setting) set(value string) error {
+	var err error
+	switch p := s.ptr.(type) {
+	case *string:
+		*p = value
+	case *[]string:
+		// Список задается через запятую, пустые элементы пропускаются
+		*p = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*p = append(*p, item)
+			}
+		}
+	case *int:
+		*p, err = strconv.Atoi(value)
+	case *float64:
+		*p, err = strconv.ParseFloat(value, 64)
+	case *bool:
+		*p, err = strconv.ParseBool(value)
+	case *time.Duration:
+		*p, err = time.ParseDuration(value)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q", value)
+	}
+	return nil
+}
+
+// value возвращает значение настройки строкой, которую понимает set
+func (s setting) value() string {
+	switch p := s.ptr.(type) {
+	case *string:
+		return *p
+	case *[]string:
+		return strings.Join(*p, ",")
+	case *int:
+		return strconv.Itoa(*p)
+	case *float64:
+		return strconv.FormatFloat(*p, 'g', -1, 64)
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *time.Duration:
+		return p.String()
+	}
+	return ""
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"CONFIG_WATCH_INTERVAL", &c.ConfigWatchInterval, "how often to check the config file for changes, 0 to disable"},
+
+		{"SERVER_HOST", &c.ServerHost, "address to listen on"},
+		{"SERVER_PORT", &c.ServerPort, "HTTP port"},
+		{"GRPC_PORT", &c.GRPCPort, "gRPC port"},
+
+		{"POSTGRES_HOST", &c.PostgresHost, "database host"},
+		{"POSTGRES_PORT", &c.PostgresPort, "database port"},
+		{"POSTGRES_USER", &c.PostgresUser, "database user"},
+		{"POSTGRES_PASSWORD", &c.PostgresPassword, "database password"},
+		{"POSTGRES_DB", &c.PostgresDB, "database name"},
+		{"SSL_MODE", &c.SSLMode, "database sslmode"},
+		{"DB_MAX_OPEN_CONNS", &c.DBMaxOpenConns, "maximum open database connections"},
+		{"DB_MAX_IDLE_CONNS", &c.DBMaxIdleConns, "maximum idle database connections"},
+		{"DB_CONN_MAX_LIFETIME", &c.DBConnMaxLifetime, "maximum database connection lifetime, 0 for unlimited"},
+
+		{"HTTP_READ_HEADER_TIMEOUT", &c.HTTPReadHeaderTimeout, "HTTP request header read timeout"},
+		{"HTTP_READ_TIMEOUT", &c.HTTPReadTimeout, "HTTP request read timeout"},
+		{"HTTP_WRITE_TIMEOUT", &c.HTTPWriteTimeout, "HTTP response write timeout"},
+		{"HTTP_IDLE_TIMEOUT", &c.HTTPIdleTimeout, "HTTP keep-alive idle timeout"},
+		{"SHUTDOWN_TIMEOUT", &c.ShutdownTimeout, "graceful shutdown timeout"},
+
+		{"LOG_LEVEL", &c.LogLevel, "log level: debug, info, warn or error"},
+		{"LOG_OUTPUT", &c.LogOutput, "log output: stdout, stderr or file"},
+		{"LOG_FORMAT", &c.LogFormat, "log format: json or text"},
+		{"LOG_FILE", &c.LogFile, "log file for LOG_OUTPUT=file"},
+		{"LOG_MAX_SIZE_MB", &c.LogMaxSizeMB, "rotate the log file at this size, 0 to disable"},
+		{"LOG_MAX_AGE", &c.LogMaxAge, "rotate the log file at this age, 0 to disable"},
+		{"LOG_MAX_BACKUPS", &c.LogMaxBackups, "rotated log files to keep, 0 to keep all"},
+		{"LOG_REDACT_FIELDS", &c.LogRedactFields, "comma-separated log attributes to redact"},
+		{"LOG_REDACT_QUERY_PARAMS", &c.LogRedactQueryParams, "comma-separated URL query parameters to redact in logs"},
+
+		{"GRPC_ENABLED", &c.GRPCEnabled, "serve the gRPC API"},
+		{"GRAPHQL_ENABLED", &c.GraphQLEnabled, "serve the GraphQL endpoint"},
+		{"SWAGGER_ENABLED", &c.SwaggerEnabled, "serve Swagger UI"},
+		{"METRICS_ENABLED", &c.MetricsEnabled, "serve Prometheus metrics on /metrics"},
+		{"METRICS_HOST", &c.MetricsHost, "internal address for metrics"},
+		{"METRICS_PORT", &c.MetricsPort, "internal port for metrics"},
+		{"METRICS_STATS_INTERVAL", &c.MetricsStatsInterval, "how often to recompute subscription metrics"},
+		{"DB_ROW_LEVEL_SECURITY", &c.DBRowLevelSecurity, "pass the tenant to PostgreSQL row-level security policies"},
+		{"REQUIRE_IF_MATCH", &c.RequireIfMatch, "require If-Match on subscription updates and deletes"},
+		{"CORS_ALLOWED_ORIGINS", &c.CORSAllowedOrigins, "comma-separated origins allowed to call the API from a browser, * for any"},
+		{"NOTIFY_WEBHOOK_URL", &c.NotifyWebhookURL, "URL to post subscription change events to, empty to disable"},
+		{"NOTIFY_WEBHOOK_SECRET", &c.NotifyWebhookSecret, "HMAC-SHA256 secret for the X-Signature header of events"},
+		{"NOTIFY_TIMEOUT", &c.NotifyTimeout, "timeout for sending one event"},
+		{"MIGRATE_ON_STARTUP", &c.MigrateOnStartup, "apply pending migrations on startup"},
+
+		{"AUTH_DISABLED", &c.AuthDisabled, "disable authentication"},
+		{"JWT_SECRET", &c.JWTSecret, "HS256 secret"},
+		{"JWT_PUBLIC_KEY_FILE", &c.JWTPublicKeyFile, "RS256 public key file"},
+		{"JWT_JWKS_FILE", &c.JWTJWKSFile, "JWKS file"},
+		{"JWT_ISSUER", &c.JWTIssuer, "expected token issuer"},
+		{"JWT_AUDIENCE", &c.JWTAudience, "expected token audience"},
+
+		{"RATE_LIMIT_STORE", &c.RateLimitStore, "rate limit store: memory, postgres or off"},
+		{"RATE_LIMIT_RPS", &c.RateLimitRPS, "requests per second per client"},
+		{"RATE_LIMIT_BURST", &c.RateLimitBurst, "request burst per client"},
+		{"RATE_LIMIT_SUM_RPS", &c.RateLimitSumRPS, "cost sum requests per second per client"},
+		{"RATE_LIMIT_SUM_BURST", &c.RateLimitSumBurst, "cost sum request burst per client"},
+		{"RATE_LIMIT_IP_RPS", &c.RateLimitIPRPS, "requests per second per IP address, checked before authentication"},
+		{"RATE_LIMIT_IP_BURST", &c.RateLimitIPBurst, "request burst per IP address"},
+
+		{"IDEMPOTENCY_TTL", &c.IdempotencyTTL, "how long Idempotency-Key responses are kept"},
+		{"IDEMPOTENCY_LEASE", &c.IdempotencyLease, "how long an unfinished Idempotency-Key request blocks retries"},
+		{"GRAPHQL_MAX_DEPTH", &c.GraphQLMaxDepth, "maximum GraphQL query depth"},
+		{"GRAPHQL_MAX_COMPLEXITY", &c.GraphQLMaxComplexity, "maximum GraphQL query complexity"},
+	}
+}
+
+// flagValue запоминает значения флагов, чтобы применить их после файла и окружения
+type flagValue struct {
+	setting setting
+	values  map[string]string
+}
+
+func (v flagValue) String() string { return "" }
+
+func (v flagValue) Set(s string) error {
+	v.values[v.setting.env] = s
+	return nil
+}
+
+func (v flagValue) IsBoolFlag() bool {
+	_, ok := v.setting.ptr.(*bool)
+	return ok
+}
+
+// readFile применяет настройки из YAML файла и возвращает значения, заданные в нем
+func readFile(path string, settings []setting) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	applied := map[string]string{}
+	var errs []error
+	for key, value := range values {
+		i := slices.IndexFunc(settings, func(s setting) bool { return s.key() == key })
+		if i < 0 {
+			errs = append(errs, fmt.Errorf("%s: unknown key %q", path, key))
+			continue
+		}
+		switch v := value.(type) {
+		case nil:
+			continue
+		case []any:
+			// Список в YAML равнозначен значению через запятую
+			if _, ok := settings[i].ptr.(*[]string); ok {
+				items := make([]string, len(v))
+				for j, item := range v {
+					items[j] = fmt.Sprint(item)
+				}
+				value = strings.Join(items, ",")
+				break
+			}
+			errs = append(errs, fmt.Errorf("%s: %s must be a scalar value", path, key))
+			continue
+		case map[string]any:
+			errs = append(errs, fmt.Errorf("%s: %s must be a scalar value", path, key))
+			continue
+		}
+		if err := settings[i].set(fmt.Sprint(value)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+			continue
+		}
+		applied[settings[i].env] = settings[i].value()
+	}
+	return applied, errors.Join(errs...)
+}
+
+// Load собирает конфигурацию: значения по умолчанию, затем YAML файл (-config или CONFIG_FILE),
+// затем переменные окружения и флаги командной строки. Каждый следующий источник
+// переопределяет предыдущий. Возвращает все ошибки разбора и проверки сразу.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	cfg := defaults()
+	settings := cfg.settings()
+
+	fs := flag.NewFlagSet("subscribe_aggregation", flag.ContinueOnError)
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "YAML config file")
+	flags := map[string]string{}
+	for _, s := range settings {
+		fs.Var(flagValue{setting: s, values: flags}, s.flag(), s.usage+" ("+s.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var fromFile map[string]string
+	if *configFile != "" {
+		var err error
+		if fromFile, err = readFile(*configFile, settings); err != nil {
+			return nil, err
+		}
+		cfg.ConfigFile = *configFile
+	}
+
+	var errs []error
+	for _, s := range settings {
+		if value := getenv(s.env); value != "" {
+			if err := s.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+		if value, ok := flags[s.env]; ok {
+			if err := s.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", s.flag(), err))
+			}
+		}
+		if value, ok := fromFile[s.env]; ok && value != s.value() {
+			cfg.Overridden = append(cfg.Overridden, s.env)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	cfg.PostgresDSN = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		quoteDSN(cfg.PostgresHost),
+		quoteDSN(cfg.PostgresPort),
+		quoteDSN(cfg.PostgresUser),
+		quoteDSN(cfg.PostgresPassword),
+		quoteDSN(cfg.PostgresDB),
+		quoteDSN(cfg.SSLMode),
+	)
+	return cfg, nil
+}
+
+// quoteDSN экранирует значение для строки подключения key=value
+func quoteDSN(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// validOrigin проверяет origin в формате scheme://host[:port] без пути
+func validOrigin(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.Path == "" && u.RawQuery == "" && u.Fragment == "" && u.User == nil
+}
+
+// validWebhookURL проверяет, что адрес вебхука — абсолютный http или https URL
+func validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+// validate проверяет значения настроек
+func (c *Config) validate() error {
+	var errs []error
+	check := func(ok bool, env, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: "+format, append([]any{env}, args...)...))
+		}
+	}
+
+	check(c.ConfigWatchInterval >= 0, "CONFIG_WATCH_INTERVAL", "must not be negative")
+	check(validPort(c.ServerPort), "SERVER_PORT", "must be a port number, got %q", c.ServerPort)
+	check(validPort(c.GRPCPort), "GRPC_PORT", "must be a port number, got %q", c.GRPCPort)
+	check(!c.GRPCEnabled || c.GRPCPort != c.ServerPort, "GRPC_PORT", "must differ from SERVER_PORT")
+	check(validPort(c.MetricsPort), "METRICS_PORT", "must be a port number, got %q", c.MetricsPort)
+	check(!c.MetricsEnabled || c.MetricsPort != c.ServerPort && !(c.GRPCEnabled && c.MetricsPort == c.GRPCPort),
+		"METRICS_PORT", "must differ from SERVER_PORT and GRPC_PORT")
+
+	check(c.PostgresHost != "", "POSTGRES_HOST", "is required")
+	check(validPort(c.PostgresPort), "POSTGRES_PORT", "must be a port number, got %q", c.PostgresPort)
+	check(c.PostgresUser != "", "POSTGRES_USER", "is required")
+	check(c.PostgresDB != "", "POSTGRES_DB", "is required")
+	check(slices.Contains([]string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}, c.SSLMode),
+		"SSL_MODE", "unknown sslmode %q", c.SSLMode)
+	check(c.DBMaxOpenConns > 0, "DB_MAX_OPEN_CONNS", "must be positive")
+	check(c.DBMaxIdleConns >= 0 && c.DBMaxIdleConns <= c.DBMaxOpenConns,
+		"DB_MAX_IDLE_CONNS", "must be between 0 and DB_MAX_OPEN_CONNS (%d)", c.DBMaxOpenConns)
+	check(c.DBConnMaxLifetime >= 0, "DB_CONN_MAX_LIFETIME", "must not be negative")
+
+	check(c.HTTPReadHeaderTimeout > 0, "HTTP_READ_HEADER_TIMEOUT", "must be positive")
+	check(c.HTTPReadTimeout > 0, "HTTP_READ_TIMEOUT", "must be positive")
+	check(c.HTTPWriteTimeout > 0, "HTTP_WRITE_TIMEOUT", "must be positive")
+	check(c.HTTPIdleTimeout > 0, "HTTP_IDLE_TIMEOUT", "must be positive")
+	check(c.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT", "must be positive")
+
+	c.LogLevel = strings.ToLower(c.LogLevel)
+	check(slices.Contains([]string{"debug", "info", "warn", "error"}, c.LogLevel),
+		"LOG_LEVEL", "must be debug, info, warn or error, got %q", c.LogLevel)
+	check(slices.Contains([]string{"stdout", "stderr", "file"}, c.LogOutput),
+		"LOG_OUTPUT", "must be stdout, stderr or file, got %q", c.LogOutput)
+	check(c.LogFormat == "json" || c.LogFormat == "text", "LOG_FORMAT", "must be json or text, got %q", c.LogFormat)
+	check(c.LogOutput != "file" || c.LogFile != "", "LOG_FILE", "is required for LOG_OUTPUT=file")
+	check(c.LogMaxSizeMB >= 0, "LOG_MAX_SIZE_MB", "must not be negative")
+	check(c.LogMaxAge >= 0, "LOG_MAX_AGE", "must not be negative")
+	check(c.LogMaxBackups >= 0, "LOG_MAX_BACKUPS", "must not be negative")
+
+	check(slices.Contains([]string{"", "memory", "postgres", "off"}, c.RateLimitStore),
+		"RATE_LIMIT_STORE", "must be memory, postgres or off, got %q", c.RateLimitStore)
+	check(c.RateLimitRPS > 0, "RATE_LIMIT_RPS", "must be positive")
+	check(c.RateLimitBurst > 0, "RATE_LIMIT_BURST", "must be positive")
+	check(c.RateLimitSumRPS > 0, "RATE_LIMIT_SUM_RPS", "must be positive")
+	check(c.RateLimitSumBurst > 0, "RATE_LIMIT_SUM_BURST", "must be positive")
+	check(c.RateLimitIPRPS > 0, "RATE_LIMIT_IP_RPS", "must be positive")
+	check(c.RateLimitIPBurst > 0, "RATE_LIMIT_IP_BURST", "must be positive")
+
+	for _, origin := range c.CORSAllowedOrigins {
+		check(validOrigin(origin), "CORS_ALLOWED_ORIGINS", "must contain * or origins like https://example.com, got %q", origin)
+	}
+
+	check(c.NotifyWebhookURL == "" || validWebhookURL(c.NotifyWebhookURL),
+		"NOTIFY_WEBHOOK_URL", "must be an http or https URL, got %q", c.NotifyWebhookURL)
+	check(c.NotifyTimeout > 0, "NOTIFY_TIMEOUT", "must be positive")
+
+	check(c.IdempotencyTTL > 0, "IDEMPOTENCY_TTL", "must be positive")
+	check(c.IdempotencyLease > c.HTTPWriteTimeout, "IDEMPOTENCY_LEASE", "must be greater than HTTP_WRITE_TIMEOUT")
+	check(c.GraphQLMaxDepth > 0, "GRAPHQL_MAX_DEPTH", "must be positive")
+	check(c.GraphQLMaxComplexity > 0, "GRAPHQL_MAX_COMPLEXITY", "must be positive")
+	check(c.MetricsStatsInterval > 0, "METRICS_STATS_INTERVAL", "must be positive")
+
+	return errors.Join(errs...)
+}
+
+// ValidateAuth проверяет настройки аутентификации. Они нужны только серверу API,
+// поэтому не входят в validate: команде migrations достаточно настроек базы.
+func (c *Config) ValidateAuth() error {
+	if c.AuthDisabled || c.JWTSecret != "" || c.JWTPublicKeyFile != "" || c.JWTJWKSFile != "" {
+		return nil
+	}
+	return errors.New("JWT_SECRET: JWT_SECRET, JWT_PUBLIC_KEY_FILE or JWT_JWKS_FILE is required unless AUTH_DISABLED=true")
 }
 
 // loadEnv загружает .env один раз
@@ -34,39 +572,26 @@ func loadEnv() {
 	}
 }
 
-// LoadConfig инициализирует Config с параметрами из env с дефолтами
-func LoadConfig() *Config {
+// Init загружает конфигурацию с флагами args и завершает процесс при ошибке.
+// Повторные вызовы ничего не делают.
+func Init(args []string) {
 	once.Do(func() {
 		loadEnv()
 
-		sslMode := os.Getenv("SSL_MODE")
-		if sslMode == "" {
-			sslMode = "disable"
-		}
-		serverHost := os.Getenv("SERVER_HOST")
-		if serverHost == "" {
-			serverHost = "0.0.0.0"
+		cfg, err := Load(args, os.Getenv)
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
 		}
-		serverPort := os.Getenv("SERVER_PORT")
-		if serverPort == "" {
-			serverPort = "8080"
-		}
-
-		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
-			os.Getenv("POSTGRES_HOST"),
-			os.Getenv("POSTGRES_USER"),
-			os.Getenv("POSTGRES_PASSWORD"),
-			os.Getenv("POSTGRES_DB"),
-			os.Getenv("POSTGRES_PORT"),
-			sslMode,
-		)
-
-		ConfigInstance = &Config{
-			ServerHost:  serverHost,
-			ServerPort:  serverPort,
-			PostgresDSN: dsn,
+		if err != nil {
+			log.Fatalf("invalid configuration:\n%v", err)
 		}
+		ConfigInstance = cfg
 	})
+}
+
+// LoadConfig возвращает конфигурацию, загружая ее без флагов, если Init еще не вызывался
+func LoadConfig() *Config {
+	Init(nil)
 	return ConfigInstance
 }
 
@@ -81,5 +606,8 @@ func InitDB() {
 	if err := db.Ping(); err != nil {
 		log.Fatalf("failed to ping db: %v", err)
 	}
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
 	DB = db
 }
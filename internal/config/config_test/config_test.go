@@ -0,0 +1,244 @@
+package config_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"subscribe_aggregation-main/internal/config"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getenv(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, `
+postgres_host: file-host
+postgres_user: web
+postgres_db: subs
+server_port: 8081
+grpc_port: 9091
+http_read_timeout: 20s
+graphql_enabled: false
+jwt_secret:
+log_redact_fields: [user_id, email]
+`)
+	env := map[string]string{
+		"CONFIG_FILE":   path,
+		"SERVER_PORT":   "8082",
+		"POSTGRES_HOST": "env-host",
+
+		"LOG_REDACT_QUERY_PARAMS": "token, ,user_id",
+	}
+
+	cfg, err := config.Load([]string{"-server-port", "8083", "-auth-disabled"}, getenv(env))
+	require.NoError(t, err)
+
+	assert.Equal(t, "8083", cfg.ServerPort)
+	assert.Equal(t, "9091", cfg.GRPCPort)
+	assert.Equal(t, "env-host", cfg.PostgresHost)
+	assert.Equal(t, 20*time.Second, cfg.HTTPReadTimeout)
+	assert.False(t, cfg.GraphQLEnabled)
+	assert.True(t, cfg.AuthDisabled)
+	assert.True(t, cfg.SwaggerEnabled)
+	assert.Equal(t, 25, cfg.DBMaxOpenConns)
+	assert.Equal(t, []string{"user_id", "email"}, cfg.LogRedactFields)
+	assert.Equal(t, []string{"token", "user_id"}, cfg.LogRedactQueryParams)
+	assert.Equal(t, "host='env-host' port='5432' user='web' password='' dbname='subs' sslmode='disable'", cfg.PostgresDSN)
+}
+
+func TestLoadValidation(t *testing.T) {
+	_, err := config.Load(nil, getenv(map[string]string{
+		"SERVER_PORT":       "http",
+		"DB_MAX_IDLE_CONNS": "50",
+		"LOG_LEVEL":         "verbose",
+		"RATE_LIMIT_STORE":  "redis",
+		"LOG_OUTPUT":        "syslog",
+	}))
+	require.Error(t, err)
+	for _, msg := range []string{"SERVER_PORT", "POSTGRES_HOST: is required", "DB_MAX_IDLE_CONNS", "LOG_LEVEL", "RATE_LIMIT_STORE", "LOG_OUTPUT"} {
+		assert.ErrorContains(t, err, msg)
+	}
+
+	// Метрики не отдаются на порту API
+	_, err = config.Load(nil, getenv(map[string]string{"METRICS_ENABLED": "true", "METRICS_PORT": "8080"}))
+	assert.ErrorContains(t, err, "METRICS_PORT: must differ from SERVER_PORT")
+
+	_, err = config.Load(nil, getenv(map[string]string{"CORS_ALLOWED_ORIGINS": "https://app.example.com, app.example.com/"}))
+	assert.ErrorContains(t, err, `CORS_ALLOWED_ORIGINS: must contain * or origins like https://example.com, got "app.example.com/"`)
+	assert.NotContains(t, err.Error(), `"https://app.example.com"`)
+
+	_, err = config.Load(nil, getenv(map[string]string{"NOTIFY_WEBHOOK_URL": "hooks.example.com/subscriptions", "NOTIFY_TIMEOUT": "0s"}))
+	assert.ErrorContains(t, err, `NOTIFY_WEBHOOK_URL: must be an http or https URL, got "hooks.example.com/subscriptions"`)
+	assert.ErrorContains(t, err, "NOTIFY_TIMEOUT: must be positive")
+
+	_, err = config.Load(nil, getenv(map[string]string{"IDEMPOTENCY_TTL": "day"}))
+	assert.ErrorContains(t, err, `IDEMPOTENCY_TTL: invalid value "day"`)
+
+	_, err = config.Load([]string{"-config", writeFile(t, "server_prot: 8080\n")}, getenv(nil))
+	assert.ErrorContains(t, err, `unknown key "server_prot"`)
+
+	_, err = config.Load([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, getenv(nil))
+	assert.ErrorContains(t, err, "read config file")
+}
+
+func TestLoadWithoutAuth(t *testing.T) {
+	// Команде migrations достаточно настроек базы
+	cfg, err := config.Load(nil, getenv(map[string]string{"POSTGRES_HOST": "db", "POSTGRES_USER": "web", "POSTGRES_DB": "subs"}))
+	require.NoError(t, err)
+	assert.ErrorContains(t, cfg.ValidateAuth(), "JWT_SECRET")
+
+	cfg.AuthDisabled = true
+	assert.NoError(t, cfg.ValidateAuth())
+}
+
+// TestInitDBWithoutAuth подключается к базе из DATABASE_URL без настроек аутентификации, как команда migrations
+func TestInitDBWithoutAuth(t *testing.T) {
+	dsn, err := url.Parse(os.Getenv("DATABASE_URL"))
+	if err != nil || dsn.Host == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+	password, _ := dsn.User.Password()
+	sslMode := dsn.Query().Get("sslmode")
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	for key, value := range map[string]string{
+		"POSTGRES_HOST":       dsn.Hostname(),
+		"POSTGRES_PORT":       dsn.Port(),
+		"POSTGRES_USER":       dsn.User.Username(),
+		"POSTGRES_PASSWORD":   password,
+		"POSTGRES_DB":         strings.TrimPrefix(dsn.Path, "/"),
+		"SSL_MODE":            sslMode,
+		"AUTH_DISABLED":       "",
+		"JWT_SECRET":          "",
+		"JWT_PUBLIC_KEY_FILE": "",
+		"JWT_JWKS_FILE":       "",
+	} {
+		t.Setenv(key, value)
+	}
+
+	config.InitDB()
+	t.Cleanup(func() { config.DB.Close() })
+	require.NoError(t, config.DB.Ping())
+}
+
+func TestWatcherReload(t *testing.T) {
+	base := "postgres_host: db\npostgres_user: web\npostgres_db: subs\n"
+	path := writeFile(t, base+"log_level: info\nrate_limit_rps: 10\n")
+	env := getenv(map[string]string{"CONFIG_FILE": path})
+
+	cfg, err := config.Load(nil, env)
+	require.NoError(t, err)
+	watcher := config.NewWatcher(cfg, nil, env)
+	var applied *config.Config
+	watcher.OnReload(func(cfg *config.Config) { applied = cfg })
+
+	var logs bytes.Buffer
+	logger := logging.GetLogger()
+	logging.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { logging.SetLogger(logger) })
+
+	require.NoError(t, os.WriteFile(path, []byte(base+"log_level: DEBUG\nrate_limit_rps: 2.5\nserver_port: 9000\n"+
+		"cors_allowed_origins: [https://app.example.com, https://admin.example.com]\n"), 0o600))
+	changes, err := watcher.Reload(context.Background())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []config.Change{
+		{Name: "LOG_LEVEL", Old: "info", New: "debug"},
+		{Name: "RATE_LIMIT_RPS", Old: "10", New: "2.5"},
+		{Name: "CORS_ALLOWED_ORIGINS", Old: "", New: "https://app.example.com,https://admin.example.com"},
+	}, changes)
+
+	// Порт нельзя поменять без перезапуска
+	require.Same(t, applied, watcher.Current())
+	assert.Equal(t, "debug", applied.LogLevel)
+	assert.Equal(t, 2.5, applied.RateLimitRPS)
+	assert.Equal(t, []string{"https://app.example.com", "https://admin.example.com"}, applied.CORSAllowedOrigins)
+	assert.Equal(t, "8080", applied.ServerPort)
+	assert.Contains(t, logs.String(), "restart required")
+	assert.Contains(t, logs.String(), "SERVER_PORT")
+	assert.Equal(t, "info", cfg.LogLevel)
+
+	// Неверная конфигурация не применяется
+	require.NoError(t, os.WriteFile(path, []byte(base+"log_level: loud\n"), 0o600))
+	_, err = watcher.Reload(context.Background())
+	assert.ErrorContains(t, err, "LOG_LEVEL")
+	assert.Equal(t, "debug", watcher.Current().LogLevel)
+}
+
+func TestWatcherReloadNotify(t *testing.T) {
+	base := "postgres_host: db\npostgres_user: web\npostgres_db: subs\n"
+	path := writeFile(t, base+"notify_webhook_secret: old-secret\n")
+	env := getenv(map[string]string{"CONFIG_FILE": path})
+
+	cfg, err := config.Load(nil, env)
+	require.NoError(t, err)
+	watcher := config.NewWatcher(cfg, nil, env)
+
+	var logs bytes.Buffer
+	logger := logging.GetLogger()
+	logging.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { logging.SetLogger(logger) })
+
+	require.NoError(t, os.WriteFile(path, []byte(base+"notify_webhook_url: https://hooks.example.com/subscriptions\n"+
+		"notify_webhook_secret: new-secret\nnotify_timeout: 2s\n"), 0o600))
+	changes, err := watcher.Reload(context.Background())
+	require.NoError(t, err)
+	// Секрет не попадает в изменения и лог
+	assert.ElementsMatch(t, []config.Change{
+		{Name: "NOTIFY_WEBHOOK_URL", Old: "", New: "https://hooks.example.com/subscriptions"},
+		{Name: "NOTIFY_WEBHOOK_SECRET", Old: "***", New: "***"},
+		{Name: "NOTIFY_TIMEOUT", Old: "5s", New: "2s"},
+	}, changes)
+	assert.NotContains(t, logs.String(), "old-secret")
+	assert.NotContains(t, logs.String(), "new-secret")
+
+	current := watcher.Current()
+	assert.Equal(t, "https://hooks.example.com/subscriptions", current.NotifyWebhookURL)
+	assert.Equal(t, "new-secret", current.NotifyWebhookSecret)
+	assert.Equal(t, 2*time.Second, current.NotifyTimeout)
+}
+
+func TestWatcherReloadOverridden(t *testing.T) {
+	base := "postgres_host: db\npostgres_user: web\npostgres_db: subs\n"
+	path := writeFile(t, base+"log_level: info\nrate_limit_rps: 10\n")
+	env := getenv(map[string]string{"CONFIG_FILE": path, "LOG_LEVEL": "warn"})
+
+	cfg, err := config.Load(nil, env)
+	require.NoError(t, err)
+	assert.Equal(t, "warn", cfg.LogLevel)
+	assert.Equal(t, []string{"LOG_LEVEL"}, cfg.Overridden)
+
+	var logs bytes.Buffer
+	logger := logging.GetLogger()
+	logging.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { logging.SetLogger(logger) })
+
+	// Окружение важнее файла и при перезагрузке, об этом пишется предупреждение
+	watcher := config.NewWatcher(cfg, nil, env)
+	require.NoError(t, os.WriteFile(path, []byte(base+"log_level: debug\nrate_limit_rps: 5\n"), 0o600))
+	changes, err := watcher.Reload(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []config.Change{{Name: "RATE_LIMIT_RPS", Old: "10", New: "5"}}, changes)
+	assert.Equal(t, "warn", watcher.Current().LogLevel)
+	assert.Contains(t, logs.String(), "LOG_LEVEL")
+	assert.Contains(t, logs.String(), "not reloadable")
+}
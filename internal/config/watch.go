@@ -0,0 +1,185 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"subscribe_aggregation-main/pkg/logging"
+)
+
+// reloadable — настройки, которые можно безопасно менять без перезапуска.
+// Изменение остальных не применяется до перезапуска, о каждом пишется предупреждение в лог.
+var reloadable = []string{
+	"LOG_LEVEL",
+	"RATE_LIMIT_RPS",
+	"RATE_LIMIT_BURST",
+	"RATE_LIMIT_SUM_RPS",
+	"RATE_LIMIT_SUM_BURST",
+	"RATE_LIMIT_IP_RPS",
+	"RATE_LIMIT_IP_BURST",
+	"CORS_ALLOWED_ORIGINS",
+	"NOTIFY_WEBHOOK_URL",
+	"NOTIFY_WEBHOOK_SECRET",
+	"NOTIFY_TIMEOUT",
+}
+
+// secret — перезагружаемые настройки, значения которых не пишутся в лог
+var secret = []string{
+	"NOTIFY_WEBHOOK_SECRET",
+}
+
+// hiddenValue заменяет значение секретной настройки в Change
+const hiddenValue = "***"
+
+// Change — изменение одной настройки при перезагрузке. Значения секретных настроек скрыты.
+type Change struct {
+	Name     string
+	Old, New string
+}
+
+// Watcher перечитывает конфигурацию по сигналу или при изменении файла и передает
+// подписчикам новую конфигурацию, в которой изменены только безопасные настройки.
+type Watcher struct {
+	args   []string
+	getenv func(string) string
+
+	mu        sync.Mutex // сериализует перезагрузки
+	current   atomic.Pointer[Config]
+	callbacks []func(*Config)
+}
+
+// NewWatcher создает Watcher для конфигурации cfg, загруженной с флагами args
+func NewWatcher(cfg *Config, args []string, getenv func(string) string) *Watcher {
+	w := &Watcher{args: args, getenv: getenv}
+	w.current.Store(cfg)
+	return w
+}
+
+// Current возвращает действующую конфигурацию
+func (w *Watcher) Current() *Config {
+	return w.current.Load()
+}
+
+// OnReload регистрирует функцию, которая применяет новую конфигурацию.
+// Регистрировать функции нужно до запуска Run.
+func (w *Watcher) OnReload(fn func(*Config)) {
+	w.callbacks = append(w.callbacks, fn)
+}
+
+// Reload загружает конфигурацию заново и применяет изменения безопасных настроек.
+// При ошибке загрузки действующая конфигурация не меняется.
+func (w *Watcher) Reload(ctx context.Context) ([]Change, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	loaded, err := Load(w.args, w.getenv)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := logging.GetLogger()
+	// Значение из файла не действует, пока настройка задана в окружении или флагом
+	for _, env := range loaded.Overridden {
+		if slices.Contains(reloadable, env) {
+			logger.WarnContext(ctx, "Config: setting is overridden by environment or flag and is not reloadable",
+				slog.String("setting", env),
+				slog.String("file", loaded.ConfigFile))
+		}
+	}
+
+	old := w.current.Load()
+	next := *old
+	next.Overridden = loaded.Overridden
+	var changes []Change
+	oldSettings, loadedSettings, nextSettings := old.settings(), loaded.settings(), next.settings()
+	for i, s := range oldSettings {
+		oldValue, newValue := s.value(), loadedSettings[i].value()
+		if oldValue == newValue {
+			continue
+		}
+		if !slices.Contains(reloadable, s.env) {
+			logger.WarnContext(ctx, "Config: setting changed, restart required to apply it",
+				slog.String("setting", s.env))
+			continue
+		}
+		nextSettings[i].set(newValue)
+		if slices.Contains(secret, s.env) {
+			oldValue, newValue = hiddenValue, hiddenValue
+		}
+		changes = append(changes, Change{Name: s.env, Old: oldValue, New: newValue})
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	w.current.Store(&next)
+	for _, fn := range w.callbacks {
+		fn(&next)
+	}
+	return changes, nil
+}
+
+// reload перезагружает конфигурацию и пишет в лог результат
+func (w *Watcher) reload(ctx context.Context, reason string) {
+	logger := logging.GetLogger()
+	changes, err := w.Reload(ctx)
+	if err != nil {
+		logger.ErrorContext(ctx, "Config: reload failed, keeping current config",
+			slog.String("reason", reason),
+			slog.String("error", err.Error()))
+		return
+	}
+	if len(changes) == 0 {
+		logger.InfoContext(ctx, "Config: reloaded without changes", slog.String("reason", reason))
+		return
+	}
+	for _, c := range changes {
+		logger.InfoContext(ctx, "Config: reloaded",
+			slog.String("reason", reason),
+			slog.String("setting", c.Name),
+			slog.String("old", c.Old),
+			slog.String("new", c.New))
+	}
+}
+
+// fileState возвращает время изменения и размер файла для обнаружения изменений
+func fileState(path string) (time.Time, int64) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, -1
+	}
+	return info.ModTime(), info.Size()
+}
+
+// Run перезагружает конфигурацию по каждому сигналу из signals и при изменении
+// файла конфигурации, пока не отменен ctx
+func (w *Watcher) Run(ctx context.Context, signals <-chan os.Signal) {
+	cfg := w.Current()
+	var poll <-chan time.Time
+	if cfg.ConfigFile != "" && cfg.ConfigWatchInterval > 0 {
+		ticker := time.NewTicker(cfg.ConfigWatchInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	modTime, size := fileState(cfg.ConfigFile)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			w.reload(ctx, fmt.Sprint(sig))
+		case <-poll:
+			if t, n := fileState(cfg.ConfigFile); !t.Equal(modTime) || n != size {
+				modTime, size = t, n
+				w.reload(ctx, "file changed")
+			}
+		}
+	}
+}
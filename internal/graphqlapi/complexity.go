@@ -0,0 +1,177 @@
+package graphqlapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"subscribe_aggregation-main/internal/ratelimit"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Limits — ограничения запроса, которые защищают базу от слишком тяжелых запросов
+type Limits struct {
+	// MaxDepth — наибольшая вложенность полей
+	MaxDepth int
+	// MaxComplexity — наибольшая стоимость запроса: каждое поле стоит 1, суммы стоимости —
+	// aggregateComplexity, а поля списков умножают стоимость вложенных полей на размер страницы
+	MaxComplexity int
+	// SumLimiter — квота на расчет суммы, общая с REST и gRPC API. Списывается за каждую
+	// вычисленную сумму, nil ничего не ограничивает
+	SumLimiter *ratelimit.Limiter
+}
+
+// aggregateComplexity — стоимость полей total и byCategory сводки стоимости. Каждое из них —
+// отдельный агрегирующий запрос к базе, поэтому оно стоит как запрос суммы REST API
+// в пределах запаса квоты: users с сотней ID и суммами не проходит ограничение по умолчанию
+const aggregateComplexity = 20
+
+// analysis считает глубину и стоимость выбранной операции
+type analysis struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+	// defaults — значения переменных по умолчанию из объявления операции
+	defaults map[string]ast.Value
+	maxDepth int
+}
+
+// checkLimits проверяет операцию запроса до выполнения. Документ уже прошел валидацию,
+// поэтому фрагменты существуют и не содержат циклов.
+func checkLimits(doc *ast.Document, operationName string, variables map[string]any, limits Limits) error {
+	a := &analysis{fragments: map[string]*ast.FragmentDefinition{}, variables: variables}
+
+	var operation *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			a.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				operation = def
+			}
+		}
+	}
+	if operation == nil {
+		return nil
+	}
+	a.defaults = map[string]ast.Value{}
+	for _, def := range operation.VariableDefinitions {
+		if def.DefaultValue != nil {
+			a.defaults[def.Variable.Name.Value] = def.DefaultValue
+		}
+	}
+
+	complexity := a.selectionSet(operation.SelectionSet, 1, "")
+	if limits.MaxDepth > 0 && a.maxDepth > limits.MaxDepth {
+		return &queryError{
+			message: fmt.Sprintf("query depth %d exceeds the limit of %d", a.maxDepth, limits.MaxDepth),
+			code:    CodeQueryTooDeep,
+		}
+	}
+	if limits.MaxComplexity > 0 && complexity > limits.MaxComplexity {
+		return &queryError{
+			message: fmt.Sprintf("query complexity %d exceeds the limit of %d", complexity, limits.MaxComplexity),
+			code:    CodeQueryTooComplex,
+		}
+	}
+	return nil
+}
+
+// selectionSet возвращает стоимость полей на глубине depth вместе с вложенными.
+// parent — имя поля, которому принадлежит набор, пустое для корня операции
+func (a *analysis) selectionSet(set *ast.SelectionSet, depth int, parent string) int {
+	if set == nil {
+		return 0
+	}
+
+	total := 0
+	for _, selection := range set.Selections {
+		switch s := selection.(type) {
+		case *ast.Field:
+			// Интроспекция не обращается к базе и не ограничивается
+			if strings.HasPrefix(s.Name.Value, "__") {
+				continue
+			}
+			a.maxDepth = max(a.maxDepth, depth)
+			total += fieldComplexity(parent, s) + a.multiplier(s)*a.selectionSet(s.SelectionSet, depth+1, s.Name.Value)
+		case *ast.InlineFragment:
+			total += a.selectionSet(s.SelectionSet, depth, parent)
+		case *ast.FragmentSpread:
+			if fragment, ok := a.fragments[s.Name.Value]; ok {
+				total += a.selectionSet(fragment.SelectionSet, depth, parent)
+			}
+		}
+	}
+	return total
+}
+
+// fieldComplexity возвращает стоимость самого поля без вложенных
+func fieldComplexity(parent string, f *ast.Field) int {
+	if parent == "cost" && (f.Name.Value == "total" || f.Name.Value == "byCategory") {
+		return aggregateComplexity
+	}
+	return 1
+}
+
+// multiplier — сколько раз вычисляются вложенные поля: размер страницы для списка подписок
+// и число ID для списка пользователей
+func (a *analysis) multiplier(f *ast.Field) int {
+	switch f.Name.Value {
+	case "subscriptions":
+		limit := defaultLimit
+		if v, ok := a.argument(f, "limit").(int); ok {
+			limit = v
+		}
+		return min(max(limit, 1), maxLimit)
+	case "users":
+		if ids, ok := a.argument(f, "ids").([]any); ok {
+			return min(max(len(ids), 1), maxLimit)
+		}
+	}
+	return 1
+}
+
+// argument возвращает значение аргумента поля: int для чисел, []any для списков
+// и nil, если аргумент не передан или его тип не важен для подсчета
+func (a *analysis) argument(f *ast.Field, name string) any {
+	for _, arg := range f.Arguments {
+		if arg.Name.Value == name {
+			return a.value(arg.Value)
+		}
+	}
+	return nil
+}
+
+func (a *analysis) value(v ast.Value) any {
+	switch v := v.(type) {
+	case *ast.IntValue:
+		n, err := strconv.Atoi(v.Value)
+		if err != nil {
+			return nil
+		}
+		return n
+	case *ast.ListValue:
+		values := make([]any, len(v.Values))
+		for i, item := range v.Values {
+			values[i] = a.value(item)
+		}
+		return values
+	case *ast.Variable:
+		// Как и при выполнении, без значения в запросе или с null берется значение по умолчанию
+		value := a.variables[v.Name.Value]
+		if def, ok := a.defaults[v.Name.Value]; ok && value == nil {
+			return a.value(def)
+		}
+		// Переменные приходят из JSON, поэтому числа в них — float64
+		switch value := value.(type) {
+		case float64:
+			return int(value)
+		case int:
+			return value
+		case []any:
+			return value
+		}
+	}
+	return nil
+}
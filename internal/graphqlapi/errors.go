@@ -0,0 +1,64 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"subscribe_aggregation-main/internal/auth"
+	"subscribe_aggregation-main/internal/storage"
+	"subscribe_aggregation-main/pkg/logging"
+)
+
+// Коды ошибок в extensions.code ответа. Клиенты сравнивают их, а не текст message
+const (
+	CodeInvalidArgument = "BAD_USER_INPUT"
+	CodeForbidden       = "FORBIDDEN"
+	CodeQueryTooDeep    = "QUERY_TOO_DEEP"
+	CodeQueryTooComplex = "QUERY_TOO_COMPLEX"
+	CodeRateLimited     = "RATE_LIMITED"
+	CodeInternal        = "INTERNAL_SERVER_ERROR"
+)
+
+// queryError — ошибка с кодом, который попадает в extensions ответа
+type queryError struct {
+	message string
+	code    string
+}
+
+func (e *queryError) Error() string { return e.message }
+
+// Extensions реализует gqlerrors.ExtendedError
+func (e *queryError) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+func invalidArgument(message string) error {
+	return &queryError{message: message, code: CodeInvalidArgument}
+}
+
+func forbidden(message string) error {
+	return &queryError{message: message, code: CodeForbidden}
+}
+
+// requireScope проверяет право API ключа на поле.
+// Пользователи с JWT и запросы без аутентификации не ограничиваются, как в RequireScope REST API.
+func requireScope(ctx context.Context, scope string) error {
+	principal := auth.FromContext(ctx)
+	if principal != nil && principal.IsService() && !principal.HasScope(scope) {
+		return forbidden("scope " + scope + " required")
+	}
+	return nil
+}
+
+// storageError переводит ошибку хранилища в ошибку поля.
+// Неизвестные ошибки логируются, а клиент получает сообщение без подробностей.
+func storageError(ctx context.Context, op string, err error) error {
+	if errors.Is(err, storage.ErrInvalidInput) {
+		return invalidArgument(err.Error())
+	}
+
+	logging.GetLogger().ErrorContext(ctx, op+": internal error",
+		slog.String("error", err.Error()))
+	return &queryError{message: "internal server error", code: CodeInternal}
+}
@@ -0,0 +1,228 @@
+package graphqlapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"subscribe_aggregation-main/internal/auth"
+	"subscribe_aggregation-main/internal/graphqlapi"
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/ratelimit"
+	"subscribe_aggregation-main/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStorage хранит подписки в памяти. Методы, которые резолверы не вызывают,
+// остаются у встроенного nil интерфейса и паникуют при вызове.
+type fakeStorage struct {
+	storage.StorageInterface
+	subs []models.Subscription
+}
+
+func (f *fakeStorage) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	for i := range f.subs {
+		if f.subs[i].ID == id {
+			sub := f.subs[i]
+			return &sub, nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (f *fakeStorage) ListSubscriptions(ctx context.Context, filter storage.ListFilter) ([]models.Subscription, error) {
+	var result []models.Subscription
+	for _, sub := range f.subs {
+		if filter.UserID == "" || sub.UserID.String() == filter.UserID {
+			result = append(result, sub)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeStorage) SumSubscriptionsCost(ctx context.Context, userID, serviceName string, filterStart, filterEnd time.Time) (int64, error) {
+	var total int64
+	for _, sub := range f.subs {
+		if userID == "" || sub.UserID.String() == userID {
+			total += int64(sub.Price)
+		}
+	}
+	return total, nil
+}
+
+func (f *fakeStorage) SumSubscriptionsCostByCategory(ctx context.Context, userID, serviceName string, filterStart, filterEnd time.Time) (map[string]int64, error) {
+	categories := map[string]int64{}
+	for _, sub := range f.subs {
+		if userID == "" || sub.UserID.String() == userID {
+			for _, tag := range sub.Tags {
+				categories[tag] += int64(sub.Price)
+			}
+		}
+	}
+	return categories, nil
+}
+
+type response struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func query(t *testing.T, handler http.Handler, principal *auth.Principal, q string, variables map[string]any) (int, response) {
+	t.Helper()
+	body, err := json.Marshal(graphqlapi.Request{Query: q, Variables: variables})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	if principal != nil {
+		req = req.WithContext(auth.NewContext(req.Context(), principal))
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var resp response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	return rr.Code, resp
+}
+
+func TestGraphQL(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	store := &fakeStorage{subs: []models.Subscription{
+		{ID: uuid.New(), UserID: owner, ServiceName: "Netflix", Price: 500, Tags: []string{"video"}},
+		{ID: uuid.New(), UserID: owner, ServiceName: "Spotify", Price: 200, Tags: []string{"music"}},
+		{ID: uuid.New(), UserID: other, ServiceName: "Netflix", Price: 500, Tags: []string{"video"}},
+	}}
+	handler, err := graphqlapi.NewHandler(store, graphqlapi.Limits{MaxDepth: 5, MaxComplexity: 100})
+	require.NoError(t, err)
+
+	ownerPrincipal := &auth.Principal{UserID: owner}
+
+	t.Run("user summary in one request", func(t *testing.T) {
+		code, resp := query(t, handler, ownerPrincipal, `query($id: ID!) {
+			user(id: $id) {
+				subscriptions(limit: 5) { serviceName price }
+				cost { total byCategory { category total } }
+			}
+		}`, map[string]any{"id": owner.String()})
+		require.Equal(t, http.StatusOK, code)
+		require.Empty(t, resp.Errors)
+
+		user := resp.Data["user"].(map[string]any)
+		assert.Len(t, user["subscriptions"], 2)
+		cost := user["cost"].(map[string]any)
+		assert.Equal(t, float64(700), cost["total"])
+		assert.Equal(t, []any{
+			map[string]any{"category": "music", "total": float64(200)},
+			map[string]any{"category": "video", "total": float64(500)},
+		}, cost["byCategory"])
+	})
+
+	t.Run("user sees only own subscriptions", func(t *testing.T) {
+		_, resp := query(t, handler, ownerPrincipal, `{ subscriptions { userId } }`, nil)
+		for _, sub := range resp.Data["subscriptions"].([]any) {
+			assert.Equal(t, owner.String(), sub.(map[string]any)["userId"])
+		}
+
+		code, resp := query(t, handler, ownerPrincipal, `query($id: ID!) { user(id: $id) { id } }`, map[string]any{"id": other.String()})
+		assert.Equal(t, http.StatusOK, code)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, graphqlapi.CodeForbidden, resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("explicit null page and limit use defaults", func(t *testing.T) {
+		_, resp := query(t, handler, ownerPrincipal, `query($page: Int, $limit: Int) { subscriptions(page: $page, limit: $limit) { id } }`,
+			map[string]any{"page": nil, "limit": nil})
+		require.Empty(t, resp.Errors)
+		assert.Len(t, resp.Data["subscriptions"], 2)
+	})
+
+	t.Run("foreign subscription is null", func(t *testing.T) {
+		_, resp := query(t, handler, ownerPrincipal, `query($id: ID!) { subscription(id: $id) { id } }`,
+			map[string]any{"id": store.subs[2].ID.String()})
+		assert.Empty(t, resp.Errors)
+		assert.Nil(t, resp.Data["subscription"])
+	})
+
+	t.Run("API key needs reports scope for cost", func(t *testing.T) {
+		key := &auth.Principal{APIKeyID: uuid.New(), Scopes: []string{auth.ScopeSubscriptionsRead}}
+		_, resp := query(t, handler, key, `{ cost { total } }`, nil)
+		require.NotEmpty(t, resp.Errors)
+		assert.Equal(t, graphqlapi.CodeForbidden, resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("too complex", func(t *testing.T) {
+		code, resp := query(t, handler, ownerPrincipal, `query($limit: Int) {
+			subscriptions(limit: $limit) { id serviceName price tags }
+		}`, map[string]any{"limit": 50})
+		assert.Equal(t, http.StatusBadRequest, code)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, graphqlapi.CodeQueryTooComplex, resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("too complex with default variable values", func(t *testing.T) {
+		code, resp := query(t, handler, ownerPrincipal, `query($limit: Int = 50) {
+			subscriptions(limit: $limit) { id serviceName price tags }
+		}`, nil)
+		assert.Equal(t, http.StatusBadRequest, code)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, graphqlapi.CodeQueryTooComplex, resp.Errors[0].Extensions["code"])
+
+		// null в переменной тоже заменяется значением по умолчанию
+		code, resp = query(t, handler, ownerPrincipal, `query($ids: [ID!] = ["1", "2", "3", "4", "5", "6", "7", "8", "9", "10"], $limit: Int = 10) {
+			users(ids: $ids) { subscriptions(limit: $limit) { id serviceName price tags } }
+		}`, map[string]any{"limit": nil})
+		assert.Equal(t, http.StatusBadRequest, code)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, graphqlapi.CodeQueryTooComplex, resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("cost of many users is too complex", func(t *testing.T) {
+		handler, err := graphqlapi.NewHandler(store, graphqlapi.Limits{MaxDepth: 8, MaxComplexity: 1000})
+		require.NoError(t, err)
+
+		ids := make([]any, 100)
+		for i := range ids {
+			ids[i] = uuid.NewString()
+		}
+		code, resp := query(t, handler, nil, `query($ids: [ID!]!) { users(ids: $ids) { cost { total } } }`,
+			map[string]any{"ids": ids})
+		assert.Equal(t, http.StatusBadRequest, code)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, graphqlapi.CodeQueryTooComplex, resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("each sum is charged to the sum quota", func(t *testing.T) {
+		limiter := ratelimit.NewLimiter("sum", ratelimit.NewMemoryStore(), ratelimit.Limit{Rate: 0.001, Burst: 1})
+		handler, err := graphqlapi.NewHandler(store, graphqlapi.Limits{MaxDepth: 5, MaxComplexity: 100, SumLimiter: limiter})
+		require.NoError(t, err)
+
+		code, resp := query(t, handler, ownerPrincipal, `{ cost { total byCategory { total } } }`, nil)
+		assert.Equal(t, http.StatusOK, code)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, graphqlapi.CodeRateLimited, resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("too deep", func(t *testing.T) {
+		code, resp := query(t, handler, ownerPrincipal, `{
+			subscriptions(limit: 1) { user { subscriptions(limit: 1) { user { subscriptions(limit: 1) { id } } } } }
+		}`, nil)
+		assert.Equal(t, http.StatusBadRequest, code)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, graphqlapi.CodeQueryTooDeep, resp.Errors[0].Extensions["code"])
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		code, resp := query(t, handler, ownerPrincipal, `{ subscriptions { unknown } }`, nil)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.NotEmpty(t, resp.Errors)
+	})
+}
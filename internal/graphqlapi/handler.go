@@ -0,0 +1,118 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+
+	"subscribe_aggregation-main/internal/auth"
+	"subscribe_aggregation-main/internal/storage"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// maxBodySize — наибольший размер тела запроса
+const maxBodySize = 1 << 20
+
+// Request — тело запроса POST /graphql
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Handler обрабатывает запросы GraphQL. Аутентификация и организация берутся
+// из контекста запроса, их задают те же middleware, что и для REST API.
+type Handler struct {
+	schema graphql.Schema
+	limits Limits
+}
+
+// NewHandler создает обработчик GraphQL поверх хранилища
+func NewHandler(store storage.StorageInterface, limits Limits) (*Handler, error) {
+	schema, err := newSchema(&resolver{store: store, sumLimiter: limits.SumLimiter})
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{schema: schema, limits: limits}, nil
+}
+
+// clientKey — ключ контекста с клиентом запроса для квоты на расчет суммы
+type clientKey struct{}
+
+// requestClient возвращает клиента квоты, как RateLimit REST API:
+// API ключ, пользователь из токена или IP адрес для запросов без аутентификации
+func requestClient(r *http.Request) string {
+	if principal := auth.FromContext(r.Context()); principal != nil {
+		return principal.ClientKey()
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// writeResult отправляет ответ в формате GraphQL: data и errors
+func writeResult(w http.ResponseWriter, status int, result *graphql.Result) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+// writeErrors отвечает ошибкой запроса без выполнения. Код ошибки передается в extensions
+func writeErrors(w http.ResponseWriter, status int, err error) {
+	formatted := gqlerrors.FormatError(err)
+	if extended, ok := err.(gqlerrors.ExtendedError); ok {
+		formatted.Extensions = extended.Extensions()
+	}
+	writeResult(w, status, &graphql.Result{Errors: []gqlerrors.FormattedError{formatted}})
+}
+
+// ServeHTTP разбирает запрос, проверяет его по схеме и ограничениям и только затем выполняет.
+// Ошибки разбора, валидации и превышение ограничений возвращаются с кодом 400,
+// ошибки отдельных полей — в errors ответа с кодом 200.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		writeErrors(w, http.StatusBadRequest, invalidArgument("invalid request body: "+err.Error()))
+		return
+	}
+	if req.Query == "" {
+		writeErrors(w, http.StatusBadRequest, invalidArgument("query is required"))
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(req.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err)
+		return
+	}
+
+	validation := graphql.ValidateDocument(&h.schema, doc, nil)
+	if !validation.IsValid {
+		writeResult(w, http.StatusBadRequest, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+
+	if err := checkLimits(doc, req.OperationName, req.Variables, h.limits); err != nil {
+		writeErrors(w, http.StatusBadRequest, err)
+		return
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       context.WithValue(r.Context(), clientKey{}, requestClient(r)),
+	})
+	writeResult(w, http.StatusOK, result)
+}
@@ -0,0 +1,290 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"subscribe_aggregation-main/internal/auth"
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/ratelimit"
+	"subscribe_aggregation-main/internal/storage"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+)
+
+type resolver struct {
+	store storage.StorageInterface
+	// sumLimiter — квота на расчет суммы, списывается за каждое поле total и byCategory
+	sumLimiter *ratelimit.Limiter
+}
+
+// allowSum списывает расчет суммы с квоты клиента. Если хранилище квот недоступно,
+// расчет разрешается, как в middleware RateLimit REST API
+func (r *resolver) allowSum(ctx context.Context) error {
+	if r.sumLimiter == nil {
+		return nil
+	}
+	client, _ := ctx.Value(clientKey{}).(string)
+	result, err := r.sumLimiter.Allow(ctx, client)
+	if err != nil {
+		logging.GetLogger().WarnContext(ctx, "GraphQL: failed to check quota",
+			slog.String("limiter", r.sumLimiter.Name),
+			slog.String("error", err.Error()))
+		return nil
+	}
+	if !result.Allowed {
+		return &queryError{message: "rate limit exceeded, retry later", code: CodeRateLimited}
+	}
+	return nil
+}
+
+// stringArg возвращает строковый аргумент или пустую строку, если он не передан
+func stringArg(args map[string]any, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+// parseUserID разбирает ID пользователя из аргумента
+func parseUserID(name, value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, invalidArgument(name + " must be a UUID")
+	}
+	return id, nil
+}
+
+// scopeUserID ограничивает фильтр пользователя, как в REST API
+func scopeUserID(ctx context.Context, requested string) (string, error) {
+	if requested != "" {
+		if _, err := parseUserID("userId", requested); err != nil {
+			return "", err
+		}
+	}
+	userID, err := auth.ScopeUserID(auth.FromContext(ctx), requested)
+	if err != nil {
+		return "", forbidden(err.Error())
+	}
+	return userID, nil
+}
+
+// parsePeriod разбирает startDate и endDate. Месяц без дня в endDate означает последний день месяца.
+func parsePeriod(args map[string]any) (time.Time, time.Time, error) {
+	var start, end time.Time
+	var err error
+	if s := stringArg(args, "startDate"); s != "" {
+		if start, err = models.ParseDate(s); err != nil {
+			return time.Time{}, time.Time{}, invalidArgument("startDate: " + err.Error())
+		}
+	}
+	if s := stringArg(args, "endDate"); s != "" {
+		if end, err = models.ParseEndDate(s); err != nil {
+			return time.Time{}, time.Time{}, invalidArgument("endDate: " + err.Error())
+		}
+	}
+	if !end.IsZero() && end.Before(start) {
+		return time.Time{}, time.Time{}, invalidArgument("endDate must not be before startDate")
+	}
+	return start, end, nil
+}
+
+// listFilter собирает фильтр списка подписок из аргументов
+func listFilter(args map[string]any, userID string) (storage.ListFilter, error) {
+	from, to, err := parsePeriod(args)
+	if err != nil {
+		return storage.ListFilter{}, err
+	}
+
+	// DefaultValue подставляется только для пропущенных аргументов, явный null приходит как nil
+	page, ok := args["page"].(int)
+	if !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if !ok {
+		limit = defaultLimit
+	}
+
+	filter := storage.ListFilter{
+		Page:        max(page, 1),
+		Limit:       limit,
+		UserID:      userID,
+		ServiceName: stringArg(args, "serviceName"),
+		From:        from,
+		To:          to,
+	}
+	if filter.Limit < 1 || filter.Limit > maxLimit {
+		return storage.ListFilter{}, invalidArgument("limit must be between 1 and 100")
+	}
+	if tags, ok := args["tags"].([]any); ok {
+		for _, tag := range tags {
+			filter.Tags = append(filter.Tags, tag.(string))
+		}
+	}
+	return filter, nil
+}
+
+// listSubscriptions возвращает страницу подписок в виде указателей для резолверов полей
+func (r *resolver) listSubscriptions(ctx context.Context, filter storage.ListFilter) (any, error) {
+	if err := requireScope(ctx, auth.ScopeSubscriptionsRead); err != nil {
+		return nil, err
+	}
+
+	subs, err := r.store.ListSubscriptions(ctx, filter)
+	if err != nil {
+		return nil, storageError(ctx, "ListSubscriptions", err)
+	}
+	result := make([]*models.Subscription, len(subs))
+	for i := range subs {
+		result[i] = &subs[i]
+	}
+	return result, nil
+}
+
+// newCostQuery собирает параметры сводки стоимости. Без endDate период заканчивается сегодня, как в REST API
+func newCostQuery(ctx context.Context, args map[string]any, userID string) (costQuery, error) {
+	if err := requireScope(ctx, auth.ScopeReportsRead); err != nil {
+		return costQuery{}, err
+	}
+
+	start, end, err := parsePeriod(args)
+	if err != nil {
+		return costQuery{}, err
+	}
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return costQuery{userID: userID, serviceName: stringArg(args, "serviceName"), start: start, end: end}, nil
+}
+
+// subscription возвращает подписку по ID или null, если ее нет или она чужая
+func (r *resolver) subscription(p graphql.ResolveParams) (any, error) {
+	if err := requireScope(p.Context, auth.ScopeSubscriptionsRead); err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(stringArg(p.Args, "id"))
+	if err != nil {
+		return nil, invalidArgument("id must be a UUID")
+	}
+
+	sub, err := r.store.GetSubscriptionByID(p.Context, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, storageError(p.Context, "GetSubscription", err)
+	}
+	// Чужая подписка выглядит как несуществующая, чтобы не раскрывать ее наличие
+	if !auth.CanAccessSubscription(auth.FromContext(p.Context), sub, false) {
+		return nil, nil
+	}
+	return sub, nil
+}
+
+func (r *resolver) subscriptions(p graphql.ResolveParams) (any, error) {
+	userID, err := scopeUserID(p.Context, stringArg(p.Args, "userId"))
+	if err != nil {
+		return nil, err
+	}
+	filter, err := listFilter(p.Args, userID)
+	if err != nil {
+		return nil, err
+	}
+	return r.listSubscriptions(p.Context, filter)
+}
+
+func (r *resolver) cost(p graphql.ResolveParams) (any, error) {
+	userID, err := scopeUserID(p.Context, stringArg(p.Args, "userId"))
+	if err != nil {
+		return nil, err
+	}
+	return newCostQuery(p.Context, p.Args, userID)
+}
+
+func (r *resolver) user(p graphql.ResolveParams) (any, error) {
+	id, err := parseUserID("id", stringArg(p.Args, "id"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := scopeUserID(p.Context, id.String()); err != nil {
+		return nil, err
+	}
+	return user{id: id}, nil
+}
+
+func (r *resolver) users(p graphql.ResolveParams) (any, error) {
+	ids, _ := p.Args["ids"].([]any)
+	if len(ids) > maxLimit {
+		return nil, invalidArgument("at most 100 ids are allowed")
+	}
+
+	users := make([]user, 0, len(ids))
+	for _, raw := range ids {
+		id, err := parseUserID("ids", raw.(string))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := scopeUserID(p.Context, id.String()); err != nil {
+			return nil, err
+		}
+		users = append(users, user{id: id})
+	}
+	return users, nil
+}
+
+// userSubscriptions возвращает подписки пользователя. Доступ проверяется и здесь,
+// так как пользователь доступен и через владельца совместной подписки.
+func (r *resolver) userSubscriptions(p graphql.ResolveParams) (any, error) {
+	userID, err := scopeUserID(p.Context, p.Source.(user).id.String())
+	if err != nil {
+		return nil, err
+	}
+	filter, err := listFilter(p.Args, userID)
+	if err != nil {
+		return nil, err
+	}
+	return r.listSubscriptions(p.Context, filter)
+}
+
+func (r *resolver) userCost(p graphql.ResolveParams) (any, error) {
+	userID, err := scopeUserID(p.Context, p.Source.(user).id.String())
+	if err != nil {
+		return nil, err
+	}
+	return newCostQuery(p.Context, p.Args, userID)
+}
+
+func (r *resolver) costTotal(p graphql.ResolveParams) (any, error) {
+	q := p.Source.(costQuery)
+	if err := r.allowSum(p.Context); err != nil {
+		return nil, err
+	}
+	total, err := r.store.SumSubscriptionsCost(p.Context, q.userID, q.serviceName, q.start, q.end)
+	if err != nil {
+		return nil, storageError(p.Context, "SumSubscriptionsCost", err)
+	}
+	return total, nil
+}
+
+// costByCategory возвращает суммы по категориям, отсортированные по названию
+func (r *resolver) costByCategory(p graphql.ResolveParams) (any, error) {
+	q := p.Source.(costQuery)
+	if err := r.allowSum(p.Context); err != nil {
+		return nil, err
+	}
+	categories, err := r.store.SumSubscriptionsCostByCategory(p.Context, q.userID, q.serviceName, q.start, q.end)
+	if err != nil {
+		return nil, storageError(p.Context, "SumSubscriptionsCostByCategory", err)
+	}
+
+	result := make([]categoryCost, 0, len(categories))
+	for category, total := range categories {
+		result = append(result, categoryCost{category: category, total: total})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].category < result[j].category })
+	return result, nil
+}
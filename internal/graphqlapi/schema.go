@@ -0,0 +1,243 @@
+// Package graphqlapi — GraphQL API для дашбордов. Позволяет одним запросом получить
+// подписки, сводки по пользователям и разбивку стоимости поверх storage.StorageInterface
+// с теми же правилами доступа, что у REST API.
+package graphqlapi
+
+import (
+	"strconv"
+	"time"
+
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	// dateLayout — формат дат в ответах, как в REST API
+	dateLayout = "2006-01-02"
+	// defaultLimit и maxLimit — размер страницы списка подписок по умолчанию и наибольший
+	defaultLimit = 10
+	maxLimit     = 100
+)
+
+// user — пользователь в ответе. Отдельной таблицы пользователей нет, поэтому это только ID
+type user struct {
+	id uuid.UUID
+}
+
+// costQuery — параметры сводки стоимости. Суммы запрашиваются из хранилища,
+// только если соответствующие поля выбраны в запросе.
+type costQuery struct {
+	userID      string
+	serviceName string
+	start       time.Time
+	end         time.Time
+}
+
+type categoryCost struct {
+	category string
+	total    int64
+}
+
+// int64Type — целое число за пределами 32 бит, в которое не помещаются суммы стоимости
+var int64Type = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Int64",
+	Description: "64-bit signed integer",
+	Serialize:   func(v any) any { return v },
+	ParseValue:  func(v any) any { return v },
+	ParseLiteral: func(v ast.Value) any {
+		if iv, ok := v.(*ast.IntValue); ok {
+			if n, err := strconv.ParseInt(iv.Value, 10, 64); err == nil {
+				return n
+			}
+		}
+		return nil
+	},
+})
+
+func formatDate(d models.DataOnly) string {
+	return time.Time(d).Format(dateLayout)
+}
+
+func formatOptionalDate(d *models.DataOnly) any {
+	if d == nil {
+		return nil
+	}
+	return formatDate(*d)
+}
+
+// field возвращает поле, значение которого вычисляется из родительского объекта типа T
+func field[T any](typ graphql.Output, value func(T) any) *graphql.Field {
+	return &graphql.Field{
+		Type: typ,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			return value(p.Source.(T)), nil
+		},
+	}
+}
+
+// periodArgs — аргументы периода, общие для списков и сумм
+var periodArgs = graphql.FieldConfigArgument{
+	"serviceName": {Type: graphql.String},
+	"startDate":   {Type: graphql.String, Description: "MM-YYYY, YYYY-MM, YYYY-MM-DD or RFC 3339"},
+	"endDate":     {Type: graphql.String, Description: "MM-YYYY, YYYY-MM, YYYY-MM-DD or RFC 3339, month includes its last day"},
+}
+
+// withArgs дополняет аргументы периода
+func withArgs(extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args := graphql.FieldConfigArgument{}
+	for name, arg := range periodArgs {
+		args[name] = arg
+	}
+	for name, arg := range extra {
+		args[name] = arg
+	}
+	return args
+}
+
+// NewSchema строит схему GraphQL с резолверами поверх хранилища
+func NewSchema(store storage.StorageInterface) (graphql.Schema, error) {
+	return newSchema(&resolver{store: store})
+}
+
+func newSchema(r *resolver) (graphql.Schema, error) {
+	memberType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "SubscriptionMember",
+		Fields: graphql.Fields{
+			"userId": field(graphql.NewNonNull(graphql.ID), func(m models.SubscriptionMember) any { return m.UserID.String() }),
+			"share":  field(graphql.NewNonNull(graphql.Float), func(m models.SubscriptionMember) any { return m.Share }),
+		},
+	})
+
+	phaseType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PricePhase",
+		Fields: graphql.Fields{
+			"months": field(graphql.NewNonNull(graphql.Int), func(p models.PricePhase) any { return p.Months }),
+			"price":  field(graphql.NewNonNull(graphql.Int), func(p models.PricePhase) any { return p.Price }),
+		},
+	})
+
+	pauseType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Pause",
+		Fields: graphql.Fields{
+			"id":        field(graphql.NewNonNull(graphql.ID), func(p models.Pause) any { return p.ID.String() }),
+			"startDate": field(graphql.NewNonNull(graphql.String), func(p models.Pause) any { return formatDate(p.StartDate) }),
+			"endDate":   field(graphql.String, func(p models.Pause) any { return formatOptionalDate(p.EndDate) }),
+		},
+	})
+
+	categoryCostType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "CategoryCost",
+		Fields: graphql.Fields{
+			"category": field(graphql.NewNonNull(graphql.String), func(c categoryCost) any { return c.category }),
+			"total":    field(graphql.NewNonNull(int64Type), func(c categoryCost) any { return c.total }),
+		},
+	})
+
+	costSummaryType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "CostSummary",
+		Description: "Total subscription cost for the period, same as GET /subscriptions/sum",
+		Fields: graphql.Fields{
+			"total": {
+				Type:    graphql.NewNonNull(int64Type),
+				Resolve: r.costTotal,
+			},
+			"byCategory": {
+				Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(categoryCostType))),
+				Resolve: r.costByCategory,
+			},
+		},
+	})
+
+	subscriptionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"id":           field(graphql.NewNonNull(graphql.ID), func(s *models.Subscription) any { return s.ID.String() }),
+			"userId":       field(graphql.NewNonNull(graphql.ID), func(s *models.Subscription) any { return s.UserID.String() }),
+			"serviceName":  field(graphql.NewNonNull(graphql.String), func(s *models.Subscription) any { return s.ServiceName }),
+			"price":        field(graphql.NewNonNull(graphql.Int), func(s *models.Subscription) any { return s.Price }),
+			"startDate":    field(graphql.NewNonNull(graphql.String), func(s *models.Subscription) any { return formatDate(s.StartDate) }),
+			"endDate":      field(graphql.String, func(s *models.Subscription) any { return formatOptionalDate((*models.DataOnly)(s.EndDate)) }),
+			"splitMode":    field(graphql.String, func(s *models.Subscription) any { return s.SplitMode }),
+			"tags":         field(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))), func(s *models.Subscription) any { return s.Tags }),
+			"members":      field(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(memberType))), func(s *models.Subscription) any { return s.Members }),
+			"phases":       field(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(phaseType))), func(s *models.Subscription) any { return s.Phases }),
+			"pauses":       field(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(pauseType))), func(s *models.Subscription) any { return s.Pauses }),
+			"trialEndDate": field(graphql.String, func(s *models.Subscription) any { return formatOptionalDate(s.TrialEndDate) }),
+			"createdAt":    field(graphql.NewNonNull(graphql.String), func(s *models.Subscription) any { return formatDate(s.CreatedAt) }),
+			"updatedAt":    field(graphql.NewNonNull(graphql.String), func(s *models.Subscription) any { return formatDate(s.UpdatedAt) }),
+			"version":      field(graphql.NewNonNull(graphql.Int), func(s *models.Subscription) any { return s.Version }),
+		},
+	})
+
+	subscriptionsArgs := withArgs(graphql.FieldConfigArgument{
+		"tags":  {Type: graphql.NewList(graphql.NewNonNull(graphql.String)), Description: "Subscription must have all of them"},
+		"page":  {Type: graphql.Int, DefaultValue: 1},
+		"limit": {Type: graphql.Int, DefaultValue: defaultLimit, Description: "Page size, at most 100"},
+	})
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "User",
+		Description: "Per-user summary: subscriptions and their cost",
+		Fields: graphql.Fields{
+			"id": field(graphql.NewNonNull(graphql.ID), func(u user) any { return u.id.String() }),
+			"subscriptions": {
+				Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(subscriptionType))),
+				Args:    subscriptionsArgs,
+				Resolve: r.userSubscriptions,
+			},
+			"cost": {
+				Type:    graphql.NewNonNull(costSummaryType),
+				Args:    periodArgs,
+				Resolve: r.userCost,
+			},
+		},
+	})
+
+	// Владелец подписки ссылается обратно на тип пользователя, поэтому поле добавляется после создания обоих типов
+	subscriptionType.AddFieldConfig("user", field(graphql.NewNonNull(userType), func(s *models.Subscription) any { return user{id: s.UserID} }))
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"subscription": {
+				Type:    subscriptionType,
+				Args:    graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: r.subscription,
+			},
+			"subscriptions": {
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(subscriptionType))),
+				Args: withArgs(graphql.FieldConfigArgument{
+					"userId": {Type: graphql.ID},
+					"tags":   subscriptionsArgs["tags"],
+					"page":   subscriptionsArgs["page"],
+					"limit":  subscriptionsArgs["limit"],
+				}),
+				Resolve: r.subscriptions,
+			},
+			"user": {
+				Type:    userType,
+				Args:    graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: r.user,
+			},
+			"users": {
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userType))),
+				Description: "Several users at once, at most 100",
+				Args:        graphql.FieldConfigArgument{"ids": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.ID)))}},
+				Resolve:     r.users,
+			},
+			"cost": {
+				Type: graphql.NewNonNull(costSummaryType),
+				Args: withArgs(graphql.FieldConfigArgument{
+					"userId": {Type: graphql.ID},
+				}),
+				Resolve: r.cost,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
@@ -0,0 +1,110 @@
+package grpcserver
+
+import (
+	"strconv"
+	"time"
+
+	"subscribe_aggregation-main/internal/models"
+	pb "subscribe_aggregation-main/pkg/pb/subscriptions/v1"
+
+	"github.com/google/uuid"
+)
+
+// dateLayout — формат дат в ответах, как в REST API
+const dateLayout = "2006-01-02"
+
+func formatDate(d models.DataOnly) string {
+	return time.Time(d).Format(dateLayout)
+}
+
+func formatOptionalDate(d *models.DataOnly) string {
+	if d == nil {
+		return ""
+	}
+	return formatDate(*d)
+}
+
+// toProto переводит подписку в сообщение ответа
+func toProto(sub *models.Subscription) *pb.Subscription {
+	msg := &pb.Subscription{
+		Id:           sub.ID.String(),
+		UserId:       sub.UserID.String(),
+		ServiceName:  sub.ServiceName,
+		Price:        int64(sub.Price),
+		StartDate:    formatDate(sub.StartDate),
+		EndDate:      formatOptionalDate((*models.DataOnly)(sub.EndDate)),
+		SplitMode:    sub.SplitMode,
+		Tags:         sub.Tags,
+		TrialEndDate: formatOptionalDate(sub.TrialEndDate),
+		CreatedAt:    formatDate(sub.CreatedAt),
+		UpdatedAt:    formatDate(sub.UpdatedAt),
+		Version:      int32(sub.Version),
+	}
+	for _, m := range sub.Members {
+		msg.Members = append(msg.Members, &pb.SubscriptionMember{UserId: m.UserID.String(), Share: m.Share})
+	}
+	for _, p := range sub.Phases {
+		msg.Phases = append(msg.Phases, &pb.PricePhase{Months: int32(p.Months), Price: int64(p.Price)})
+	}
+	for _, p := range sub.Pauses {
+		msg.Pauses = append(msg.Pauses, &pb.Pause{Id: p.ID.String(), StartDate: formatDate(p.StartDate), EndDate: formatOptionalDate(p.EndDate)})
+	}
+	return msg
+}
+
+// fromProto переводит подписку из запроса в модель. Поля только для чтения не переносятся.
+// Ошибки формата возвращаются как нарушения по полям, как при разборе JSON в REST API.
+func fromProto(msg *pb.Subscription) (*models.Subscription, []violation) {
+	var violations []violation
+	sub := &models.Subscription{
+		ServiceName: msg.GetServiceName(),
+		Price:       int(msg.GetPrice()),
+		SplitMode:   msg.GetSplitMode(),
+		Tags:        msg.GetTags(),
+	}
+
+	if msg.GetUserId() != "" {
+		id, err := uuid.Parse(msg.GetUserId())
+		if err != nil {
+			violations = append(violations, violation{"user_id", "user_id must be a UUID"})
+		}
+		sub.UserID = id
+	}
+
+	if start, err := parseDate("start_date", msg.GetStartDate(), models.ParseDate); err != nil {
+		violations = append(violations, *err)
+	} else if !start.IsZero() {
+		sub.StartDate = models.DataOnly(start)
+	}
+
+	if end, err := parseDate("end_date", msg.GetEndDate(), models.ParseEndDate); err != nil {
+		violations = append(violations, *err)
+	} else if !end.IsZero() {
+		d := models.EndDate(end)
+		sub.EndDate = &d
+	}
+
+	for i, m := range msg.GetMembers() {
+		id, err := uuid.Parse(m.GetUserId())
+		if err != nil {
+			violations = append(violations, violation{"members[" + strconv.Itoa(i) + "].user_id", "user_id must be a UUID"})
+		}
+		sub.Members = append(sub.Members, models.SubscriptionMember{UserID: id, Share: m.GetShare()})
+	}
+	for _, p := range msg.GetPhases() {
+		sub.Phases = append(sub.Phases, models.PricePhase{Months: int(p.GetMonths()), Price: int(p.GetPrice())})
+	}
+	return sub, violations
+}
+
+// parseDate разбирает необязательную дату запроса. Пустая строка дает нулевое время
+func parseDate(field, value string, parse func(string) (time.Time, error)) (time.Time, *violation) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := parse(value)
+	if err != nil {
+		return time.Time{}, &violation{field, err.Error()}
+	}
+	return t, nil
+}
@@ -0,0 +1,83 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"subscribe_aggregation-main/internal/storage"
+	"subscribe_aggregation-main/internal/validation"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// violation — ошибка в поле запроса, передается клиенту в errdetails.BadRequest
+type violation struct {
+	field   string
+	message string
+}
+
+// invalidArgument возвращает InvalidArgument с нарушениями по полям
+func invalidArgument(message string, violations ...violation) error {
+	st := status.New(codes.InvalidArgument, message)
+	if len(violations) == 0 {
+		return st.Err()
+	}
+
+	details := &errdetails.BadRequest{}
+	for _, v := range violations {
+		details.FieldViolations = append(details.FieldViolations,
+			&errdetails.BadRequest_FieldViolation{Field: v.field, Description: v.message})
+	}
+	if withDetails, err := st.WithDetails(details); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// validationError переводит ошибку пакета validation в InvalidArgument
+func validationError(err error) error {
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		return invalidArgument(err.Error())
+	}
+
+	violations := make([]violation, 0, len(errs))
+	for _, v := range errs {
+		violations = append(violations, violation{v.Field, v.Message})
+	}
+	return invalidArgument("request validation failed", violations...)
+}
+
+// storageCodes сопоставляет ошибки хранилища с кодами gRPC, как storageErrors в REST API
+var storageCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{storage.ErrVersionMismatch, codes.Aborted},
+	{storage.ErrNotFound, codes.NotFound},
+	{storage.ErrConflict, codes.AlreadyExists},
+	{storage.ErrInvalidInput, codes.InvalidArgument},
+}
+
+// storageError переводит ошибку хранилища в статус gRPC.
+// Неизвестные ошибки логируются, а клиент получает Internal без подробностей.
+func storageError(ctx context.Context, op, resource string, err error) error {
+	for _, known := range storageCodes {
+		if !errors.Is(err, known.err) {
+			continue
+		}
+		message := err.Error()
+		if err == storage.ErrNotFound {
+			message = resource + " not found"
+		}
+		return status.Error(known.code, message)
+	}
+
+	logging.GetLogger().ErrorContext(ctx, op+": internal error",
+		slog.String("error", err.Error()))
+	return status.Error(codes.Internal, "internal server error")
+}
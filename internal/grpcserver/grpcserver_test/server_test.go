@@ -0,0 +1,295 @@
+package grpcserver_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"subscribe_aggregation-main/internal/auth"
+	"subscribe_aggregation-main/internal/grpcserver"
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/ratelimit"
+	"subscribe_aggregation-main/internal/storage"
+	"subscribe_aggregation-main/internal/tenant"
+	pb "subscribe_aggregation-main/pkg/pb/subscriptions/v1"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const secret = "test-secret"
+
+// fakeStorage хранит подписки в памяти. Методы, которые сервер не вызывает,
+// остаются у встроенного nil интерфейса и паникуют при вызове.
+type fakeStorage struct {
+	storage.StorageInterface
+	subs    map[uuid.UUID]*models.Subscription
+	tenants map[uuid.UUID]string
+	keys    map[string]*models.APIKey
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{
+		subs:    map[uuid.UUID]*models.Subscription{},
+		tenants: map[uuid.UUID]string{},
+		keys:    map[string]*models.APIKey{},
+	}
+}
+
+func (f *fakeStorage) CreateSubscription(ctx context.Context, sub *models.Subscription) error {
+	sub.Version = 1
+	saved := *sub
+	f.subs[sub.ID] = &saved
+	f.tenants[sub.ID] = tenant.FromContext(ctx)
+	return nil
+}
+
+func (f *fakeStorage) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	sub, ok := f.subs[id]
+	if !ok || f.tenants[id] != tenant.FromContext(ctx) {
+		return nil, storage.ErrNotFound
+	}
+	found := *sub
+	return &found, nil
+}
+
+func (f *fakeStorage) UpdateSubscription(ctx context.Context, sub *models.Subscription) error {
+	current, err := f.GetSubscriptionByID(ctx, sub.ID)
+	if err != nil {
+		return err
+	}
+	if sub.Version > 0 && sub.Version != current.Version {
+		return storage.ErrVersionMismatch
+	}
+	sub.UserID = current.UserID
+	sub.Version = current.Version + 1
+	saved := *sub
+	f.subs[sub.ID] = &saved
+	return nil
+}
+
+func (f *fakeStorage) DeleteSubscription(ctx context.Context, id uuid.UUID, version int) error {
+	current, err := f.GetSubscriptionByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if version > 0 && version != current.Version {
+		return storage.ErrVersionMismatch
+	}
+	delete(f.subs, id)
+	return nil
+}
+
+func (f *fakeStorage) SumSubscriptionsCost(ctx context.Context, userID, serviceName string, filterStart, filterEnd time.Time) (int64, error) {
+	var total int64
+	for id, sub := range f.subs {
+		if f.tenants[id] == tenant.FromContext(ctx) && (userID == "" || sub.UserID.String() == userID) {
+			total += int64(sub.Price)
+		}
+	}
+	return total, nil
+}
+
+func (f *fakeStorage) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	key, ok := f.keys[hash]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return key, nil
+}
+
+func (f *fakeStorage) TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return nil
+}
+
+// startServer запускает gRPC сервер в памяти без квот и возвращает клиента
+func startServer(t *testing.T, store *fakeStorage) pb.SubscriptionServiceClient {
+	return startServerWithLimits(t, store, grpcserver.RateLimits{})
+}
+
+// startServerWithLimits запускает gRPC сервер в памяти с квотами limits
+func startServerWithLimits(t *testing.T, store *fakeStorage, limits grpcserver.RateLimits) pb.SubscriptionServiceClient {
+	return startServerWithOptions(t, store, limits, false)
+}
+
+// startServerWithOptions запускает gRPC сервер в памяти с квотами limits и обязательной версией
+func startServerWithOptions(t *testing.T, store *fakeStorage, limits grpcserver.RateLimits, requireVersion bool) pb.SubscriptionServiceClient {
+	t.Helper()
+	verifier, err := auth.NewVerifier(auth.Config{Secret: secret})
+	require.NoError(t, err)
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpcserver.New(store, &auth.Authenticator{Verifier: verifier, Keys: store}, limits, requireVersion)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewSubscriptionServiceClient(conn)
+}
+
+func withToken(t *testing.T, userID uuid.UUID, claims jwt.MapClaims) context.Context {
+	t.Helper()
+	all := jwt.MapClaims{"sub": userID.String(), "exp": time.Now().Add(time.Hour).Unix()}
+	for k, v := range claims {
+		all[k] = v
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, all).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return metadata.AppendToOutgoingContext(context.Background(), grpcserver.AuthorizationMetadata, "Bearer "+token)
+}
+
+func TestSubscriptionService(t *testing.T) {
+	store := newFakeStorage()
+	client := startServer(t, store)
+
+	owner, other := uuid.New(), uuid.New()
+	ownerCtx := withToken(t, owner, nil)
+
+	created, err := client.CreateSubscription(ownerCtx, &pb.CreateSubscriptionRequest{Subscription: &pb.Subscription{
+		ServiceName: "svc",
+		Price:       300,
+		StartDate:   "2025-01",
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, owner.String(), created.GetUserId())
+	assert.Equal(t, "2025-01-01", created.GetStartDate())
+	assert.Equal(t, int32(1), created.GetVersion())
+
+	// Request ID клиента возвращается в заголовке ответа
+	var header metadata.MD
+	got, err := client.GetSubscription(metadata.AppendToOutgoingContext(ownerCtx, grpcserver.RequestIDMetadata, "req-123"),
+		&pb.GetSubscriptionRequest{Id: created.GetId()}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), got.GetPrice())
+	assert.Equal(t, []string{"req-123"}, header.Get(grpcserver.RequestIDMetadata))
+
+	// Чужая подписка выглядит как несуществующая
+	_, err = client.GetSubscription(withToken(t, other, nil), &pb.GetSubscriptionRequest{Id: created.GetId()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// Подписка другой организации тоже не видна
+	_, err = client.GetSubscription(withToken(t, owner, jwt.MapClaims{"tenant_id": "acme"}), &pb.GetSubscriptionRequest{Id: created.GetId()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	updated, err := client.UpdateSubscription(ownerCtx, &pb.UpdateSubscriptionRequest{
+		Subscription: &pb.Subscription{Id: created.GetId(), ServiceName: "svc", Price: 400, StartDate: "2025-01-01"},
+		Version:      1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), updated.GetVersion())
+
+	_, err = client.UpdateSubscription(ownerCtx, &pb.UpdateSubscriptionRequest{
+		Subscription: &pb.Subscription{Id: created.GetId(), ServiceName: "svc", Price: 500, StartDate: "2025-01-01"},
+		Version:      1,
+	})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	sum, err := client.SumSubscriptionsCost(ownerCtx, &pb.SumSubscriptionsCostRequest{StartDate: "2025-01"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(400), sum.GetTotalPrice())
+
+	_, err = client.SumSubscriptionsCost(ownerCtx, &pb.SumSubscriptionsCostRequest{UserId: other.String()})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = client.CreateSubscription(ownerCtx, &pb.CreateSubscriptionRequest{Subscription: &pb.Subscription{ServiceName: "svc", StartDate: "soon"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSubscriptionServiceRequireVersion(t *testing.T) {
+	store := newFakeStorage()
+	client := startServerWithOptions(t, store, grpcserver.RateLimits{}, true)
+
+	owner := uuid.New()
+	ownerCtx := withToken(t, owner, nil)
+	created, err := client.CreateSubscription(ownerCtx, &pb.CreateSubscriptionRequest{Subscription: &pb.Subscription{
+		ServiceName: "svc",
+		Price:       300,
+		StartDate:   "2025-01",
+	}})
+	require.NoError(t, err)
+
+	_, err = client.UpdateSubscription(ownerCtx, &pb.UpdateSubscriptionRequest{
+		Subscription: &pb.Subscription{Id: created.GetId(), ServiceName: "svc", Price: 400, StartDate: "2025-01-01"},
+	})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = client.DeleteSubscription(ownerCtx, &pb.DeleteSubscriptionRequest{Id: created.GetId()})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	updated, err := client.UpdateSubscription(ownerCtx, &pb.UpdateSubscriptionRequest{
+		Subscription: &pb.Subscription{Id: created.GetId(), ServiceName: "svc", Price: 400, StartDate: "2025-01-01"},
+		Version:      created.GetVersion(),
+	})
+	require.NoError(t, err)
+
+	_, err = client.DeleteSubscription(ownerCtx, &pb.DeleteSubscriptionRequest{Id: created.GetId(), Version: updated.GetVersion()})
+	assert.NoError(t, err)
+}
+
+func TestSubscriptionServiceAuth(t *testing.T) {
+	store := newFakeStorage()
+	client := startServer(t, store)
+
+	_, err := client.ListSubscriptions(context.Background(), &pb.ListSubscriptionsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	key, _, hash, err := auth.NewAPIKey()
+	require.NoError(t, err)
+	store.keys[hash] = &models.APIKey{ID: uuid.New(), Scopes: []string{auth.ScopeReportsRead}, TenantID: tenant.Default}
+	keyCtx := metadata.AppendToOutgoingContext(context.Background(), grpcserver.APIKeyMetadata, key)
+
+	_, err = client.SumSubscriptionsCost(keyCtx, &pb.SumSubscriptionsCostRequest{})
+	assert.NoError(t, err)
+
+	// Ключ только с reports:read не может создавать подписки
+	_, err = client.CreateSubscription(keyCtx, &pb.CreateSubscriptionRequest{Subscription: &pb.Subscription{}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	badKeyCtx := metadata.AppendToOutgoingContext(context.Background(), grpcserver.APIKeyMetadata, "sak_unknown")
+	_, err = client.SumSubscriptionsCost(badKeyCtx, &pb.SumSubscriptionsCostRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.SumSubscriptionsCost(metadata.AppendToOutgoingContext(keyCtx, grpcserver.TenantMetadata, "acme"), &pb.SumSubscriptionsCostRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestSubscriptionServiceRateLimit(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Rate: 0.001, Burst: 2}
+	client := startServerWithLimits(t, newFakeStorage(), grpcserver.RateLimits{
+		IP:     ratelimit.NewLimiter("ip", store, limit),
+		Client: ratelimit.NewLimiter("api", store, ratelimit.Limit{Rate: 0.001, Burst: 1}),
+	})
+
+	// Квота адреса проверяется до аутентификации, поэтому неверные токены ее тоже расходуют
+	badCtx := metadata.AppendToOutgoingContext(context.Background(), grpcserver.AuthorizationMetadata, "Bearer not-a-token")
+	_, err := client.ListSubscriptions(badCtx, &pb.ListSubscriptionsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Квота пользователя — один вызов, второй отклоняется с временем до повтора
+	userCtx := withToken(t, uuid.New(), nil)
+	_, err = client.SumSubscriptionsCost(userCtx, &pb.SumSubscriptionsCostRequest{})
+	require.NoError(t, err)
+
+	var header metadata.MD
+	_, err = client.SumSubscriptionsCost(userCtx, &pb.SumSubscriptionsCostRequest{}, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.NotEmpty(t, header.Get(grpcserver.RetryAfterMetadata))
+
+	// Квота адреса исчерпана: отклоняется даже вызов с верным токеном другого пользователя
+	_, err = client.SumSubscriptionsCost(withToken(t, uuid.New(), nil), &pb.SumSubscriptionsCostRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
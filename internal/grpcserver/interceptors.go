@@ -0,0 +1,191 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"subscribe_aggregation-main/internal/auth"
+	"subscribe_aggregation-main/internal/ratelimit"
+	"subscribe_aggregation-main/internal/storage"
+	"subscribe_aggregation-main/internal/tenant"
+	"subscribe_aggregation-main/pkg/logging"
+	pb "subscribe_aggregation-main/pkg/pb/subscriptions/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Ключи метаданных запроса. Совпадают с заголовками REST API в нижнем регистре
+const (
+	AuthorizationMetadata = "authorization"
+	APIKeyMetadata        = "x-api-key"
+	TenantMetadata        = "x-tenant-id"
+	RequestIDMetadata     = "x-request-id"
+	TraceparentMetadata   = "traceparent"
+	// RetryAfterMetadata — через сколько секунд повторить вызов, отклоненный по квоте
+	RetryAfterMetadata = "retry-after"
+)
+
+// methodScopes — право API ключа, необходимое для каждого метода
+var methodScopes = map[string]string{
+	pb.SubscriptionService_CreateSubscription_FullMethodName:   auth.ScopeSubscriptionsWrite,
+	pb.SubscriptionService_GetSubscription_FullMethodName:      auth.ScopeSubscriptionsRead,
+	pb.SubscriptionService_ListSubscriptions_FullMethodName:    auth.ScopeSubscriptionsRead,
+	pb.SubscriptionService_UpdateSubscription_FullMethodName:   auth.ScopeSubscriptionsWrite,
+	pb.SubscriptionService_DeleteSubscription_FullMethodName:   auth.ScopeSubscriptionsWrite,
+	pb.SubscriptionService_SumSubscriptionsCost_FullMethodName: auth.ScopeReportsRead,
+}
+
+// metadataValue возвращает первое значение ключа из метаданных запроса
+func metadataValue(ctx context.Context, key string) string {
+	values := metadata.ValueFromIncomingContext(ctx, key)
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(values[0])
+}
+
+// LoggingInterceptor добавляет request ID в контекст и логирует метод, код ответа и длительность.
+// Request ID берется из метаданных x-request-id или traceparent и возвращается в заголовке ответа.
+func LoggingInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	traceparent := metadataValue(ctx, TraceparentMetadata)
+	reqID := logging.IncomingRequestID(metadataValue(ctx, RequestIDMetadata), traceparent)
+	ctx = logging.ContextWithRequestID(ctx, reqID)
+	ctx = logging.ContextWithTraceparent(ctx, traceparent)
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadata, reqID))
+
+	logger := logging.GetLogger()
+	start := time.Now()
+
+	logger.InfoContext(ctx, "gRPC request started",
+		slog.String("method", info.FullMethod),
+	)
+
+	resp, err := handler(ctx, req)
+
+	logger.InfoContext(ctx, "gRPC request completed",
+		slog.String("method", info.FullMethod),
+		slog.String("code", status.Code(err).String()),
+		slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+	)
+	return resp, err
+}
+
+// AuthInterceptor проверяет API ключ или Bearer токен из метаданных так же, как AuthMiddleware REST API
+func AuthInterceptor(authenticator *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		principal, err := authenticator.Authenticate(ctx, metadataValue(ctx, AuthorizationMetadata), metadataValue(ctx, APIKeyMetadata))
+		if err != nil {
+			return nil, authError(ctx, err)
+		}
+		return handler(auth.NewContext(ctx, principal), req)
+	}
+}
+
+// authError отвечает Unauthenticated на неверные учетные данные и Internal на ошибку хранилища ключей
+func authError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, auth.ErrNoCredentials):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, auth.ErrInvalidToken):
+		logging.GetLogger().InfoContext(ctx, "AuthInterceptor: token rejected", slog.String("error", err.Error()))
+		return status.Error(codes.Unauthenticated, "invalid or expired token")
+	case errors.Is(err, storage.ErrNotFound):
+		logging.GetLogger().InfoContext(ctx, "AuthInterceptor: API key rejected")
+		return status.Error(codes.Unauthenticated, "invalid or revoked API key")
+	default:
+		return storageError(ctx, "AuthInterceptor", "API key", err)
+	}
+}
+
+// TenantInterceptor определяет организацию запроса по тем же правилам, что ResolveTenant REST API
+func TenantInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	id, err := auth.ResolveTenant(auth.FromContext(ctx), metadataValue(ctx, TenantMetadata))
+	if errors.Is(err, auth.ErrInvalidTenant) {
+		return nil, invalidArgument("invalid "+TenantMetadata,
+			violation{TenantMetadata, "tenant id may contain only lowercase letters, digits, - and _"})
+	}
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return handler(tenant.NewContext(ctx, id), req)
+}
+
+// ScopeInterceptor проверяет право API ключа на метод.
+// Пользователи с JWT и запросы без аутентификации не ограничиваются.
+func ScopeInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	scope, ok := methodScopes[info.FullMethod]
+	principal := auth.FromContext(ctx)
+	if principal != nil && principal.IsService() && (!ok || !principal.HasScope(scope)) {
+		return nil, status.Error(codes.PermissionDenied, "scope "+scope+" required")
+	}
+	return handler(ctx, req)
+}
+
+// RateLimits — квоты gRPC API. Это те же лимитеры, что у REST API, поэтому клиент
+// не может обойти квоту, переключившись на другой протокол. nil лимитер ничего не ограничивает.
+type RateLimits struct {
+	// IP — квота адреса клиента, проверяется до аутентификации
+	IP *ratelimit.Limiter
+	// Client — квота API ключа или пользователя
+	Client *ratelimit.Limiter
+	// Sum — дополнительная квота на расчет суммы
+	Sum *ratelimit.Limiter
+}
+
+// RateLimitInterceptor ограничивает частоту вызовов клиента, которого возвращает client,
+// квотой limiter, как RateLimit REST API. Если заданы methods, ограничиваются только они.
+// Превышение квоты возвращает ResourceExhausted и время до повтора в метаданных retry-after.
+// Если хранилище квот недоступно, вызов пропускается.
+func RateLimitInterceptor(limiter *ratelimit.Limiter, client func(context.Context) string, methods ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if len(methods) > 0 && !slices.Contains(methods, info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		result, err := limiter.Allow(ctx, client(ctx))
+		if err != nil {
+			logging.GetLogger().WarnContext(ctx, "RateLimitInterceptor: failed to check quota",
+				slog.String("limiter", limiter.Name),
+				slog.String("error", err.Error()))
+			return handler(ctx, req)
+		}
+		if !result.Allowed {
+			retryAfter := strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds())))
+			grpc.SetHeader(ctx, metadata.Pairs(RetryAfterMetadata, retryAfter))
+			return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded, retry later")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// peerClient возвращает IP адрес клиента из соединения
+func peerClient(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "ip:unknown"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return "ip:" + host
+}
+
+// requestClient возвращает API ключ или пользователя, а без аутентификации — IP адрес
+func requestClient(ctx context.Context) string {
+	if principal := auth.FromContext(ctx); principal != nil {
+		return principal.ClientKey()
+	}
+	return peerClient(ctx)
+}
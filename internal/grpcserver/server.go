@@ -0,0 +1,284 @@
+// Package grpcserver — gRPC API подписок для внутренних сервисов.
+// Повторяет REST API поверх того же storage.StorageInterface и тех же правил доступа.
+package grpcserver
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"subscribe_aggregation-main/internal/auth"
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/storage"
+	"subscribe_aggregation-main/internal/validation"
+	"subscribe_aggregation-main/pkg/logging"
+	pb "subscribe_aggregation-main/pkg/pb/subscriptions/v1"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server реализует SubscriptionService
+type Server struct {
+	pb.UnimplementedSubscriptionServiceServer
+	Storage storage.StorageInterface
+	// RequireVersion запрещает изменять и удалять подписки без version, как REQUIRE_IF_MATCH в REST API
+	RequireVersion bool
+}
+
+// New создает gRPC сервер с перехватчиками логирования, квот, аутентификации и выбора организации.
+// Если authenticator равен nil, аутентификация отключена, как AUTH_DISABLED для REST API.
+// Перехватчики идут в том же порядке, что middleware REST API.
+// С requireVersion изменение и удаление без version отклоняются с FailedPrecondition.
+func New(store storage.StorageInterface, authenticator *auth.Authenticator, limits RateLimits, requireVersion bool) *grpc.Server {
+	interceptors := []grpc.UnaryServerInterceptor{LoggingInterceptor}
+	if limits.IP != nil {
+		interceptors = append(interceptors, RateLimitInterceptor(limits.IP, peerClient))
+	}
+	if authenticator != nil {
+		interceptors = append(interceptors, AuthInterceptor(authenticator))
+	}
+	interceptors = append(interceptors, TenantInterceptor)
+	if limits.Client != nil {
+		interceptors = append(interceptors, RateLimitInterceptor(limits.Client, requestClient))
+	}
+	interceptors = append(interceptors, ScopeInterceptor)
+	if limits.Sum != nil {
+		interceptors = append(interceptors, RateLimitInterceptor(limits.Sum, requestClient,
+			pb.SubscriptionService_SumSubscriptionsCost_FullMethodName))
+	}
+
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	pb.RegisterSubscriptionServiceServer(srv, &Server{Storage: store, RequireVersion: requireVersion})
+	return srv
+}
+
+// parseID разбирает идентификатор подписки из запроса
+func parseID(id string) (uuid.UUID, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, invalidArgument("invalid UUID", violation{"id", "id must be a UUID"})
+	}
+	return parsed, nil
+}
+
+// checkVersion отклоняет изменение без ожидаемой версии, если она обязательна
+func (s *Server) checkVersion(version int) error {
+	if version <= 0 && s.RequireVersion {
+		return status.Error(codes.FailedPrecondition, "version is required to modify a subscription")
+	}
+	return nil
+}
+
+// authorize загружает подписку и проверяет доступ к ней.
+// Чужая подписка выглядит как несуществующая, чтобы не раскрывать ее наличие.
+func (s *Server) authorize(ctx context.Context, op string, id uuid.UUID, write bool) error {
+	principal := auth.FromContext(ctx)
+	if principal == nil || principal.AllUsers() {
+		return nil
+	}
+
+	sub, err := s.Storage.GetSubscriptionByID(ctx, id)
+	if err != nil {
+		return storageError(ctx, op, "subscription", err)
+	}
+	if !auth.CanAccessSubscription(principal, sub, write) {
+		return status.Error(codes.NotFound, "subscription not found")
+	}
+	return nil
+}
+
+// scopeUserID ограничивает фильтр пользователя, как в REST API
+func scopeUserID(ctx context.Context, requested string) (string, error) {
+	if requested != "" {
+		if _, err := uuid.Parse(requested); err != nil {
+			return "", invalidArgument("invalid user_id", violation{"user_id", "user_id must be a UUID"})
+		}
+	}
+	userID, err := auth.ScopeUserID(auth.FromContext(ctx), requested)
+	if err != nil {
+		return "", status.Error(codes.PermissionDenied, err.Error())
+	}
+	return userID, nil
+}
+
+// parsePeriod разбирает start_date и end_date запроса.
+// Месяц без дня в end_date означает последний день этого месяца.
+func parsePeriod(startDate, endDate string) (time.Time, time.Time, error) {
+	start, v := parseDate("start_date", startDate, models.ParseDate)
+	if v != nil {
+		return time.Time{}, time.Time{}, invalidArgument("invalid start_date", *v)
+	}
+	end, v := parseDate("end_date", endDate, models.ParseEndDate)
+	if v != nil {
+		return time.Time{}, time.Time{}, invalidArgument("invalid end_date", *v)
+	}
+	if !end.IsZero() && end.Before(start) {
+		return time.Time{}, time.Time{}, invalidArgument("invalid end_date", violation{"end_date", "end_date must not be before start_date"})
+	}
+	return start, end, nil
+}
+
+func (s *Server) CreateSubscription(ctx context.Context, req *pb.CreateSubscriptionRequest) (*pb.Subscription, error) {
+	sub, violations := fromProto(req.GetSubscription())
+	if len(violations) > 0 {
+		return nil, invalidArgument("invalid subscription", violations...)
+	}
+	sub.ID = uuid.New()
+
+	// Обычный пользователь создает подписки только на себя
+	if principal := auth.FromContext(ctx); principal != nil && !principal.AllUsers() {
+		if sub.UserID == uuid.Nil {
+			sub.UserID = principal.UserID
+		}
+		if sub.UserID != principal.UserID {
+			return nil, status.Error(codes.PermissionDenied, "subscriptions can be created only for the authenticated user")
+		}
+	}
+
+	if err := validation.NewSubscription(sub); err != nil {
+		return nil, validationError(err)
+	}
+	if err := s.Storage.CreateSubscription(ctx, sub); err != nil {
+		return nil, storageError(ctx, "CreateSubscription", "subscription", err)
+	}
+	return toProto(sub), nil
+}
+
+func (s *Server) GetSubscription(ctx context.Context, req *pb.GetSubscriptionRequest) (*pb.Subscription, error) {
+	id, err := parseID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := s.Storage.GetSubscriptionByID(ctx, id)
+	if err != nil {
+		return nil, storageError(ctx, "GetSubscription", "subscription", err)
+	}
+	if !auth.CanAccessSubscription(auth.FromContext(ctx), sub, false) {
+		return nil, status.Error(codes.NotFound, "subscription not found")
+	}
+	return toProto(sub), nil
+}
+
+func (s *Server) ListSubscriptions(ctx context.Context, req *pb.ListSubscriptionsRequest) (*pb.ListSubscriptionsResponse, error) {
+	userID, err := scopeUserID(ctx, req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+
+	from, to, err := parsePeriod(req.GetStartDate(), req.GetEndDate())
+	if err != nil {
+		return nil, err
+	}
+
+	filter := storage.ListFilter{
+		Page:        max(int(req.GetPage()), 1),
+		Limit:       int(req.GetLimit()),
+		UserID:      userID,
+		ServiceName: req.GetServiceName(),
+		Tags:        req.GetTags(),
+		From:        from,
+		To:          to,
+	}
+	if filter.Limit < 1 {
+		filter.Limit = 10
+	}
+
+	subs, err := s.Storage.ListSubscriptions(ctx, filter)
+	if err != nil {
+		return nil, storageError(ctx, "ListSubscriptions", "subscription", err)
+	}
+
+	resp := &pb.ListSubscriptionsResponse{Subscriptions: make([]*pb.Subscription, 0, len(subs))}
+	for i := range subs {
+		resp.Subscriptions = append(resp.Subscriptions, toProto(&subs[i]))
+	}
+	return resp, nil
+}
+
+func (s *Server) UpdateSubscription(ctx context.Context, req *pb.UpdateSubscriptionRequest) (*pb.Subscription, error) {
+	id, err := parseID(req.GetSubscription().GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	sub, violations := fromProto(req.GetSubscription())
+	if len(violations) > 0 {
+		return nil, invalidArgument("invalid subscription", violations...)
+	}
+	if err := validation.Subscription(sub); err != nil {
+		return nil, validationError(err)
+	}
+	sub.ID = id
+	sub.Version = int(req.GetVersion())
+	if err := s.checkVersion(sub.Version); err != nil {
+		return nil, err
+	}
+
+	if err := s.authorize(ctx, "UpdateSubscription", id, true); err != nil {
+		return nil, err
+	}
+	if err := s.Storage.UpdateSubscription(ctx, sub); err != nil {
+		return nil, storageError(ctx, "UpdateSubscription", "subscription", err)
+	}
+	return toProto(sub), nil
+}
+
+func (s *Server) DeleteSubscription(ctx context.Context, req *pb.DeleteSubscriptionRequest) (*pb.DeleteSubscriptionResponse, error) {
+	id, err := parseID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkVersion(int(req.GetVersion())); err != nil {
+		return nil, err
+	}
+
+	if err := s.authorize(ctx, "DeleteSubscription", id, true); err != nil {
+		return nil, err
+	}
+	if err := s.Storage.DeleteSubscription(ctx, id, int(req.GetVersion())); err != nil {
+		return nil, storageError(ctx, "DeleteSubscription", "subscription", err)
+	}
+	return &pb.DeleteSubscriptionResponse{}, nil
+}
+
+func (s *Server) SumSubscriptionsCost(ctx context.Context, req *pb.SumSubscriptionsCostRequest) (*pb.SumSubscriptionsCostResponse, error) {
+	groupBy := req.GetGroupBy()
+	if groupBy != "" && groupBy != "category" {
+		return nil, invalidArgument("invalid group_by, expected category", violation{"group_by", "group_by must be category"})
+	}
+
+	userID, err := scopeUserID(ctx, req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := parsePeriod(req.GetStartDate(), req.GetEndDate())
+	if err != nil {
+		return nil, err
+	}
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	if groupBy == "category" {
+		categories, err := s.Storage.SumSubscriptionsCostByCategory(ctx, userID, req.GetServiceName(), start, end)
+		if err != nil {
+			return nil, storageError(ctx, "SumSubscriptionsCost", "subscription", err)
+		}
+		return &pb.SumSubscriptionsCostResponse{Categories: categories}, nil
+	}
+
+	total, err := s.Storage.SumSubscriptionsCost(ctx, userID, req.GetServiceName(), start, end)
+	if err != nil {
+		return nil, storageError(ctx, "SumSubscriptionsCost", "subscription", err)
+	}
+
+	logging.GetLogger().InfoContext(ctx, "SumSubscriptionsCost: total price calculated",
+		slog.String("user_id", userID), slog.Int64("total_price", total))
+	return &pb.SumSubscriptionsCostResponse{TotalPrice: total}, nil
+}
@@ -0,0 +1,133 @@
+// Package metrics собирает метрики Prometheus: HTTP запросы, пул соединений с базой,
+// длительность операций хранилища и бизнес-показатели подписок.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"subscribe_aggregation-main/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute — метка маршрута для запросов, не совпавших ни с одним маршрутом,
+// чтобы произвольные URL не создавали новые временные ряды
+const unmatchedRoute = "unmatched"
+
+// Metrics хранит метрики сервиса в собственном реестре
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	storageDuration *prometheus.HistogramVec
+	storageErrors   *prometheus.CounterVec
+
+	activeSubscriptions *prometheus.GaugeVec
+	monthlyRecurring    *prometheus.GaugeVec
+}
+
+// New создает метрики и регистрирует их вместе с метриками Go рантайма и процесса
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request duration by method and route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "storage_operation_duration_seconds",
+			Help:    "Storage method duration.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		storageErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "storage_operation_errors_total",
+			Help: "Storage method calls that returned an error.",
+		}, []string{"method"}),
+		activeSubscriptions: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "subscriptions_active",
+			Help: "Active, not paused subscriptions by tenant.",
+		}, []string{"tenant_id"}),
+		monthlyRecurring: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "subscriptions_monthly_recurring_price",
+			Help: "Total monthly price of active subscriptions by tenant.",
+		}, []string{"tenant_id"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration,
+		m.storageDuration, m.storageErrors,
+		m.activeSubscriptions, m.monthlyRecurring,
+	)
+	return m
+}
+
+// Handler отдает метрики в формате Prometheus
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB добавляет статистику пула соединений db
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Middleware считает HTTP запросы и их длительность. Маршрут берется из шаблона chi
+// (/subscriptions/{id}), поэтому ID в пути не увеличивают число временных рядов.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		m.httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(sw.status)).Inc()
+		m.httpDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// SetSubscriptionStats заменяет бизнес-показатели новыми значениями.
+// Организации, которых нет в stats, удаляются из метрик.
+func (m *Metrics) SetSubscriptionStats(stats []storage.SubscriptionStats) {
+	m.activeSubscriptions.Reset()
+	m.monthlyRecurring.Reset()
+	for _, s := range stats {
+		m.activeSubscriptions.WithLabelValues(s.TenantID).Set(float64(s.Active))
+		m.monthlyRecurring.WithLabelValues(s.TenantID).Set(float64(s.MonthlyTotal))
+	}
+}
+
+// observeStorage записывает длительность и ошибку вызова метода хранилища
+func (m *Metrics) observeStorage(method string, start time.Time, err error) {
+	m.storageDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.storageErrors.WithLabelValues(method).Inc()
+	}
+}
+
+// statusWriter запоминает HTTP статус ответа
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,117 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"subscribe_aggregation-main/internal/metrics"
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// fakeStorage находит только одну подписку. Остальные методы остаются
+// у встроенного nil интерфейса и паникуют при вызове.
+type fakeStorage struct {
+	storage.StorageInterface
+	id uuid.UUID
+}
+
+func (f *fakeStorage) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	if id != f.id {
+		return nil, storage.ErrNotFound
+	}
+	return &models.Subscription{ID: id}, nil
+}
+
+// scrape возвращает метрики в текстовом формате Prometheus
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func assertContains(t *testing.T, body string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(body, line) {
+			t.Errorf("metrics do not contain %q", line)
+		}
+	}
+}
+
+func TestMiddlewareLabelsByRoutePattern(t *testing.T) {
+	m := metrics.New()
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Get("/subscriptions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if chi.URLParam(r, "id") == "missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	})
+
+	for _, path := range []string{"/subscriptions/1", "/subscriptions/2", "/subscriptions/missing", "/unknown/path"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	body := scrape(t, m)
+	assertContains(t, body,
+		`http_requests_total{method="GET",route="/subscriptions/{id}",status="200"} 2`,
+		`http_requests_total{method="GET",route="/subscriptions/{id}",status="404"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/subscriptions/{id}"} 3`,
+	)
+	if strings.Contains(body, "/subscriptions/1") {
+		t.Error("expected IDs from the path not to be used as labels")
+	}
+}
+
+func TestInstrumentStorage(t *testing.T) {
+	m := metrics.New()
+	id := uuid.New()
+	store := m.InstrumentStorage(&fakeStorage{id: id})
+	ctx := context.Background()
+
+	if _, err := store.GetSubscriptionByID(ctx, id); err != nil {
+		t.Fatalf("GetSubscriptionByID() error = %v", err)
+	}
+	if _, err := store.GetSubscriptionByID(ctx, uuid.New()); err != storage.ErrNotFound {
+		t.Fatalf("GetSubscriptionByID() error = %v, want ErrNotFound", err)
+	}
+
+	assertContains(t, scrape(t, m),
+		`storage_operation_duration_seconds_count{method="GetSubscriptionByID"} 2`,
+		`storage_operation_errors_total{method="GetSubscriptionByID"} 1`,
+	)
+}
+
+func TestSetSubscriptionStatsReplacesTenants(t *testing.T) {
+	m := metrics.New()
+	m.SetSubscriptionStats([]storage.SubscriptionStats{
+		{TenantID: "a", Active: 3, MonthlyTotal: 1500},
+		{TenantID: "b", Active: 1, MonthlyTotal: 200},
+	})
+	m.SetSubscriptionStats([]storage.SubscriptionStats{
+		{TenantID: "a", Active: 2, MonthlyTotal: 1000},
+	})
+
+	body := scrape(t, m)
+	assertContains(t, body,
+		`subscriptions_active{tenant_id="a"} 2`,
+		`subscriptions_monthly_recurring_price{tenant_id="a"} 1000`,
+	)
+	if strings.Contains(body, `tenant_id="b"`) {
+		t.Error("expected tenant without subscriptions to be removed from metrics")
+	}
+}
@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// InstrumentStorage оборачивает next и записывает длительность и ошибки каждого метода
+func (m *Metrics) InstrumentStorage(next storage.StorageInterface) storage.StorageInterface {
+	return &instrumentedStorage{next: next, m: m}
+}
+
+type instrumentedStorage struct {
+	next storage.StorageInterface
+	m    *Metrics
+}
+
+// observe вызывается через defer: время начала вычисляется сразу, а ошибка читается после возврата
+func (s *instrumentedStorage) observe(method string, start time.Time, err *error) {
+	s.m.observeStorage(method, start, *err)
+}
+
+func (s *instrumentedStorage) CreateSubscription(ctx context.Context, sub *models.Subscription) (err error) {
+	defer s.observe("CreateSubscription", time.Now(), &err)
+	return s.next.CreateSubscription(ctx, sub)
+}
+
+func (s *instrumentedStorage) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (_ *models.Subscription, err error) {
+	defer s.observe("GetSubscriptionByID", time.Now(), &err)
+	return s.next.GetSubscriptionByID(ctx, id)
+}
+
+func (s *instrumentedStorage) ListSubscriptions(ctx context.Context, filter storage.ListFilter) (_ []models.Subscription, err error) {
+	defer s.observe("ListSubscriptions", time.Now(), &err)
+	return s.next.ListSubscriptions(ctx, filter)
+}
+
+func (s *instrumentedStorage) UpdateSubscription(ctx context.Context, sub *models.Subscription) (err error) {
+	defer s.observe("UpdateSubscription", time.Now(), &err)
+	return s.next.UpdateSubscription(ctx, sub)
+}
+
+func (s *instrumentedStorage) DeleteSubscription(ctx context.Context, id uuid.UUID, version int) (err error) {
+	defer s.observe("DeleteSubscription", time.Now(), &err)
+	return s.next.DeleteSubscription(ctx, id, version)
+}
+
+func (s *instrumentedStorage) SumSubscriptionsCost(ctx context.Context, userID, serviceName string, filterStart, filterEnd time.Time) (_ int64, err error) {
+	defer s.observe("SumSubscriptionsCost", time.Now(), &err)
+	return s.next.SumSubscriptionsCost(ctx, userID, serviceName, filterStart, filterEnd)
+}
+
+func (s *instrumentedStorage) SumSubscriptionsCostByCategory(ctx context.Context, userID, serviceName string, filterStart, filterEnd time.Time) (_ map[string]int64, err error) {
+	defer s.observe("SumSubscriptionsCostByCategory", time.Now(), &err)
+	return s.next.SumSubscriptionsCostByCategory(ctx, userID, serviceName, filterStart, filterEnd)
+}
+
+func (s *instrumentedStorage) CreateService(ctx context.Context, svc *models.Service) (err error) {
+	defer s.observe("CreateService", time.Now(), &err)
+	return s.next.CreateService(ctx, svc)
+}
+
+func (s *instrumentedStorage) GetServiceByID(ctx context.Context, id uuid.UUID) (_ *models.Service, err error) {
+	defer s.observe("GetServiceByID", time.Now(), &err)
+	return s.next.GetServiceByID(ctx, id)
+}
+
+func (s *instrumentedStorage) ListServices(ctx context.Context, category string) (_ []models.Service, err error) {
+	defer s.observe("ListServices", time.Now(), &err)
+	return s.next.ListServices(ctx, category)
+}
+
+func (s *instrumentedStorage) UpdateService(ctx context.Context, svc *models.Service) (err error) {
+	defer s.observe("UpdateService", time.Now(), &err)
+	return s.next.UpdateService(ctx, svc)
+}
+
+func (s *instrumentedStorage) DeleteService(ctx context.Context, id uuid.UUID) (err error) {
+	defer s.observe("DeleteService", time.Now(), &err)
+	return s.next.DeleteService(ctx, id)
+}
+
+func (s *instrumentedStorage) ListTags(ctx context.Context) (_ []models.Tag, err error) {
+	defer s.observe("ListTags", time.Now(), &err)
+	return s.next.ListTags(ctx)
+}
+
+func (s *instrumentedStorage) PauseSubscription(ctx context.Context, id uuid.UUID, date time.Time) (_ *models.Pause, _ int, err error) {
+	defer s.observe("PauseSubscription", time.Now(), &err)
+	return s.next.PauseSubscription(ctx, id, date)
+}
+
+func (s *instrumentedStorage) ResumeSubscription(ctx context.Context, id uuid.UUID, date time.Time) (_ *models.Pause, _ int, err error) {
+	defer s.observe("ResumeSubscription", time.Now(), &err)
+	return s.next.ResumeSubscription(ctx, id, date)
+}
+
+func (s *instrumentedStorage) ListTrialsEndingSoon(ctx context.Context, userID string, from, to time.Time) (_ []models.Subscription, err error) {
+	defer s.observe("ListTrialsEndingSoon", time.Now(), &err)
+	return s.next.ListTrialsEndingSoon(ctx, userID, from, to)
+}
+
+func (s *instrumentedStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) (err error) {
+	defer s.observe("CreateAPIKey", time.Now(), &err)
+	return s.next.CreateAPIKey(ctx, key)
+}
+
+func (s *instrumentedStorage) GetAPIKeyByHash(ctx context.Context, hash string) (_ *models.APIKey, err error) {
+	defer s.observe("GetAPIKeyByHash", time.Now(), &err)
+	return s.next.GetAPIKeyByHash(ctx, hash)
+}
+
+func (s *instrumentedStorage) ListAPIKeys(ctx context.Context) (_ []models.APIKey, err error) {
+	defer s.observe("ListAPIKeys", time.Now(), &err)
+	return s.next.ListAPIKeys(ctx)
+}
+
+func (s *instrumentedStorage) RevokeAPIKey(ctx context.Context, id uuid.UUID) (err error) {
+	defer s.observe("RevokeAPIKey", time.Now(), &err)
+	return s.next.RevokeAPIKey(ctx, id)
+}
+
+func (s *instrumentedStorage) RotateAPIKey(ctx context.Context, key *models.APIKey) (err error) {
+	defer s.observe("RotateAPIKey", time.Now(), &err)
+	return s.next.RotateAPIKey(ctx, key)
+}
+
+func (s *instrumentedStorage) TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) (err error) {
+	defer s.observe("TouchAPIKey", time.Now(), &err)
+	return s.next.TouchAPIKey(ctx, id, at)
+}
+
+func (s *instrumentedStorage) ReserveIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) (_ *models.IdempotencyRecord, err error) {
+	defer s.observe("ReserveIdempotencyKey", time.Now(), &err)
+	return s.next.ReserveIdempotencyKey(ctx, rec)
+}
+
+func (s *instrumentedStorage) CompleteIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) (err error) {
+	defer s.observe("CompleteIdempotencyKey", time.Now(), &err)
+	return s.next.CompleteIdempotencyKey(ctx, rec)
+}
+
+func (s *instrumentedStorage) ReleaseIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) (err error) {
+	defer s.observe("ReleaseIdempotencyKey", time.Now(), &err)
+	return s.next.ReleaseIdempotencyKey(ctx, rec)
+}
@@ -1,22 +1,192 @@
+// Package migrations управляет схемой базы через goose. Миграции встроены
+// в бинарник (storage.Migrations), поэтому команды работают из любого каталога.
 package migrations
 
 import (
-	"log"
-	"subscribe_aggregation-main/internal/config"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"subscribe_aggregation-main/internal/storage"
 
 	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
 )
 
-func RunMigrations() {
-	config.InitDB() // Инициализация базы, если нужно
+// versionLayout — формат версии в имени файла миграции, например 202510182100
+const versionLayout = "200601021504"
+
+// Commands — команды миграций и их аргументы
+var Commands = []struct{ Name, Usage string }{
+	{"up", "apply all pending migrations"},
+	{"up-by-one", "apply the next pending migration"},
+	{"up-to VERSION", "apply pending migrations up to VERSION"},
+	{"down", "roll back the latest migration"},
+	{"down-to VERSION", "roll back migrations down to VERSION (0 rolls back all)"},
+	{"redo", "roll back the latest migration and apply it again"},
+	{"status", "show applied and pending migrations"},
+	{"version", "show the current schema version"},
+	{"create NAME", "create an empty SQL migration in --dir"},
+}
+
+// newProvider создает goose с встроенными миграциями. Блокировка сессии PostgreSQL
+// не дает нескольким экземплярам сервера применять миграции одновременно.
+func newProvider(db *sql.DB) (*goose.Provider, error) {
+	fsys, err := fs.Sub(storage.Migrations, storage.MigrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(goose.DialectPostgres, db, fsys, goose.WithSessionLocker(locker))
+}
+
+// Up применяет все новые миграции и возвращает число примененных
+func Up(ctx context.Context, db *sql.DB) (int, error) {
+	provider, err := newProvider(db)
+	if err != nil {
+		return 0, err
+	}
+	results, err := provider.Up(ctx)
+	return len(results), err
+}
+
+func parseVersion(args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("expected one VERSION argument")
+	}
+	version, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid version %q", args[0])
+	}
+	return version, nil
+}
+
+// printResults выводит примененные или отмененные миграции
+func printResults(w io.Writer, results ...*goose.MigrationResult) {
+	if len(results) == 0 {
+		fmt.Fprintln(w, "no migrations to run")
+	}
+	for _, r := range results {
+		if r != nil {
+			fmt.Fprintf(w, "%-4s %s (%s)\n", r.Direction, filepath.Base(r.Source.Path), r.Duration.Round(time.Millisecond))
+		}
+	}
+}
+
+// Run выполняет команду миграции, кроме create, и печатает результат в w
+func Run(ctx context.Context, db *sql.DB, w io.Writer, command string, args []string) error {
+	provider, err := newProvider(db)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "up":
+		results, err := provider.Up(ctx)
+		printResults(w, results...)
+		return err
+	case "up-by-one":
+		result, err := provider.UpByOne(ctx)
+		printResults(w, result)
+		return err
+	case "up-to":
+		version, err := parseVersion(args)
+		if err != nil {
+			return err
+		}
+		results, err := provider.UpTo(ctx, version)
+		printResults(w, results...)
+		return err
+	case "down":
+		result, err := provider.Down(ctx)
+		printResults(w, result)
+		return err
+	case "down-to":
+		version, err := parseVersion(args)
+		if err != nil {
+			return err
+		}
+		results, err := provider.DownTo(ctx, version)
+		printResults(w, results...)
+		return err
+	case "redo":
+		down, err := provider.Down(ctx)
+		if err != nil {
+			return err
+		}
+		up, err := provider.UpByOne(ctx)
+		printResults(w, down, up)
+		return err
+	case "status":
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tMIGRATION")
+		for _, s := range statuses {
+			appliedAt := "-"
+			if !s.AppliedAt.IsZero() {
+				appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, appliedAt, filepath.Base(s.Source.Path))
+		}
+		return tw.Flush()
+	case "version":
+		version, err := provider.GetDBVersion(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, version)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// nameSanitizer заменяет символы, недопустимые в имени файла миграции
+var nameSanitizer = regexp.MustCompile(`[^a-z0-9]+`)
+
+// migrationTemplate — содержимое новой миграции
+const migrationTemplate = "-- +goose Up\n\n-- +goose Down\n"
 
-	if err := goose.SetDialect("postgres"); err != nil {
-		log.Fatalf("Failed to set goose dialect: %v", err)
+// Create создает пустую SQL миграцию в каталоге dir и возвращает путь к файлу.
+// Версия — время создания в UTC с точностью до минуты, как у существующих миграций.
+func Create(dir, name string, now time.Time) (string, error) {
+	name = strings.Trim(nameSanitizer.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", fmt.Errorf("migration name is required")
 	}
 
-	if err := goose.Up(config.DB.DB, "internal/storage/migrations"); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+	version := now.UTC().Format(versionLayout)
+	existing, err := filepath.Glob(filepath.Join(dir, version+"_*.sql"))
+	if err != nil {
+		return "", err
+	}
+	if len(existing) > 0 {
+		return "", fmt.Errorf("migration version %s already exists: %s", version, filepath.Base(existing[0]))
 	}
 
-	log.Println("Migrations applied successfully")
+	path := filepath.Join(dir, version+"_"+name+".sql")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(migrationTemplate); err != nil {
+		return "", err
+	}
+	return path, nil
 }
@@ -0,0 +1,49 @@
+package migrations_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"subscribe_aggregation-main/internal/migrations"
+	"subscribe_aggregation-main/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 10, 18, 21, 5, 30, 0, time.FixedZone("MSK", 3*60*60))
+
+	path, err := migrations.Create(dir, "Add Users Index!", now)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "202510181805_add_users_index.sql"), path)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "-- +goose Up\n\n-- +goose Down\n", string(content))
+
+	// Вторая миграция с той же версией сломала бы порядок применения
+	_, err = migrations.Create(dir, "other", now)
+	assert.ErrorContains(t, err, "already exists")
+
+	_, err = migrations.Create(dir, "!!!", now.Add(time.Minute))
+	assert.Error(t, err)
+}
+
+// Встроенные миграции совпадают с файлами в репозитории
+func TestEmbeddedMigrations(t *testing.T) {
+	onDisk, err := filepath.Glob(filepath.Join("..", "..", "storage", storage.MigrationsDir, "*.sql"))
+	require.NoError(t, err)
+	require.NotEmpty(t, onDisk)
+
+	embedded, err := fs.Glob(storage.Migrations, storage.MigrationsDir+"/*.sql")
+	require.NoError(t, err)
+	require.Len(t, embedded, len(onDisk))
+	for i := range onDisk {
+		assert.Equal(t, filepath.Base(onDisk[i]), filepath.Base(embedded[i]))
+	}
+}
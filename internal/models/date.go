@@ -0,0 +1,54 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DateFormats — поддерживаемые форматы дат в запросах, в порядке проверки
+var DateFormats = []string{"01-2006", "2006-01", "2006-01-02", time.RFC3339}
+
+// monthFormats — форматы без дня месяца
+var monthFormats = map[string]bool{"01-2006": true, "2006-01": true}
+
+// DateError возвращается, если строка не подходит ни под один из DateFormats
+type DateError struct {
+	Value string
+}
+
+func (e *DateError) Error() string {
+	return fmt.Sprintf("invalid date %q, expected MM-YYYY, YYYY-MM, YYYY-MM-DD or RFC 3339", e.Value)
+}
+
+// parseDate разбирает дату и сообщает, был ли указан только месяц.
+// Время и часовой пояс RFC 3339 отбрасываются: остается календарная дата в UTC.
+func parseDate(s string) (time.Time, bool, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range DateFormats {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), monthFormats[layout], nil
+	}
+	return time.Time{}, false, &DateError{Value: s}
+}
+
+// ParseDate разбирает дату начала периода.
+// Месяц без дня (MM-YYYY, YYYY-MM) приводится к первому дню месяца.
+func ParseDate(s string) (time.Time, error) {
+	t, _, err := parseDate(s)
+	return t, err
+}
+
+// ParseEndDate разбирает дату окончания периода.
+// Месяц без дня (MM-YYYY, YYYY-MM) приводится к последнему дню месяца,
+// чтобы период включал месяц целиком.
+func ParseEndDate(s string) (time.Time, error) {
+	t, monthOnly, err := parseDate(s)
+	if err != nil || !monthOnly {
+		return t, err
+	}
+	return t.AddDate(0, 1, -1), nil
+}
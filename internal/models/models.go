@@ -5,8 +5,12 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+// DataOnly — календарная дата без времени. Принимает форматы из DateFormats,
+// месяц без дня приводится к первому дню месяца: стоимость считается помесячно
+// с учетом неполных месяцев, поэтому такой месяц входит в период целиком.
 type DataOnly time.Time
 
 func (d *DataOnly) UnmarshalJSON(b []byte) error {
@@ -14,7 +18,7 @@ func (d *DataOnly) UnmarshalJSON(b []byte) error {
 	if s == "" || s == "null" {
 		return nil
 	}
-	t, err := time.Parse("2006-01-02", s)
+	t, err := ParseDate(s)
 	if err != nil {
 		return err
 	}
@@ -29,13 +33,137 @@ func (d DataOnly) ToTime() time.Time {
 	return time.Time(d).Truncate(time.Second)
 }
 
+// EndDate — дата окончания периода. В отличие от DataOnly месяц без дня приводится
+// к последнему дню месяца, как end_date в параметрах запроса, gRPC и GraphQL.
+type EndDate DataOnly
+
+func (d *EndDate) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		return nil
+	}
+	t, err := ParseEndDate(s)
+	if err != nil {
+		return err
+	}
+	*d = EndDate(t)
+	return nil
+}
+func (d EndDate) MarshalJSON() ([]byte, error) {
+	return DataOnly(d).MarshalJSON()
+}
+func (d EndDate) ToTime() time.Time {
+	return DataOnly(d).ToTime()
+}
+
 type Subscription struct {
-	ID          uuid.UUID `json:"id" db:"id"`
+	ID uuid.UUID `json:"id" db:"id"`
+	// TenantID — организация, которой принадлежит подписка. Задается из запроса, а не из тела
+	TenantID    string    `json:"-" db:"tenant_id"`
 	ServiceName string    `json:"service_name" db:"service_name"`
 	Price       int       `json:"price" db:"price"`
 	UserID      uuid.UUID `json:"user_id" db:"user_id"`
 	StartDate   DataOnly  `json:"start_date" db:"start_date"`
-	EndDate     *DataOnly `json:"end_date,omitempty" db:"end_date"`
+	EndDate     *EndDate  `json:"end_date,omitempty" db:"end_date"`
 	CreatedAt   DataOnly  `json:"created_at" db:"created_at"`
 	UpdatedAt   DataOnly  `json:"updated_at" db:"updated_at"`
+	// Version увеличивается при каждом изменении и передается в заголовке ETag
+	Version int `json:"version" db:"version"`
+	// SplitMode — режим разделения стоимости между участниками: "", equal или custom
+	SplitMode string `json:"split_mode,omitempty" db:"split_mode"`
+	// Tags — теги (категории) подписки, хранятся в отдельной таблице
+	Tags []string `json:"tags,omitempty" db:"-"`
+	// Members — участники совместной подписки с долями оплаты
+	Members []SubscriptionMember `json:"members,omitempty" db:"-"`
+	// Pauses — периоды приостановки, не входящие в стоимость
+	Pauses []Pause `json:"pauses,omitempty" db:"-"`
+	// Phases — пробные и вводные периоды от даты начала подписки
+	Phases []PricePhase `json:"phases,omitempty" db:"-"`
+	// TrialEndDate — дата первого списания полной цены, вычисляется по Phases
+	TrialEndDate *DataOnly `json:"trial_end_date,omitempty" db:"-"`
+}
+
+// PricePhase — пробный или вводный период: длительность в месяцах и цена в месяц (0 для бесплатного)
+type PricePhase struct {
+	Months int `json:"months" db:"months"`
+	Price  int `json:"price" db:"price"`
+}
+
+// Режимы разделения стоимости совместной подписки
+const (
+	SplitNone   = ""
+	SplitEqual  = "equal"
+	SplitCustom = "custom"
+)
+
+// SubscriptionMember — участник совместной подписки и его доля (от 0 до 1)
+type SubscriptionMember struct {
+	UserID uuid.UUID `json:"user_id" db:"user_id"`
+	Share  float64   `json:"share" db:"share"`
+}
+
+// Service — запись каталога сервисов с каноническим названием и алиасами
+type Service struct {
+	ID uuid.UUID `json:"id" db:"id"`
+	// TenantID — организация, у каждой из которых свой каталог
+	TenantID     string         `json:"-" db:"tenant_id"`
+	Name         string         `json:"name" db:"name"`
+	Aliases      pq.StringArray `json:"aliases" db:"aliases" swaggertype:"array,string"`
+	Category     string         `json:"category" db:"category"`
+	DefaultPrice int            `json:"default_price" db:"default_price"`
+	Homepage     string         `json:"homepage" db:"homepage"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// APIKey — ключ доступа для сервисов и фоновых задач. В базе хранится только хеш ключа,
+// сам ключ возвращается один раз при выпуске или ротации.
+type APIKey struct {
+	ID uuid.UUID `json:"id" db:"id"`
+	// TenantID — организация, к данным которой ключ дает доступ
+	TenantID string         `json:"tenant_id" db:"tenant_id"`
+	Name     string         `json:"name" db:"name"`
+	Prefix   string         `json:"prefix" db:"prefix"`
+	Hash     string         `json:"-" db:"key_hash"`
+	Scopes   pq.StringArray `json:"scopes" db:"scopes" swaggertype:"array,string"`
+	// Key — открытый ключ, заполняется только в ответе на выпуск и ротацию
+	Key        string     `json:"key,omitempty" db:"-"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// IdempotencyRecord — первый ответ на запрос с заголовком Idempotency-Key.
+// Ключ действует в пределах организации и клиента, StatusCode 0 означает,
+// что первый запрос еще выполняется.
+type IdempotencyRecord struct {
+	TenantID     string    `db:"tenant_id"`
+	Client       string    `db:"client"`
+	Key          string    `db:"key"`
+	RequestHash  string    `db:"request_hash"`
+	StatusCode   int       `db:"status_code"`
+	ContentType  string    `db:"content_type"`
+	ETag         string    `db:"etag"`
+	ResponseBody []byte    `db:"response_body"`
+	CreatedAt    time.Time `db:"created_at"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	// Lease — сколько может выполняться первый запрос. Незавершенную запись старше Lease
+	// занимает следующий запрос: первый, скорее всего, прервался, не сохранив ответ
+	Lease time.Duration `db:"-"`
+}
+
+// Tag — тег (категория) подписки, например "video" или "music"
+type Tag struct {
+	ID       uuid.UUID `json:"id" db:"id"`
+	TenantID string    `json:"-" db:"tenant_id"`
+	Name     string    `json:"name" db:"name"`
+}
+
+// Pause — период приостановки подписки. StartDate — первый день паузы,
+// EndDate — дата возобновления (пусто, пока подписка приостановлена).
+type Pause struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	SubscriptionID uuid.UUID `json:"subscription_id" db:"subscription_id"`
+	StartDate      DataOnly  `json:"start_date" db:"start_date"`
+	EndDate        *DataOnly `json:"end_date,omitempty" db:"end_date"`
 }
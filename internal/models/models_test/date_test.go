@@ -0,0 +1,62 @@
+package models_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"subscribe_aggregation-main/internal/models"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		input   string
+		start   time.Time
+		end     time.Time
+		wantErr bool
+	}{
+		{input: "07-2025", start: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), end: time.Date(2025, 7, 31, 0, 0, 0, 0, time.UTC)},
+		{input: "2024-02", start: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), end: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{input: "2025-07-15", start: time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC), end: time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC)},
+		{input: "2025-07-15T23:30:00+03:00", start: time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC), end: time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC)},
+		{input: "15.07.2025", wantErr: true},
+		{input: "13-2025", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			start, err := models.ParseDate(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDate(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !start.Equal(tt.start) {
+				t.Errorf("ParseDate(%q) = %v, want %v", tt.input, start, tt.start)
+			}
+
+			end, err := models.ParseEndDate(tt.input)
+			if err != nil {
+				t.Fatalf("ParseEndDate(%q) error = %v", tt.input, err)
+			}
+			if !end.Equal(tt.end) {
+				t.Errorf("ParseEndDate(%q) = %v, want %v", tt.input, end, tt.end)
+			}
+		})
+	}
+}
+
+func TestDataOnlyUnmarshalMonth(t *testing.T) {
+	var sub models.Subscription
+	if err := json.Unmarshal([]byte(`{"start_date": "07-2025", "end_date": "2025-12"}`), &sub); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got := sub.StartDate.ToTime(); !got.Equal(time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("StartDate = %v, want 2025-07-01", got)
+	}
+	// Месяц без дня в end_date включает последний день, как в параметрах запроса
+	if sub.EndDate == nil || !sub.EndDate.ToTime().Equal(time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("EndDate = %v, want 2025-12-31", sub.EndDate)
+	}
+}
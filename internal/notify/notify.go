@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"subscribe_aggregation-main/internal/tenant"
+	"subscribe_aggregation-main/pkg/logging"
+)
+
+// SignatureHeader — подпись тела запроса HMAC-SHA256 с секретом вебхука в виде sha256=<hex>
+const SignatureHeader = "X-Signature"
+
+// Типы событий
+const (
+	SubscriptionCreated = "subscription.created"
+	SubscriptionUpdated = "subscription.updated"
+	SubscriptionDeleted = "subscription.deleted"
+	SubscriptionPaused  = "subscription.paused"
+	SubscriptionResumed = "subscription.resumed"
+)
+
+// Config — настройки вебхука. Пустой URL отключает уведомления.
+type Config struct {
+	URL     string
+	Secret  string
+	Timeout time.Duration
+}
+
+// Event — тело запроса к вебхуку
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	TenantID string    `json:"tenant_id"`
+	// Data — подписка, пауза или, для удаления, ID подписки
+	Data any `json:"data"`
+}
+
+// Notifier отправляет события об изменении подписок на вебхук.
+// Отправка асинхронная и не влияет на ответ API: ошибки только пишутся в лог.
+// Настройки меняются без перезапуска через SetConfig.
+type Notifier struct {
+	client *http.Client
+	config atomic.Pointer[Config]
+	wg     sync.WaitGroup
+}
+
+// New создает Notifier с настройками cfg
+func New(cfg Config) *Notifier {
+	n := &Notifier{client: &http.Client{}}
+	n.SetConfig(cfg)
+	return n
+}
+
+// SetConfig заменяет настройки, например при перезагрузке конфигурации.
+// Уже начатые отправки завершаются со старыми настройками.
+func (n *Notifier) SetConfig(cfg Config) {
+	n.config.Store(&cfg)
+}
+
+// Notify отправляет событие в фоне. Request ID и traceparent берутся из ctx,
+// но отмена ctx отправку не прерывает: запрос API к этому времени уже завершен.
+func (n *Notifier) Notify(ctx context.Context, eventType string, data any) {
+	cfg := n.config.Load()
+	if cfg.URL == "" {
+		return
+	}
+	event := Event{
+		Type:     eventType,
+		Time:     time.Now().UTC(),
+		TenantID: tenant.FromContext(ctx),
+		Data:     data,
+	}
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		ctx := context.WithoutCancel(ctx)
+		if err := n.send(ctx, cfg, event); err != nil {
+			logging.GetLogger().ErrorContext(ctx, "Notify: failed to send event",
+				slog.String("type", event.Type),
+				slog.String("error", err.Error()))
+		}
+	}()
+}
+
+// Wait ждет завершения начатых отправок, например при остановке сервера
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+// send отправляет одно событие с таймаутом из настроек
+func (n *Notifier) send(ctx context.Context, cfg *Config, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(cfg.Secret, body))
+	}
+	logging.SetRequestHeaders(ctx, req.Header)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign возвращает значение заголовка SignatureHeader для тела body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,135 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/notify"
+	"subscribe_aggregation-main/internal/storage"
+	"subscribe_aggregation-main/internal/tenant"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStorage создает и удаляет подписки. Остальные методы остаются
+// у встроенного nil интерфейса и паникуют при вызове.
+type fakeStorage struct {
+	storage.StorageInterface
+	err error
+}
+
+func (f *fakeStorage) CreateSubscription(ctx context.Context, sub *models.Subscription) error {
+	sub.ID = uuid.New()
+	return f.err
+}
+
+func (f *fakeStorage) DeleteSubscription(ctx context.Context, id uuid.UUID, version int) error {
+	return f.err
+}
+
+// request — запрос, полученный вебхуком
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+// webhook запускает вебхук, запоминающий запросы
+func webhook(t *testing.T, status int) (*httptest.Server, func() []request) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, request{header: r.Header, body: body})
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func TestNotifierStorage(t *testing.T) {
+	srv, received := webhook(t, http.StatusNoContent)
+	n := notify.New(notify.Config{URL: srv.URL, Secret: "s3cret", Timeout: time.Second})
+	store := n.Storage(&fakeStorage{})
+
+	ctx := tenant.NewContext(logging.ContextWithRequestID(context.Background(), "req-1"), "acme")
+	ctx, cancel := context.WithCancel(ctx)
+	sub := &models.Subscription{ServiceName: "Netflix", Price: 500}
+	require.NoError(t, store.CreateSubscription(ctx, sub))
+	// Завершение запроса API не отменяет отправку
+	cancel()
+	n.Wait()
+
+	requests := received()
+	require.Len(t, requests, 1)
+	req := requests[0]
+	assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+	assert.Equal(t, notify.Sign("s3cret", req.body), req.header.Get(notify.SignatureHeader))
+	assert.Equal(t, "req-1", req.header.Get(logging.RequestIDHeader))
+
+	var event struct {
+		Type     string              `json:"type"`
+		TenantID string              `json:"tenant_id"`
+		Data     models.Subscription `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(req.body, &event))
+	assert.Equal(t, notify.SubscriptionCreated, event.Type)
+	assert.Equal(t, "acme", event.TenantID)
+	assert.Equal(t, sub.ID, event.Data.ID)
+	assert.Equal(t, "Netflix", event.Data.ServiceName)
+}
+
+func TestNotifierSkipsFailedWrites(t *testing.T) {
+	srv, received := webhook(t, http.StatusNoContent)
+	n := notify.New(notify.Config{URL: srv.URL, Timeout: time.Second})
+	store := n.Storage(&fakeStorage{err: storage.ErrNotFound})
+
+	assert.ErrorIs(t, store.DeleteSubscription(context.Background(), uuid.New(), 0), storage.ErrNotFound)
+	n.Wait()
+	assert.Empty(t, received())
+}
+
+func TestNotifierSetConfig(t *testing.T) {
+	srv, received := webhook(t, http.StatusNoContent)
+	n := notify.New(notify.Config{Timeout: time.Second})
+	store := n.Storage(&fakeStorage{})
+
+	// Без URL события не отправляются
+	id := uuid.New()
+	require.NoError(t, store.DeleteSubscription(context.Background(), id, 0))
+	n.Wait()
+	assert.Empty(t, received())
+
+	n.SetConfig(notify.Config{URL: srv.URL, Timeout: time.Second})
+	require.NoError(t, store.DeleteSubscription(context.Background(), id, 0))
+	n.Wait()
+
+	requests := received()
+	require.Len(t, requests, 1)
+	assert.Empty(t, requests[0].header.Get(notify.SignatureHeader))
+	assert.JSONEq(t, `{"id":"`+id.String()+`"}`, string(mustField(t, requests[0].body, "data")))
+}
+
+// mustField возвращает поле JSON объекта без разбора
+func mustField(t *testing.T, body []byte, name string) json.RawMessage {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(body, &fields))
+	return fields[name]
+}
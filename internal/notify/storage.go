@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"context"
+	"time"
+
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// Storage оборачивает next и отправляет событие после каждого успешного изменения подписки
+func (n *Notifier) Storage(next storage.StorageInterface) storage.StorageInterface {
+	return &notifyingStorage{StorageInterface: next, n: n}
+}
+
+// notifyingStorage переопределяет только методы, изменяющие подписки
+type notifyingStorage struct {
+	storage.StorageInterface
+	n *Notifier
+}
+
+// deletedSubscription — данные события удаления
+type deletedSubscription struct {
+	ID uuid.UUID `json:"id"`
+}
+
+func (s *notifyingStorage) CreateSubscription(ctx context.Context, sub *models.Subscription) error {
+	if err := s.StorageInterface.CreateSubscription(ctx, sub); err != nil {
+		return err
+	}
+	s.n.Notify(ctx, SubscriptionCreated, *sub)
+	return nil
+}
+
+func (s *notifyingStorage) UpdateSubscription(ctx context.Context, sub *models.Subscription) error {
+	if err := s.StorageInterface.UpdateSubscription(ctx, sub); err != nil {
+		return err
+	}
+	s.n.Notify(ctx, SubscriptionUpdated, *sub)
+	return nil
+}
+
+func (s *notifyingStorage) DeleteSubscription(ctx context.Context, id uuid.UUID, version int) error {
+	if err := s.StorageInterface.DeleteSubscription(ctx, id, version); err != nil {
+		return err
+	}
+	s.n.Notify(ctx, SubscriptionDeleted, deletedSubscription{ID: id})
+	return nil
+}
+
+func (s *notifyingStorage) PauseSubscription(ctx context.Context, id uuid.UUID, date time.Time) (*models.Pause, int, error) {
+	pause, version, err := s.StorageInterface.PauseSubscription(ctx, id, date)
+	if err != nil {
+		return nil, 0, err
+	}
+	s.n.Notify(ctx, SubscriptionPaused, *pause)
+	return pause, version, nil
+}
+
+func (s *notifyingStorage) ResumeSubscription(ctx context.Context, id uuid.UUID, date time.Time) (*models.Pause, int, error) {
+	pause, version, err := s.StorageInterface.ResumeSubscription(ctx, id, date)
+	if err != nil {
+		return nil, 0, err
+	}
+	s.n.Notify(ctx, SubscriptionResumed, *pause)
+	return pause, version, nil
+}
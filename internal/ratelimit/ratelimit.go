@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limit — параметры token bucket: Rate токенов в секунду и не больше Burst токенов в запасе
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result — решение по запросу и состояние квоты клиента для заголовков RateLimit-*
+type Result struct {
+	Allowed bool
+	Limit   int
+	// Remaining — сколько запросов клиент может сделать прямо сейчас
+	Remaining int
+	// Reset — через сколько квота восстановится полностью
+	Reset time.Duration
+	// RetryAfter — через сколько появится токен для отклоненного запроса
+	RetryAfter time.Duration
+}
+
+// Bucket — состояние квоты одного клиента
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// NewBucket возвращает полную квоту
+func NewBucket(limit Limit, now time.Time) Bucket {
+	return Bucket{Tokens: float64(limit.Burst), Updated: now}
+}
+
+// Take пополняет квоту за прошедшее время и списывает токен, если он есть.
+// Отклоненный запрос токен не списывает.
+func (b *Bucket) Take(limit Limit, now time.Time) Result {
+	if elapsed := now.Sub(b.Updated).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(limit.Burst), b.Tokens+elapsed*limit.Rate)
+	}
+	b.Updated = now
+
+	result := Result{Limit: limit.Burst}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.Tokens) / limit.Rate)
+	}
+	result.Remaining = int(b.Tokens)
+	result.Reset = seconds((float64(limit.Burst) - b.Tokens) / limit.Rate)
+	return result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Store хранит квоты клиентов
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Limiter применяет одну квоту к клиентам. Name отделяет квоты разных лимитеров в общем хранилище
+type Limiter struct {
+	Name  string
+	Store Store
+	limit atomic.Pointer[Limit]
+}
+
+// NewLimiter создает лимитер с квотой limit
+func NewLimiter(name string, store Store, limit Limit) *Limiter {
+	l := &Limiter{Name: name, Store: store}
+	l.SetLimit(limit)
+	return l
+}
+
+// SetLimit меняет квоту на лету, например при перезагрузке конфигурации.
+// Накопленные токены клиентов сохраняются и ограничиваются новым Burst.
+func (l *Limiter) SetLimit(limit Limit) {
+	l.limit.Store(&limit)
+}
+
+// Allow списывает токен из квоты клиента
+func (l *Limiter) Allow(ctx context.Context, client string) (Result, error) {
+	return l.Store.Take(ctx, l.Name+":"+client, *l.limit.Load(), time.Now())
+}
+
+// sweepInterval — как часто MemoryStore удаляет восстановившиеся квоты
+const sweepInterval = time.Minute
+
+// MemoryStore хранит квоты в памяти процесса. Подходит для одного экземпляра сервиса
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	Bucket
+	// full — когда квота восстановится полностью и запись можно удалить
+	full time.Time
+}
+
+// NewMemoryStore создает пустое хранилище квот в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket)}
+}
+
+func (m *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &memoryBucket{Bucket: NewBucket(limit, now)}
+		m.buckets[key] = b
+	}
+	result := b.Take(limit, now)
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+// sweep удаляет полные квоты, чтобы память не росла с числом клиентов
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+}
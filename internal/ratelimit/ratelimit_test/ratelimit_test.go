@@ -0,0 +1,84 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"subscribe_aggregation-main/internal/ratelimit"
+)
+
+func TestBucketTake(t *testing.T) {
+	limit := ratelimit.Limit{Rate: 1, Burst: 2}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	bucket := ratelimit.NewBucket(limit, now)
+
+	for i, want := range []int{1, 0} {
+		result := bucket.Take(limit, now)
+		if !result.Allowed || result.Remaining != want {
+			t.Fatalf("request %d: Allowed = %v, Remaining = %d, want true and %d", i, result.Allowed, result.Remaining, want)
+		}
+	}
+
+	result := bucket.Take(limit, now)
+	if result.Allowed {
+		t.Fatal("expected request over burst to be rejected")
+	}
+	if result.RetryAfter != time.Second || result.Reset != 2*time.Second {
+		t.Errorf("RetryAfter = %v, Reset = %v, want 1s and 2s", result.RetryAfter, result.Reset)
+	}
+
+	// Через полсекунды токена еще нет, и отклоненные запросы его не тратят
+	if result = bucket.Take(limit, now.Add(500*time.Millisecond)); result.Allowed {
+		t.Error("expected request before refill to be rejected")
+	}
+	if result = bucket.Take(limit, now.Add(time.Second)); !result.Allowed {
+		t.Error("expected request after refill to be allowed")
+	}
+
+	// Квота не превышает Burst даже после долгого простоя
+	bucket.Take(limit, now.Add(time.Hour))
+	if bucket.Tokens != 1 {
+		t.Errorf("Tokens = %v, want 1", bucket.Tokens)
+	}
+}
+
+func TestMemoryStoreSeparatesClients(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	limiter := ratelimit.NewLimiter("api", store, ratelimit.Limit{Rate: 0.001, Burst: 1})
+	ctx := context.Background()
+
+	if result, _ := limiter.Allow(ctx, "user:a"); !result.Allowed {
+		t.Error("expected first request of user a to be allowed")
+	}
+	if result, _ := limiter.Allow(ctx, "user:a"); result.Allowed {
+		t.Error("expected second request of user a to be rejected")
+	}
+	if result, _ := limiter.Allow(ctx, "user:b"); !result.Allowed {
+		t.Error("expected user b to have its own quota")
+	}
+
+	// Квоты разных лимитеров в одном хранилище не пересекаются
+	other := ratelimit.NewLimiter("sum", store, ratelimit.Limit{Rate: 0.001, Burst: 1})
+	if result, _ := other.Allow(ctx, "user:a"); !result.Allowed {
+		t.Error("expected separate quota for another limiter")
+	}
+}
+
+func TestLimiterSetLimit(t *testing.T) {
+	limiter := ratelimit.NewLimiter("api", ratelimit.NewMemoryStore(), ratelimit.Limit{Rate: 0.001, Burst: 1})
+	ctx := context.Background()
+
+	limiter.Allow(ctx, "user:a")
+	if result, _ := limiter.Allow(ctx, "user:a"); result.Allowed {
+		t.Error("expected second request to be rejected with burst 1")
+	}
+
+	limiter.SetLimit(ratelimit.Limit{Rate: 0.001, Burst: 5})
+	for i := range 5 {
+		result, _ := limiter.Allow(ctx, "user:b")
+		if !result.Allowed || result.Limit != 5 {
+			t.Fatalf("request %d after SetLimit: Allowed = %v, Limit = %d, want true and 5", i+1, result.Allowed, result.Limit)
+		}
+	}
+}
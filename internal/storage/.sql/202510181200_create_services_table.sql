@@ -0,0 +1,39 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS services (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    category VARCHAR(100) NOT NULL DEFAULT '',
+    default_price INTEGER NOT NULL DEFAULT 0,
+    homepage TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_services_name_lower ON services (lower(name));
+CREATE INDEX IF NOT EXISTS idx_services_aliases ON services USING GIN (aliases);
+
+-- Убираем лишние пробелы в существующих названиях
+UPDATE subscriptions
+SET service_name = regexp_replace(btrim(service_name), '\s+', ' ', 'g')
+WHERE service_name <> regexp_replace(btrim(service_name), '\s+', ' ', 'g');
+
+-- Заполняем каталог: для каждого названия без учета регистра берем самое частое написание
+INSERT INTO services (name, aliases)
+SELECT DISTINCT ON (lower(service_name)) service_name, ARRAY[lower(service_name)]
+FROM subscriptions
+GROUP BY service_name
+ORDER BY lower(service_name), COUNT(*) DESC, service_name
+ON CONFLICT DO NOTHING;
+
+-- Переводим существующие подписки на канонические названия
+UPDATE subscriptions s
+SET service_name = c.name
+FROM services c
+WHERE lower(s.service_name) = ANY(c.aliases)
+  AND s.service_name <> c.name;
+
+-- +goose Down
+
+DROP TABLE IF EXISTS services;
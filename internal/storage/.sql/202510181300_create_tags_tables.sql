@@ -0,0 +1,34 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS tags (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS subscription_tags (
+    subscription_id UUID NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (subscription_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_tags_tag_id ON subscription_tags (tag_id);
+CREATE INDEX IF NOT EXISTS idx_services_category ON services (category);
+
+-- Категории из каталога сервисов становятся тегами существующих подписок
+INSERT INTO tags (name)
+SELECT DISTINCT lower(category)
+FROM services
+WHERE category <> ''
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO subscription_tags (subscription_id, tag_id)
+SELECT s.id, t.id
+FROM subscriptions s
+JOIN services c ON c.name = s.service_name
+JOIN tags t ON t.name = lower(c.category)
+ON CONFLICT DO NOTHING;
+
+-- +goose Down
+
+DROP TABLE IF EXISTS subscription_tags;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,19 @@
+-- +goose Up
+
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS split_mode VARCHAR(10) NOT NULL DEFAULT ''
+        CHECK (split_mode IN ('', 'equal', 'custom'));
+
+CREATE TABLE IF NOT EXISTS subscription_members (
+    subscription_id UUID NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    share NUMERIC(7, 6) NOT NULL CHECK (share > 0 AND share <= 1),
+    PRIMARY KEY (subscription_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_members_user_id ON subscription_members (user_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS subscription_members;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS split_mode;
@@ -0,0 +1,17 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS subscription_pauses (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    subscription_id UUID NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    start_date DATE NOT NULL,
+    end_date DATE,
+    CHECK (end_date IS NULL OR end_date >= start_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_pauses_subscription_id ON subscription_pauses (subscription_id);
+-- У подписки может быть только одна незавершенная пауза
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscription_pauses_open ON subscription_pauses (subscription_id) WHERE end_date IS NULL;
+
+-- +goose Down
+
+DROP TABLE IF EXISTS subscription_pauses;
@@ -0,0 +1,13 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS subscription_phases (
+    subscription_id UUID NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    months INTEGER NOT NULL CHECK (months > 0),
+    price INTEGER NOT NULL CHECK (price >= 0),
+    PRIMARY KEY (subscription_id, position)
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS subscription_phases;
@@ -0,0 +1,130 @@
+-- +goose Up
+
+-- Существующие данные принадлежат организации по умолчанию
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+ALTER TABLE services ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_tenant_user ON subscriptions (tenant_id, user_id);
+
+-- Каталог и теги у каждой организации свои
+DROP INDEX IF EXISTS idx_services_name_lower;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_services_tenant_name_lower ON services (tenant_id, lower(name));
+ALTER TABLE tags DROP CONSTRAINT IF EXISTS tags_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_tenant_name ON tags (tenant_id, name);
+
+-- Row-level security — вторая линия защиты от ошибок в условиях запросов.
+-- Политики ограничивают строки организацией из app.tenant_id, который приложение
+-- устанавливает в каждой транзакции при DB_ROW_LEVEL_SECURITY=true.
+-- Без этой настройки переменная пуста и политики ничего не ограничивают.
+ALTER TABLE subscriptions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE subscriptions FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON subscriptions
+    USING (COALESCE(current_setting('app.tenant_id', true), '') IN ('', tenant_id));
+
+ALTER TABLE services ENABLE ROW LEVEL SECURITY;
+ALTER TABLE services FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON services
+    USING (COALESCE(current_setting('app.tenant_id', true), '') IN ('', tenant_id));
+
+ALTER TABLE tags ENABLE ROW LEVEL SECURITY;
+ALTER TABLE tags FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON tags
+    USING (COALESCE(current_setting('app.tenant_id', true), '') IN ('', tenant_id));
+
+-- Связанные таблицы видны только через подписки своей организации
+ALTER TABLE subscription_tags ENABLE ROW LEVEL SECURITY;
+ALTER TABLE subscription_tags FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON subscription_tags
+    USING (subscription_id IN (SELECT id FROM subscriptions));
+
+ALTER TABLE subscription_members ENABLE ROW LEVEL SECURITY;
+ALTER TABLE subscription_members FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON subscription_members
+    USING (subscription_id IN (SELECT id FROM subscriptions));
+
+ALTER TABLE subscription_pauses ENABLE ROW LEVEL SECURITY;
+ALTER TABLE subscription_pauses FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON subscription_pauses
+    USING (subscription_id IN (SELECT id FROM subscriptions));
+
+ALTER TABLE subscription_phases ENABLE ROW LEVEL SECURITY;
+ALTER TABLE subscription_phases FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON subscription_phases
+    USING (subscription_id IN (SELECT id FROM subscriptions));
+
+-- +goose Down
+
+DROP POLICY IF EXISTS tenant_isolation ON subscription_phases;
+ALTER TABLE subscription_phases NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE subscription_phases DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON subscription_pauses;
+ALTER TABLE subscription_pauses NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE subscription_pauses DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON subscription_members;
+ALTER TABLE subscription_members NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE subscription_members DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON subscription_tags;
+ALTER TABLE subscription_tags NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE subscription_tags DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON tags;
+ALTER TABLE tags NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE tags DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON services;
+ALTER TABLE services NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE services DISABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON subscriptions;
+ALTER TABLE subscriptions NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE subscriptions DISABLE ROW LEVEL SECURITY;
+
+-- Без организаций названия тегов и сервисов снова уникальны глобально.
+-- Одинаковые записи разных организаций сливаются в одну: запись организации
+-- по умолчанию, иначе первой по tenant_id. Подписки переходят на оставшийся тег,
+-- а алиасы удаленных сервисов добавляются к оставшемуся.
+INSERT INTO subscription_tags (subscription_id, tag_id)
+SELECT st.subscription_id, r.keep_id
+FROM subscription_tags st
+JOIN (
+    SELECT id, first_value(id) OVER (PARTITION BY name ORDER BY tenant_id <> 'default', tenant_id, id) AS keep_id
+    FROM tags
+) r ON r.id = st.tag_id
+WHERE r.id <> r.keep_id
+ON CONFLICT DO NOTHING;
+
+DELETE FROM tags t
+USING (
+    SELECT id, first_value(id) OVER (PARTITION BY name ORDER BY tenant_id <> 'default', tenant_id, id) AS keep_id
+    FROM tags
+) r
+WHERE t.id = r.id AND r.id <> r.keep_id;
+
+UPDATE services c
+SET aliases = m.aliases
+FROM (
+    SELECT r.keep_id, array_agg(DISTINCT alias ORDER BY alias) AS aliases
+    FROM (
+        SELECT aliases, first_value(id) OVER (PARTITION BY lower(name) ORDER BY tenant_id <> 'default', tenant_id, id) AS keep_id
+        FROM services
+    ) r, unnest(r.aliases) AS alias
+    GROUP BY r.keep_id
+) m
+WHERE c.id = m.keep_id;
+
+DELETE FROM services c
+USING (
+    SELECT id, first_value(id) OVER (PARTITION BY lower(name) ORDER BY tenant_id <> 'default', tenant_id, id) AS keep_id
+    FROM services
+) r
+WHERE c.id = r.id AND r.id <> r.keep_id;
+
+DROP INDEX IF EXISTS idx_tags_tenant_name;
+ALTER TABLE tags ADD CONSTRAINT tags_name_key UNIQUE (name);
+DROP INDEX IF EXISTS idx_services_tenant_name_lower;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_services_name_lower ON services (lower(name));
+DROP INDEX IF EXISTS idx_subscriptions_tenant_user;
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE tags DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE services DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS tenant_id;
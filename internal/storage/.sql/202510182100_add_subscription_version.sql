@@ -0,0 +1,9 @@
+-- +goose Up
+
+-- Версия увеличивается при каждом изменении подписки и передается клиентам в ETag.
+-- Изменение с устаревшей версией отклоняется, чтобы не затереть чужую правку.
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+-- +goose Down
+
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS version;
@@ -0,0 +1,9 @@
+-- +goose Up
+
+-- ETag ответа на создание повторяется вместе с телом, чтобы клиент мог сразу
+-- передать его в If-Match
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS etag TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS etag;
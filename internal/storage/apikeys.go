@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/tenant"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// apiKeyTouchInterval — как часто обновляется last_used_at, чтобы не писать в базу на каждый запрос
+const apiKeyTouchInterval = time.Minute
+
+func (s *Storage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	if key.ID == uuid.Nil {
+		key.ID = uuid.New()
+	}
+	key.TenantID = tenant.FromContext(ctx)
+
+	query := sq.Insert("api_keys").
+		Columns("id", "tenant_id", "name", "prefix", "key_hash", "scopes", "created_at").
+		Values(key.ID, key.TenantID, key.Name, key.Prefix, key.Hash, key.Scopes, sq.Expr("NOW()")).
+		Suffix("RETURNING created_at").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.GetContext(ctx, &key.CreatedAt, sqlStr, args...); err != nil {
+		return mapError(err)
+	}
+	return nil
+}
+
+// GetAPIKeyByHash возвращает действующий ключ по хешу. Отозванные ключи не находятся.
+// Поиск идет по всем организациям: организация запроса определяется по найденному ключу.
+func (s *Storage) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	var key models.APIKey
+
+	query := sq.Select("*").
+		From("api_keys").
+		Where(sq.Eq{"key_hash": hash, "revoked_at": nil}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.GetContext(ctx, &key, sqlStr, args...); err != nil {
+		return nil, mapError(err)
+	}
+	return &key, nil
+}
+
+func (s *Storage) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	query := sq.Select("*").
+		From("api_keys").
+		Where(tenantEq(ctx, "tenant_id")).
+		OrderBy("created_at", "name").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []models.APIKey{}
+	err = s.db.SelectContext(ctx, &keys, sqlStr, args...)
+	return keys, err
+}
+
+// RevokeAPIKey отзывает ключ. Запись остается для аудита, повторный отзыв возвращает ErrNotFound
+func (s *Storage) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	query := sq.Update("api_keys").
+		Set("revoked_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id, "revoked_at": nil}).
+		Where(tenantEq(ctx, "tenant_id")).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RotateAPIKey заменяет хеш и префикс действующего ключа, сохраняя его ID и права.
+// Старый ключ перестает работать сразу.
+func (s *Storage) RotateAPIKey(ctx context.Context, key *models.APIKey) error {
+	query := sq.Update("api_keys").
+		Set("prefix", key.Prefix).
+		Set("key_hash", key.Hash).
+		Set("last_used_at", nil).
+		Where(sq.Eq{"id": key.ID, "revoked_at": nil}).
+		Where(tenantEq(ctx, "tenant_id")).
+		Suffix("RETURNING tenant_id, name, scopes, created_at").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	var row struct {
+		TenantID  string         `db:"tenant_id"`
+		Name      string         `db:"name"`
+		Scopes    pq.StringArray `db:"scopes"`
+		CreatedAt time.Time      `db:"created_at"`
+	}
+	if err := s.db.GetContext(ctx, &row, sqlStr, args...); err != nil {
+		return mapError(err)
+	}
+	key.TenantID, key.Name, key.Scopes, key.CreatedAt, key.LastUsedAt = row.TenantID, row.Name, row.Scopes, row.CreatedAt, nil
+	return nil
+}
+
+// TouchAPIKey отмечает использование ключа не чаще раза в apiKeyTouchInterval
+func (s *Storage) TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error {
+	query := sq.Update("api_keys").
+		Set("last_used_at", at).
+		Where(sq.Eq{"id": id}).
+		Where(sq.Or{
+			sq.Eq{"last_used_at": nil},
+			sq.Lt{"last_used_at": at.Add(-apiKeyTouchInterval)},
+		}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Виды ошибок хранилища. Обработчики API сопоставляют их с HTTP статусами
+// через errors.Is, не завися от ошибок драйвера базы данных.
+var (
+	// ErrNotFound возвращается, если запись не найдена
+	ErrNotFound = errors.New("not found")
+	// ErrConflict возвращается, если операция противоречит текущему состоянию данных
+	ErrConflict = errors.New("conflict")
+	// ErrInvalidInput возвращается, если данные не прошли проверку хранилища
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrVersionMismatch возвращается, если запись изменилась после того, как клиент ее прочитал
+	ErrVersionMismatch = errors.New("version mismatch")
+)
+
+// kindError — конкретная ошибка хранилища, относящаяся к одному из видов выше
+type kindError struct {
+	kind error
+	msg  string
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
+
+// newError создает ошибку вида kind с собственным сообщением
+func newError(kind error, msg string) error {
+	return &kindError{kind: kind, msg: msg}
+}
+
+// Коды ошибок PostgreSQL, которые переводятся в ошибки хранилища
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+	pqCheckViolation      = "23514"
+)
+
+// mapError переводит ошибки драйвера в ошибки хранилища.
+// Остальные ошибки возвращаются без изменений.
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case pqUniqueViolation:
+			return newError(ErrConflict, "duplicate value violates "+pqErr.Constraint)
+		case pqForeignKeyViolation:
+			return newError(ErrNotFound, "referenced record does not exist")
+		case pqCheckViolation:
+			return newError(ErrInvalidInput, "value violates "+pqErr.Constraint)
+		}
+	}
+	return err
+}
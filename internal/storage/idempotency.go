@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/tenant"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// idempotencyKeyEq выбирает запись ключа клиента в организации из контекста
+func idempotencyKeyEq(ctx context.Context, rec *models.IdempotencyRecord) sq.Eq {
+	return sq.Eq{"tenant_id": tenant.FromContext(ctx), "client": rec.Client, "key": rec.Key}
+}
+
+// ReserveIdempotencyKey закрепляет ключ за первым запросом. Если ключ свободен, истек
+// или первый запрос не завершился за rec.Lease, возвращает nil.
+// Иначе возвращает запись первого запроса, чтобы повторить его ответ.
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	rec.TenantID = tenant.FromContext(ctx)
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Истекший ключ и ключ прерванного запроса можно использовать заново
+	reclaimable := sq.Or{sq.LtOrEq{"expires_at": rec.CreatedAt}}
+	if rec.Lease > 0 {
+		reclaimable = append(reclaimable, sq.And{
+			sq.Eq{"status_code": 0},
+			sq.LtOrEq{"created_at": rec.CreatedAt.Add(-rec.Lease)},
+		})
+	}
+	deleteQuery := sq.Delete("idempotency_keys").
+		Where(idempotencyKeyEq(ctx, rec)).
+		Where(reclaimable).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := deleteQuery.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return nil, err
+	}
+
+	insert := sq.Insert("idempotency_keys").
+		Columns("tenant_id", "client", "key", "request_hash", "created_at", "expires_at").
+		Values(rec.TenantID, rec.Client, rec.Key, rec.RequestHash, rec.CreatedAt, rec.ExpiresAt).
+		Suffix("ON CONFLICT DO NOTHING").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err = insert.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	res, err := tx.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 1 {
+		return nil, tx.Commit()
+	}
+
+	query := sq.Select("*").
+		From("idempotency_keys").
+		Where(idempotencyKeyEq(ctx, rec)).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err = query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.IdempotencyRecord
+	if err := tx.GetContext(ctx, &existing, sqlStr, args...); err != nil {
+		return nil, mapError(err)
+	}
+	return &existing, tx.Commit()
+}
+
+// reservationEq выбирает запись, зарезервированную именно этим запросом. Если lease истек
+// и ключ занял повтор, запись уже чужая: ее нельзя ни заполнить, ни удалить
+func reservationEq(ctx context.Context, rec *models.IdempotencyRecord) sq.Eq {
+	eq := idempotencyKeyEq(ctx, rec)
+	eq["created_at"] = rec.CreatedAt
+	eq["request_hash"] = rec.RequestHash
+	eq["status_code"] = 0
+	return eq
+}
+
+// CompleteIdempotencyKey сохраняет ответ на первый запрос для повторов
+func (s *Storage) CompleteIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) error {
+	query := sq.Update("idempotency_keys").
+		Set("status_code", rec.StatusCode).
+		Set("content_type", rec.ContentType).
+		Set("etag", rec.ETag).
+		Set("response_body", rec.ResponseBody).
+		Where(reservationEq(ctx, rec)).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// ReleaseIdempotencyKey освобождает ключ незавершенного запроса, чтобы клиент мог повторить его
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) error {
+	query := sq.Delete("idempotency_keys").
+		Where(reservationEq(ctx, rec)).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys удаляет ключи с истекшим окном повтора
+func (s *Storage) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) error {
+	query := sq.Delete("idempotency_keys").
+		Where(sq.LtOrEq{"expires_at": now}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
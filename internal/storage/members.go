@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"math"
+
+	"subscribe_aggregation-main/internal/models"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrInvalidSplit возвращается при некорректном составе участников или долях
+var ErrInvalidSplit = newError(ErrInvalidInput, "invalid subscription split")
+
+// shareTolerance — допустимое отклонение суммы долей от единицы
+const shareTolerance = 1e-4
+
+// NormalizeMembers проверяет участников совместной подписки и вычисляет доли.
+// В режиме equal владелец добавляется автоматически, а стоимость делится поровну.
+// В режиме custom доли задаются явно и в сумме должны давать единицу.
+// Подписка без участников остается личной: ее полностью оплачивает владелец.
+func NormalizeMembers(sub *models.Subscription) error {
+	if len(sub.Members) == 0 {
+		if sub.SplitMode == models.SplitCustom {
+			return ErrInvalidSplit
+		}
+		sub.SplitMode = models.SplitNone
+		return nil
+	}
+	if sub.SplitMode == models.SplitNone {
+		sub.SplitMode = models.SplitEqual
+	}
+
+	seen := make(map[uuid.UUID]struct{}, len(sub.Members)+1)
+	for _, member := range sub.Members {
+		if member.UserID == uuid.Nil {
+			return ErrInvalidSplit
+		}
+		if _, ok := seen[member.UserID]; ok {
+			return ErrInvalidSplit
+		}
+		seen[member.UserID] = struct{}{}
+	}
+
+	switch sub.SplitMode {
+	case models.SplitEqual:
+		if _, ok := seen[sub.UserID]; !ok {
+			sub.Members = append([]models.SubscriptionMember{{UserID: sub.UserID}}, sub.Members...)
+		}
+		share := 1 / float64(len(sub.Members))
+		for i := range sub.Members {
+			sub.Members[i].Share = share
+		}
+	case models.SplitCustom:
+		total := 0.0
+		for _, member := range sub.Members {
+			if member.Share <= 0 || member.Share > 1 {
+				return ErrInvalidSplit
+			}
+			total += member.Share
+		}
+		if math.Abs(total-1) > shareTolerance {
+			return ErrInvalidSplit
+		}
+	default:
+		return ErrInvalidSplit
+	}
+	return nil
+}
+
+// setSubscriptionMembers заменяет состав участников подписки
+func setSubscriptionMembers(ctx context.Context, tx *sqlx.Tx, subscriptionID uuid.UUID, members []models.SubscriptionMember) error {
+	deleteQuery := sq.Delete("subscription_members").
+		Where(sq.Eq{"subscription_id": subscriptionID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := deleteQuery.ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return err
+	}
+
+	if len(members) == 0 {
+		return nil
+	}
+
+	insert := sq.Insert("subscription_members").
+		Columns("subscription_id", "user_id", "share").
+		PlaceholderFormat(sq.Dollar)
+	for _, member := range members {
+		insert = insert.Values(subscriptionID, member.UserID, member.Share)
+	}
+
+	sqlStr, args, err = insert.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// loadMembers заполняет поле Members у переданных подписок
+func (s *Storage) loadMembers(ctx context.Context, subs []models.Subscription) error {
+	if len(subs) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(subs))
+	for _, sub := range subs {
+		ids = append(ids, sub.ID)
+	}
+
+	query := sq.Select("subscription_id", "user_id", "share").
+		From("subscription_members").
+		Where(sq.Eq{"subscription_id": ids}).
+		OrderBy("subscription_id", "user_id").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	var rows []struct {
+		SubscriptionID uuid.UUID `db:"subscription_id"`
+		models.SubscriptionMember
+	}
+	if err := s.db.SelectContext(ctx, &rows, sqlStr, args...); err != nil {
+		return err
+	}
+
+	membersByID := make(map[uuid.UUID][]models.SubscriptionMember)
+	for _, row := range rows {
+		membersByID[row.SubscriptionID] = append(membersByID[row.SubscriptionID], row.SubscriptionMember)
+	}
+	for i := range subs {
+		subs[i].Members = membersByID[subs[i].ID]
+	}
+	return nil
+}
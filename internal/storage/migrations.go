@@ -0,0 +1,12 @@
+package storage
+
+import "embed"
+
+// Migrations — SQL миграции схемы, встроенные в бинарник,
+// чтобы их можно было применять из любого рабочего каталога
+//
+//go:embed .sql/*.sql
+var Migrations embed.FS
+
+// MigrationsDir — каталог миграций внутри Migrations и относительно internal/storage
+const MigrationsDir = ".sql"
@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"subscribe_aggregation-main/internal/models"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// ErrAlreadyPaused возвращается при попытке приостановить уже приостановленную подписку
+	ErrAlreadyPaused = newError(ErrConflict, "subscription is already paused")
+	// ErrNotPaused возвращается при попытке возобновить активную подписку
+	ErrNotPaused = newError(ErrConflict, "subscription is not paused")
+	// ErrInvalidPauseDate возвращается, если дата паузы выходит за период подписки
+	// или пересекается с предыдущими паузами
+	ErrInvalidPauseDate = newError(ErrInvalidInput, "invalid pause date")
+)
+
+// PausePeriod — интервал приостановки подписки.
+// StartDate — первый день паузы, EndDate — день возобновления (nil, пока пауза не закончена).
+type PausePeriod struct {
+	StartDate time.Time  `db:"start_date"`
+	EndDate   *time.Time `db:"end_date"`
+}
+
+// splitByPauses разбивает период подписки на активные отрезки, исключая паузы.
+// Месяцы, целиком попавшие в паузу, не оплачиваются; месяц начала паузы
+// и месяц возобновления оплачиваются как частичные.
+func splitByPauses(sub SubscriptionPeriod) []SubscriptionPeriod {
+	pauses := sub.Pauses
+	sub.Pauses = nil
+	segments := []SubscriptionPeriod{sub}
+
+	sort.Slice(pauses, func(i, j int) bool {
+		return pauses[i].StartDate.Before(pauses[j].StartDate)
+	})
+
+	for _, pause := range pauses {
+		var next []SubscriptionPeriod
+		for _, seg := range segments {
+			// Отрезок до начала паузы
+			if seg.StartDate.Before(pause.StartDate) {
+				end := pause.StartDate.AddDate(0, 0, -1)
+				before := seg
+				before.EndDate = minTimePtr(seg.EndDate, &end)
+				next = append(next, before)
+			}
+			// Отрезок после возобновления
+			if pause.EndDate != nil && (seg.EndDate == nil || !seg.EndDate.Before(*pause.EndDate)) {
+				after := seg
+				after.StartDate = maxTime(seg.StartDate, *pause.EndDate)
+				next = append(next, after)
+			}
+		}
+		segments = next
+	}
+	return segments
+}
+
+// pausesBySubscription возвращает паузы для каждой из переданных подписок
+func (s *Storage) pausesBySubscription(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]models.Pause, error) {
+	result := make(map[uuid.UUID][]models.Pause)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	query := sq.Select("*").
+		From("subscription_pauses").
+		Where(sq.Eq{"subscription_id": ids}).
+		OrderBy("start_date").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var pauses []models.Pause
+	if err := s.db.SelectContext(ctx, &pauses, sqlStr, args...); err != nil {
+		return nil, err
+	}
+
+	for _, pause := range pauses {
+		result[pause.SubscriptionID] = append(result[pause.SubscriptionID], pause)
+	}
+	return result, nil
+}
+
+// subscriptionPauses возвращает паузы подписки в транзакции tx
+func subscriptionPauses(ctx context.Context, tx *sqlx.Tx, subscriptionID uuid.UUID) ([]models.Pause, error) {
+	query := sq.Select("*").
+		From("subscription_pauses").
+		Where(sq.Eq{"subscription_id": subscriptionID}).
+		OrderBy("start_date").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var pauses []models.Pause
+	if err := tx.SelectContext(ctx, &pauses, sqlStr, args...); err != nil {
+		return nil, err
+	}
+	return pauses, nil
+}
+
+// attachPauses добавляет паузы к периодам подписок для расчета стоимости
+func (s *Storage) attachPauses(ctx context.Context, subs []SubscriptionPeriod) error {
+	ids := make([]uuid.UUID, 0, len(subs))
+	for _, sub := range subs {
+		ids = append(ids, sub.ID)
+	}
+
+	pausesByID, err := s.pausesBySubscription(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range subs {
+		for _, pause := range pausesByID[subs[i].ID] {
+			period := PausePeriod{StartDate: pause.StartDate.ToTime()}
+			if pause.EndDate != nil {
+				end := pause.EndDate.ToTime()
+				period.EndDate = &end
+			}
+			subs[i].Pauses = append(subs[i].Pauses, period)
+		}
+	}
+	return nil
+}
+
+// loadPauses заполняет поле Pauses у переданных подписок
+func (s *Storage) loadPauses(ctx context.Context, subs []models.Subscription) error {
+	ids := make([]uuid.UUID, 0, len(subs))
+	for _, sub := range subs {
+		ids = append(ids, sub.ID)
+	}
+
+	pausesByID, err := s.pausesBySubscription(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range subs {
+		subs[i].Pauses = pausesByID[subs[i].ID]
+	}
+	return nil
+}
+
+// PauseSubscription приостанавливает подписку начиная с указанной даты.
+// Возвращает паузу и новую версию подписки
+func (s *Storage) PauseSubscription(ctx context.Context, id uuid.UUID, date time.Time) (*models.Pause, int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	subQuery := sq.Select("start_date", "end_date").
+		From("subscriptions").
+		Where(sq.Eq{"id": id}).
+		Where(tenantEq(ctx, "tenant_id")).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := subQuery.ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var period PausePeriod
+	if err := tx.GetContext(ctx, &period, sqlStr, args...); err != nil {
+		return nil, 0, mapError(err)
+	}
+	if date.Before(period.StartDate) || (period.EndDate != nil && date.After(*period.EndDate)) {
+		return nil, 0, ErrInvalidPauseDate
+	}
+
+	// Новая пауза не может начинаться раньше окончания последней
+	lastQuery := sq.Select("start_date", "end_date").
+		From("subscription_pauses").
+		Where(sq.Eq{"subscription_id": id}).
+		OrderBy("start_date DESC").
+		Limit(1).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err = lastQuery.ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var last PausePeriod
+	err = tx.GetContext(ctx, &last, sqlStr, args...)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return nil, 0, err
+	case last.EndDate == nil:
+		return nil, 0, ErrAlreadyPaused
+	case date.Before(*last.EndDate):
+		return nil, 0, ErrInvalidPauseDate
+	}
+
+	pause := &models.Pause{
+		ID:             uuid.New(),
+		SubscriptionID: id,
+		StartDate:      models.DataOnly(date),
+	}
+
+	insert := sq.Insert("subscription_pauses").
+		Columns("id", "subscription_id", "start_date").
+		Values(pause.ID, pause.SubscriptionID, date).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err = insert.ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return nil, 0, err
+	}
+	version, err := bumpSubscriptionVersion(ctx, tx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return pause, version, tx.Commit()
+}
+
+// ResumeSubscription завершает текущую паузу подписки указанной датой.
+// Возвращает паузу и новую версию подписки
+func (s *Storage) ResumeSubscription(ctx context.Context, id uuid.UUID, date time.Time) (*models.Pause, int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	existsQuery := sq.Select("1").
+		From("subscriptions").
+		Where(sq.Eq{"id": id}).
+		Where(tenantEq(ctx, "tenant_id")).
+		Prefix("SELECT EXISTS (").
+		Suffix(")").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := existsQuery.ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var exists bool
+	if err := tx.GetContext(ctx, &exists, sqlStr, args...); err != nil {
+		return nil, 0, err
+	}
+	if !exists {
+		return nil, 0, ErrNotFound
+	}
+
+	openQuery := sq.Select("*").
+		From("subscription_pauses").
+		Where(sq.Eq{"subscription_id": id, "end_date": nil}).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err = openQuery.ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var pause models.Pause
+	err = tx.GetContext(ctx, &pause, sqlStr, args...)
+	if err == sql.ErrNoRows {
+		return nil, 0, ErrNotPaused
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if date.Before(pause.StartDate.ToTime()) {
+		return nil, 0, ErrInvalidPauseDate
+	}
+
+	update := sq.Update("subscription_pauses").
+		Set("end_date", date).
+		Where(sq.Eq{"id": pause.ID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err = update.ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return nil, 0, err
+	}
+	version, err := bumpSubscriptionVersion(ctx, tx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	end := models.DataOnly(date)
+	pause.EndDate = &end
+	return &pause, version, tx.Commit()
+}
@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"subscribe_aggregation-main/internal/models"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrInvalidPhases возвращается при некорректной длительности или цене пробных периодов
+var ErrInvalidPhases = newError(ErrInvalidInput, "invalid trial phases")
+
+// PricePhase — пробный или вводный период со своей ценой в месяц
+type PricePhase struct {
+	Months int
+	Price  int64
+}
+
+// ValidatePhases проверяет пробные периоды подписки
+func ValidatePhases(phases []models.PricePhase) error {
+	for _, phase := range phases {
+		if phase.Months <= 0 || phase.Price < 0 {
+			return ErrInvalidPhases
+		}
+	}
+	return nil
+}
+
+// applyShare возвращает долю участника от цены, округленную до целого
+func applyShare(price int64, share float64) int64 {
+	return int64(math.Round(float64(price) * share))
+}
+
+// splitByPhases разбивает период подписки на пробные отрезки со своей ценой
+// и отрезок с полной ценой после окончания всех пробных периодов
+func splitByPhases(sub SubscriptionPeriod) []SubscriptionPeriod {
+	phases := sub.Phases
+	sub.Phases = nil
+	if len(phases) == 0 {
+		return []SubscriptionPeriod{sub}
+	}
+
+	var segments []SubscriptionPeriod
+	start := sub.StartDate
+	monthsFromStart := 0
+	for _, phase := range phases {
+		if sub.EndDate != nil && start.After(*sub.EndDate) {
+			return segments
+		}
+		monthsFromStart += phase.Months
+		next := addMonths(sub.StartDate, monthsFromStart)
+		end := next.AddDate(0, 0, -1)
+
+		segment := sub
+		segment.Price = phase.Price
+		segment.StartDate = start
+		segment.EndDate = minTimePtr(sub.EndDate, &end)
+		segments = append(segments, segment)
+
+		start = next
+	}
+
+	if sub.EndDate == nil || !start.After(*sub.EndDate) {
+		rest := sub
+		rest.StartDate = start
+		segments = append(segments, rest)
+	}
+	return segments
+}
+
+// priceOn возвращает месячную цену действующего на дату date периода:
+// цену пробного периода, в который попадает дата, или полную цену после них
+func priceOn(sub SubscriptionPeriod, date time.Time) int64 {
+	price := sub.Price
+	for _, segment := range splitByPhases(sub) {
+		if !segment.StartDate.After(date) {
+			price = segment.Price
+		}
+	}
+	return price
+}
+
+// TrialEndDate возвращает дату первого списания полной цены
+// или nil, если у подписки нет пробных периодов
+func TrialEndDate(start time.Time, phases []models.PricePhase) *time.Time {
+	if len(phases) == 0 {
+		return nil
+	}
+	months := 0
+	for _, phase := range phases {
+		months += phase.Months
+	}
+	end := addMonths(start, months)
+	return &end
+}
+
+// addMonths прибавляет месяцы так же, как date + interval в PostgreSQL: если в месяце
+// результата нет такого дня, берется его последний день (31 января + 1 месяц = 29 февраля).
+// time.AddDate перенес бы лишние дни на следующий месяц.
+func addMonths(t time.Time, months int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()).AddDate(0, months, 0)
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	return time.Date(firstOfMonth.Year(), firstOfMonth.Month(), min(t.Day(), lastDay),
+		t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// setSubscriptionPhases заменяет пробные периоды подписки
+func setSubscriptionPhases(ctx context.Context, tx *sqlx.Tx, subscriptionID uuid.UUID, phases []models.PricePhase) error {
+	deleteQuery := sq.Delete("subscription_phases").
+		Where(sq.Eq{"subscription_id": subscriptionID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := deleteQuery.ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return err
+	}
+
+	if len(phases) == 0 {
+		return nil
+	}
+
+	insert := sq.Insert("subscription_phases").
+		Columns("subscription_id", "position", "months", "price").
+		PlaceholderFormat(sq.Dollar)
+	for i, phase := range phases {
+		insert = insert.Values(subscriptionID, i, phase.Months, phase.Price)
+	}
+
+	sqlStr, args, err = insert.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// phasesBySubscription возвращает пробные периоды для каждой из переданных подписок
+func (s *Storage) phasesBySubscription(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]models.PricePhase, error) {
+	result := make(map[uuid.UUID][]models.PricePhase)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	query := sq.Select("subscription_id", "months", "price").
+		From("subscription_phases").
+		Where(sq.Eq{"subscription_id": ids}).
+		OrderBy("subscription_id", "position").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		SubscriptionID uuid.UUID `db:"subscription_id"`
+		models.PricePhase
+	}
+	if err := s.db.SelectContext(ctx, &rows, sqlStr, args...); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		result[row.SubscriptionID] = append(result[row.SubscriptionID], row.PricePhase)
+	}
+	return result, nil
+}
+
+// attachPhases добавляет пробные периоды к периодам подписок для расчета стоимости.
+// Цены пробных периодов, как и основная цена, пересчитываются по доле участника.
+func (s *Storage) attachPhases(ctx context.Context, subs []SubscriptionPeriod) error {
+	ids := make([]uuid.UUID, 0, len(subs))
+	for _, sub := range subs {
+		ids = append(ids, sub.ID)
+	}
+
+	phasesByID, err := s.phasesBySubscription(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range subs {
+		for _, phase := range phasesByID[subs[i].ID] {
+			subs[i].Phases = append(subs[i].Phases, PricePhase{
+				Months: phase.Months,
+				Price:  applyShare(int64(phase.Price), subs[i].Share),
+			})
+		}
+	}
+	return nil
+}
+
+// loadPhases заполняет пробные периоды и дату их окончания у переданных подписок
+func (s *Storage) loadPhases(ctx context.Context, subs []models.Subscription) error {
+	ids := make([]uuid.UUID, 0, len(subs))
+	for _, sub := range subs {
+		ids = append(ids, sub.ID)
+	}
+
+	phasesByID, err := s.phasesBySubscription(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range subs {
+		subs[i].Phases = phasesByID[subs[i].ID]
+		if end := TrialEndDate(subs[i].StartDate.ToTime(), subs[i].Phases); end != nil {
+			trialEnd := models.DataOnly(*end)
+			subs[i].TrialEndDate = &trialEnd
+		}
+	}
+	return nil
+}
+
+// ListTrialsEndingSoon возвращает подписки, у которых первое списание
+// полной цены приходится на интервал [from, to]
+func (s *Storage) ListTrialsEndingSoon(ctx context.Context, userID string, from, to time.Time) ([]models.Subscription, error) {
+	// Совпадает с TrialEndDate: конец месяца ограничивается последним днем, см. addMonths
+	trialEnd := "(s.start_date + make_interval(months => p.months))::DATE"
+
+	query := sq.Select("s.*").
+		From("subscriptions s").
+		Join("(SELECT subscription_id, SUM(months)::INT AS months FROM subscription_phases GROUP BY subscription_id) p ON p.subscription_id = s.id").
+		Where(tenantEq(ctx, "s.tenant_id")).
+		Where(sq.Expr(trialEnd+" BETWEEN ? AND ?", from, to)).
+		Where(sq.Or{
+			sq.Eq{"s.end_date": nil},
+			sq.Expr("s.end_date >= " + trialEnd),
+		}).
+		OrderBy(trialEnd).
+		PlaceholderFormat(sq.Dollar)
+
+	if userID != "" {
+		query = query.Where(sq.Or{
+			sq.Eq{"s.user_id": userID},
+			sq.Expr("s.id IN (SELECT subscription_id FROM subscription_members WHERE user_id = ?)", userID),
+		})
+	}
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	subs := []models.Subscription{}
+	if err := s.db.SelectContext(ctx, &subs, sqlStr, args...); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadRelations(ctx, subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"subscribe_aggregation-main/internal/ratelimit"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// RateLimitStore хранит квоты в PostgreSQL, чтобы несколько экземпляров сервиса
+// делили одну квоту клиента. Таблица не журналируется: после сбоя квоты просто обнуляются.
+type RateLimitStore struct {
+	db *sqlx.DB
+}
+
+// NewRateLimitStore создает хранилище квот в базе
+func NewRateLimitStore(db *sqlx.DB) *RateLimitStore {
+	return &RateLimitStore{db: db}
+}
+
+// Take списывает токен из квоты key. Строка блокируется на время пересчета,
+// поэтому одновременные запросы одного клиента не тратят один и тот же токен.
+func (s *RateLimitStore) Take(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	defer tx.Rollback()
+
+	bucket := ratelimit.NewBucket(limit, now)
+
+	insert := sq.Insert("rate_limits").
+		Columns("key", "tokens", "updated_at").
+		Values(key, bucket.Tokens, bucket.Updated).
+		Suffix("ON CONFLICT (key) DO NOTHING").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := insert.ToSql()
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	query := sq.Select("tokens", "updated_at").
+		From("rate_limits").
+		Where(sq.Eq{"key": key}).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err = query.ToSql()
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	if err := tx.QueryRowxContext(ctx, sqlStr, args...).Scan(&bucket.Tokens, &bucket.Updated); err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	result := bucket.Take(limit, now)
+
+	update := sq.Update("rate_limits").
+		Set("tokens", bucket.Tokens).
+		Set("updated_at", bucket.Updated).
+		Where(sq.Eq{"key": key}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err = update.ToSql()
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	return result, tx.Commit()
+}
+
+// DeleteIdleRateLimits удаляет квоты, которые не использовались дольше maxIdle
+func (s *RateLimitStore) DeleteIdleRateLimits(ctx context.Context, maxIdle time.Duration) error {
+	query := sq.Delete("rate_limits").
+		Where(sq.Lt{"updated_at": time.Now().Add(-maxIdle)}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, sqlStr, args...)
+	return err
+}
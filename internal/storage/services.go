@@ -0,0 +1,326 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/tenant"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrServiceAliasTaken возвращается, если алиас уже принадлежит другому сервису каталога
+var ErrServiceAliasTaken = newError(ErrConflict, "service alias already belongs to another service")
+
+// NormalizeServiceName убирает пробелы по краям и схлопывает повторяющиеся пробелы
+func NormalizeServiceName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// ServiceKey возвращает ключ для сравнения названий без учета регистра и пробелов.
+// В таком виде хранятся алиасы в каталоге.
+func ServiceKey(name string) string {
+	return strings.ToLower(NormalizeServiceName(name))
+}
+
+// normalizeAliases приводит алиасы к ключам, добавляет ключ канонического названия
+// и убирает пустые значения и дубликаты
+func normalizeAliases(name string, aliases []string) pq.StringArray {
+	seen := make(map[string]struct{}, len(aliases)+1)
+	result := pq.StringArray{}
+	for _, alias := range append([]string{name}, aliases...) {
+		key := ServiceKey(alias)
+		if key == "" {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		result = append(result, key)
+	}
+	return result
+}
+
+// lookupServiceName ищет каноническое название сервиса по названию или алиасу.
+// Если сервис не найден в каталоге, возвращает нормализованное название и false.
+func (s *Storage) lookupServiceName(ctx context.Context, name string) (string, bool, error) {
+	normalized := NormalizeServiceName(name)
+	if normalized == "" {
+		return "", false, nil
+	}
+
+	query := sq.Select("name").
+		From("services").
+		Where("? = ANY(aliases)", ServiceKey(normalized)).
+		Where(tenantEq(ctx, "tenant_id")).
+		Limit(1).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return "", false, err
+	}
+
+	var canonical string
+	err = s.db.GetContext(ctx, &canonical, sqlStr, args...)
+	if err == sql.ErrNoRows {
+		return normalized, false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return canonical, true, nil
+}
+
+// canonicalServiceName возвращает каноническое название сервиса.
+// Неизвестные сервисы регистрируются в каталоге, чтобы последующие
+// написания с другим регистром или пробелами сводились к ним же.
+func (s *Storage) canonicalServiceName(ctx context.Context, name string) (string, error) {
+	canonical, found, err := s.lookupServiceName(ctx, name)
+	if err != nil || found || canonical == "" {
+		return canonical, err
+	}
+
+	query := sq.Insert("services").
+		Columns("id", "tenant_id", "name", "aliases").
+		Values(uuid.New(), tenant.FromContext(ctx), canonical, normalizeAliases(canonical, nil)).
+		Suffix("ON CONFLICT DO NOTHING").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := s.db.ExecContext(ctx, sqlStr, args...); err != nil {
+		return "", err
+	}
+	return canonical, nil
+}
+
+// checkAliases проверяет, что алиасы не заняты другим сервисом каталога
+func (s *Storage) checkAliases(ctx context.Context, id uuid.UUID, aliases pq.StringArray) error {
+	query := sq.Select("name").
+		From("services").
+		Where("aliases && ?", aliases).
+		Where(sq.NotEq{"id": id}).
+		Where(tenantEq(ctx, "tenant_id")).
+		Limit(1).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	var owner string
+	err = s.db.GetContext(ctx, &owner, sqlStr, args...)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return ErrServiceAliasTaken
+}
+
+// renameSubscriptions переводит подписки, совпадающие с алиасами сервиса, на каноническое название.
+// Версия увеличивается, как в bumpSubscriptionVersion, чтобы старые ETag переименованных подписок не совпадали.
+func renameSubscriptions(ctx context.Context, tx *sqlx.Tx, svc *models.Service, oldName string) error {
+	query := sq.Update("subscriptions").
+		Set("service_name", svc.Name).
+		Set("version", sq.Expr("version + 1")).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(tenantEq(ctx, "tenant_id")).
+		Where(sq.And{
+			sq.NotEq{"service_name": svc.Name},
+			sq.Or{
+				sq.Expr("lower(service_name) = ANY(?)", svc.Aliases),
+				sq.Eq{"service_name": oldName},
+			},
+		}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+func (s *Storage) CreateService(ctx context.Context, svc *models.Service) error {
+	if svc.ID == uuid.Nil {
+		svc.ID = uuid.New()
+	}
+	svc.Name = NormalizeServiceName(svc.Name)
+	svc.Aliases = normalizeAliases(svc.Name, svc.Aliases)
+	// Категория сервиса используется как тег подписок, поэтому нормализуется так же
+	svc.Category = ServiceKey(svc.Category)
+
+	if err := s.checkAliases(ctx, svc.ID, svc.Aliases); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := sq.Insert("services").
+		Columns("id", "tenant_id", "name", "aliases", "category", "default_price", "homepage", "created_at", "updated_at").
+		Values(svc.ID, tenant.FromContext(ctx), svc.Name, svc.Aliases, svc.Category, svc.DefaultPrice, svc.Homepage, sq.Expr("NOW()"), sq.Expr("NOW()")).
+		Suffix("RETURNING created_at, updated_at").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	if err := tx.QueryRowxContext(ctx, sqlStr, args...).Scan(&svc.CreatedAt, &svc.UpdatedAt); err != nil {
+		return mapError(err)
+	}
+
+	if err := renameSubscriptions(ctx, tx, svc, svc.Name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Storage) GetServiceByID(ctx context.Context, id uuid.UUID) (*models.Service, error) {
+	var svc models.Service
+
+	query := sq.Select("*").
+		From("services").
+		Where(sq.Eq{"id": id}).
+		Where(tenantEq(ctx, "tenant_id")).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.GetContext(ctx, &svc, sqlStr, args...); err != nil {
+		return nil, mapError(err)
+	}
+	return &svc, nil
+}
+
+func (s *Storage) ListServices(ctx context.Context, category string) ([]models.Service, error) {
+	query := sq.Select("*").
+		From("services").
+		Where(tenantEq(ctx, "tenant_id")).
+		OrderBy("name").
+		PlaceholderFormat(sq.Dollar)
+	if category != "" {
+		query = query.Where(sq.Eq{"category": ServiceKey(category)})
+	}
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	services := []models.Service{}
+	err = s.db.SelectContext(ctx, &services, sqlStr, args...)
+	return services, err
+}
+
+func (s *Storage) UpdateService(ctx context.Context, svc *models.Service) error {
+	svc.Name = NormalizeServiceName(svc.Name)
+	svc.Aliases = normalizeAliases(svc.Name, svc.Aliases)
+	// Категория сервиса используется как тег подписок, поэтому нормализуется так же
+	svc.Category = ServiceKey(svc.Category)
+
+	if err := s.checkAliases(ctx, svc.ID, svc.Aliases); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	selectQuery := sq.Select("name").
+		From("services").
+		Where(sq.Eq{"id": svc.ID}).
+		Where(tenantEq(ctx, "tenant_id")).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar)
+
+	selectSQL, selectArgs, err := selectQuery.ToSql()
+	if err != nil {
+		return err
+	}
+
+	var oldName string
+	if err := tx.GetContext(ctx, &oldName, selectSQL, selectArgs...); err != nil {
+		return mapError(err)
+	}
+
+	query := sq.Update("services").
+		Set("name", svc.Name).
+		Set("aliases", svc.Aliases).
+		Set("category", svc.Category).
+		Set("default_price", svc.DefaultPrice).
+		Set("homepage", svc.Homepage).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": svc.ID}).
+		Where(tenantEq(ctx, "tenant_id")).
+		Suffix("RETURNING created_at, updated_at").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	if err := tx.QueryRowxContext(ctx, sqlStr, args...).Scan(&svc.CreatedAt, &svc.UpdatedAt); err != nil {
+		return mapError(err)
+	}
+
+	if err := renameSubscriptions(ctx, tx, svc, oldName); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Storage) DeleteService(ctx context.Context, id uuid.UUID) error {
+	query := sq.Delete("services").
+		Where(sq.Eq{"id": id}).
+		Where(tenantEq(ctx, "tenant_id")).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
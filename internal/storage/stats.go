@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"subscribe_aggregation-main/internal/tenant"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+)
+
+// SubscriptionStats — показатели активных подписок одной организации
+type SubscriptionStats struct {
+	TenantID string
+	// Active — подписки, действующие на дату и не приостановленные
+	Active int64
+	// MonthlyTotal — сумма месячных цен активных подписок на дату: в пробный период
+	// берется его цена, у совместных подписок складываются доли участников
+	MonthlyTotal int64
+}
+
+// SubscriptionStats считает активные на дату date подписки и их месячную стоимость
+// по организациям. Список организаций читается без ограничения организацией,
+// а показатели каждой считаются через tenantDB, как и остальные запросы хранилища.
+func (s *Storage) SubscriptionStats(ctx context.Context, date time.Time) ([]SubscriptionStats, error) {
+	query := sq.Select("DISTINCT tenant_id").
+		From("subscriptions").
+		OrderBy("tenant_id")
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	// Без app.tenant_id политики row-level security видят все организации
+	var tenants []string
+	if err := s.db.DB.SelectContext(ctx, &tenants, sqlStr, args...); err != nil {
+		return nil, err
+	}
+
+	stats := make([]SubscriptionStats, 0, len(tenants))
+	for _, id := range tenants {
+		tenantStats, err := s.tenantStats(tenant.NewContext(ctx, id), date)
+		if err != nil {
+			return nil, err
+		}
+		tenantStats.TenantID = id
+		stats = append(stats, tenantStats)
+	}
+	return stats, nil
+}
+
+// tenantStats считает показатели организации из контекста
+func (s *Storage) tenantStats(ctx context.Context, date time.Time) (SubscriptionStats, error) {
+	// Пауза действует с первого дня до дня возобновления, не включая его
+	paused := sq.Select("1").
+		From("subscription_pauses p").
+		Where("p.subscription_id = s.id").
+		Where(sq.LtOrEq{"p.start_date": date}).
+		Where(sq.Or{sq.Eq{"p.end_date": nil}, sq.Gt{"p.end_date": date}})
+	pausedSQL, pausedArgs, err := paused.ToSql()
+	if err != nil {
+		return SubscriptionStats{}, err
+	}
+
+	// Совместная подписка дает строку на каждого участника с его долей, как в selectPeriods
+	query := sq.Select("s.id", "COALESCE(m.user_id, s.user_id) AS user_id", "s.service_name", "s.price",
+		"s.start_date", "s.end_date", "COALESCE(m.share, 1)::FLOAT8 AS share").
+		From("subscriptions s").
+		LeftJoin("subscription_members m ON m.subscription_id = s.id").
+		Where(tenantEq(ctx, "s.tenant_id")).
+		Where(sq.LtOrEq{"s.start_date": date}).
+		Where(sq.Or{sq.Eq{"s.end_date": nil}, sq.GtOrEq{"s.end_date": date}}).
+		Where("NOT EXISTS ("+pausedSQL+")", pausedArgs...).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return SubscriptionStats{}, err
+	}
+
+	var subs []SubscriptionPeriod
+	if err := s.db.SelectContext(ctx, &subs, sqlStr, args...); err != nil {
+		return SubscriptionStats{}, err
+	}
+	for i := range subs {
+		subs[i].Price = applyShare(subs[i].Price, subs[i].Share)
+	}
+	if err := s.attachPhases(ctx, subs); err != nil {
+		return SubscriptionStats{}, err
+	}
+
+	var stats SubscriptionStats
+	active := make(map[uuid.UUID]struct{}, len(subs))
+	for _, sub := range subs {
+		active[sub.ID] = struct{}{}
+		stats.MonthlyTotal += priceOn(sub, date)
+	}
+	stats.Active = int64(len(active))
+	return stats, nil
+}
@@ -2,12 +2,14 @@ package storage
 
 import (
 	"context"
-	"database/sql"
-	"log"
+	"log/slog"
 	"sort"
+	"strconv"
 	"time"
 
 	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/tenant"
+	"subscribe_aggregation-main/pkg/logging"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
@@ -15,26 +17,94 @@ import (
 )
 
 type Storage struct {
-	db *sqlx.DB
+	db *tenantDB
 }
 
 type SubscriptionPeriod struct {
-	Price     int64      `db:"price"`
-	StartDate time.Time  `db:"start_date"`
-	EndDate   *time.Time `db:"end_date"`
+	ID          uuid.UUID  `db:"id"`
+	UserID      uuid.UUID  `db:"user_id"`
+	ServiceName string     `db:"service_name"`
+	Price       int64      `db:"price"`
+	StartDate   time.Time  `db:"start_date"`
+	EndDate     *time.Time `db:"end_date"`
+	// Share — доля пользователя в совместной подписке (1 для личных)
+	Share float64 `db:"share"`
+	// Phases — пробные периоды с собственной ценой от начала подписки
+	Phases []PricePhase `db:"-"`
+	// Pauses исключаются из периода в MergeIntervals
+	Pauses []PausePeriod `db:"-"`
+}
+
+// LogValue выводит период в логах атрибутами, чтобы RedactHandler мог скрыть user_id
+func (p SubscriptionPeriod) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("id", p.ID.String()),
+		slog.String("user_id", p.UserID.String()),
+		slog.String("service_name", p.ServiceName),
+		slog.Int64("price", p.Price),
+		slog.Time("start_date", p.StartDate),
+	}
+	if p.EndDate != nil {
+		attrs = append(attrs, slog.Time("end_date", *p.EndDate))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// ListFilter задает пагинацию и фильтры для списка подписок
+type ListFilter struct {
+	Page        int
+	Limit       int
+	UserID      string
+	ServiceName string
+	// Tags — подписка должна иметь все перечисленные теги
+	Tags []string
+	// From и To — подписка должна действовать хотя бы день в этом интервале.
+	// Нулевое значение снимает ограничение с соответствующей стороны.
+	From time.Time
+	To   time.Time
 }
 
 type StorageInterface interface {
 	CreateSubscription(ctx context.Context, sub *models.Subscription) error
 	GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error)
-	ListSubscriptions(ctx context.Context, page, limit int) ([]models.Subscription, error)
+	ListSubscriptions(ctx context.Context, filter ListFilter) ([]models.Subscription, error)
 	UpdateSubscription(ctx context.Context, sub *models.Subscription) error
-	DeleteSubscription(ctx context.Context, id uuid.UUID) error
+	DeleteSubscription(ctx context.Context, id uuid.UUID, version int) error
 	SumSubscriptionsCost(ctx context.Context, userID, serviceName string, filterStart, filterEnd time.Time) (int64, error)
+	SumSubscriptionsCostByCategory(ctx context.Context, userID, serviceName string, filterStart, filterEnd time.Time) (map[string]int64, error)
+
+	CreateService(ctx context.Context, svc *models.Service) error
+	GetServiceByID(ctx context.Context, id uuid.UUID) (*models.Service, error)
+	ListServices(ctx context.Context, category string) ([]models.Service, error)
+	UpdateService(ctx context.Context, svc *models.Service) error
+	DeleteService(ctx context.Context, id uuid.UUID) error
+
+	ListTags(ctx context.Context) ([]models.Tag, error)
+
+	PauseSubscription(ctx context.Context, id uuid.UUID, date time.Time) (*models.Pause, int, error)
+	ResumeSubscription(ctx context.Context, id uuid.UUID, date time.Time) (*models.Pause, int, error)
+	ListTrialsEndingSoon(ctx context.Context, userID string, from, to time.Time) ([]models.Subscription, error)
+
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) error
+	RotateAPIKey(ctx context.Context, key *models.APIKey) error
+	TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error
+
+	ReserveIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) error
+	ReleaseIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord) error
 }
 
 func NewStorage(db *sqlx.DB) *Storage {
-	return &Storage{db: db}
+	return &Storage{db: &tenantDB{DB: db}}
+}
+
+// EnableRowLevelSecurity включает установку app.tenant_id для политик PostgreSQL.
+// Запросы без транзакции при этом выполняются в отдельных транзакциях.
+func (s *Storage) EnableRowLevelSecurity() {
+	s.db.rowLevelSecurity = true
 }
 
 func (s *Storage) CreateSubscription(ctx context.Context, sub *models.Subscription) error {
@@ -42,6 +112,27 @@ func (s *Storage) CreateSubscription(ctx context.Context, sub *models.Subscripti
 		sub.ID = uuid.New()
 	}
 
+	// Приводим название сервиса к каноническому по каталогу
+	serviceName, err := s.canonicalServiceName(ctx, sub.ServiceName)
+	if err != nil {
+		return err
+	}
+	sub.ServiceName = serviceName
+
+	tags, err := s.subscriptionTags(ctx, sub)
+	if err != nil {
+		return err
+	}
+	sub.Tags = tags
+
+	if err := NormalizeMembers(sub); err != nil {
+		return err
+	}
+
+	if err := ValidatePhases(sub.Phases); err != nil {
+		return err
+	}
+
 	startDate := time.Time(sub.StartDate)
 	var endDate *time.Time
 	if sub.EndDate != nil {
@@ -49,9 +140,15 @@ func (s *Storage) CreateSubscription(ctx context.Context, sub *models.Subscripti
 		endDate = &ed
 	}
 
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := sq.Insert("subscriptions").
-		Columns("id", "user_id", "service_name", "price", "start_date", "end_date", "created_at", "updated_at").
-		Values(sub.ID, sub.UserID, sub.ServiceName, sub.Price, startDate, endDate, sq.Expr("NOW()"), sq.Expr("NOW()")).
+		Columns("id", "tenant_id", "user_id", "service_name", "price", "start_date", "end_date", "split_mode", "version", "created_at", "updated_at").
+		Values(sub.ID, tenant.FromContext(ctx), sub.UserID, sub.ServiceName, sub.Price, startDate, endDate, sub.SplitMode, 1, sq.Expr("NOW()"), sq.Expr("NOW()")).
 		PlaceholderFormat(sq.Dollar)
 
 	sqlStr, args, err := query.ToSql()
@@ -59,8 +156,27 @@ func (s *Storage) CreateSubscription(ctx context.Context, sub *models.Subscripti
 		return err
 	}
 
-	_, err = s.db.ExecContext(ctx, sqlStr, args...)
-	return err
+	if _, err = tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return mapError(err)
+	}
+
+	if err := setSubscriptionTags(ctx, tx, sub.ID, sub.Tags); err != nil {
+		return err
+	}
+
+	if err := setSubscriptionMembers(ctx, tx, sub.ID, sub.Members); err != nil {
+		return err
+	}
+
+	if err := setSubscriptionPhases(ctx, tx, sub.ID, sub.Phases); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	sub.Version = 1
+	return nil
 }
 
 func (s *Storage) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
@@ -69,6 +185,7 @@ func (s *Storage) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*model
 	query := sq.Select("*").
 		From("subscriptions").
 		Where(sq.Eq{"id": id}).
+		Where(tenantEq(ctx, "tenant_id")).
 		PlaceholderFormat(sq.Dollar)
 
 	sqlStr, args, err := query.ToSql()
@@ -76,14 +193,19 @@ func (s *Storage) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*model
 		return nil, err
 	}
 
-	err = s.db.GetContext(ctx, &sub, sqlStr, args...)
-	if err == sql.ErrNoRows {
-		return nil, nil
+	if err := s.db.GetContext(ctx, &sub, sqlStr, args...); err != nil {
+		return nil, mapError(err)
+	}
+
+	subs := []models.Subscription{sub}
+	if err := s.loadRelations(ctx, subs); err != nil {
+		return nil, err
 	}
-	return &sub, err
+	return &subs[0], nil
 }
 
-func (s *Storage) ListSubscriptions(ctx context.Context, page, limit int) ([]models.Subscription, error) {
+func (s *Storage) ListSubscriptions(ctx context.Context, filter ListFilter) ([]models.Subscription, error) {
+	page, limit := filter.Page, filter.Limit
 	if page < 1 {
 		page = 1
 	}
@@ -92,23 +214,115 @@ func (s *Storage) ListSubscriptions(ctx context.Context, page, limit int) ([]mod
 	}
 	offset := (page - 1) * limit
 
+	// Постоянный порядок нужен постраничному обходу: без него PostgreSQL может вернуть
+	// строки в разном порядке, и подписки пропадут или повторятся на соседних страницах
 	query := sq.Select("*").
 		From("subscriptions").
+		Where(tenantEq(ctx, "tenant_id")).
+		OrderBy("created_at", "id").
 		Limit(uint64(limit)).
 		Offset(uint64(offset)).
 		PlaceholderFormat(sq.Dollar)
 
+	if filter.UserID != "" {
+		// Пользователь видит свои подписки и совместные, в которых участвует
+		query = query.Where(sq.Or{
+			sq.Eq{"user_id": filter.UserID},
+			sq.Expr("id IN (SELECT subscription_id FROM subscription_members WHERE user_id = ?)", filter.UserID),
+		})
+	}
+	if filter.ServiceName != "" {
+		name, _, err := s.lookupServiceName(ctx, filter.ServiceName)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(sq.Eq{"service_name": name})
+	}
+	if !filter.From.IsZero() {
+		query = query.Where(sq.Or{
+			sq.Eq{"end_date": nil},
+			sq.GtOrEq{"end_date": filter.From},
+		})
+	}
+	if !filter.To.IsZero() {
+		query = query.Where(sq.LtOrEq{"start_date": filter.To})
+	}
+	if tags := normalizeTags(filter.Tags); len(tags) > 0 {
+		query = query.Where(sq.Expr(`id IN (
+			SELECT st.subscription_id FROM subscription_tags st
+			JOIN tags t ON t.id = st.tag_id
+			WHERE t.name = ANY(?)
+			GROUP BY st.subscription_id
+			HAVING COUNT(DISTINCT t.name) = ?)`, tags, len(tags)))
+	}
+
 	sqlStr, args, err := query.ToSql()
 	if err != nil {
 		return nil, err
 	}
 
 	var subs []models.Subscription
-	err = s.db.SelectContext(ctx, &subs, sqlStr, args...)
-	return subs, err
+	if err := s.db.SelectContext(ctx, &subs, sqlStr, args...); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadRelations(ctx, subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// loadRelations заполняет связанные с подписками теги, участников, пробные периоды и паузы
+func (s *Storage) loadRelations(ctx context.Context, subs []models.Subscription) error {
+	if err := s.loadTags(ctx, subs); err != nil {
+		return err
+	}
+	if err := s.loadMembers(ctx, subs); err != nil {
+		return err
+	}
+	if err := s.loadPhases(ctx, subs); err != nil {
+		return err
+	}
+	return s.loadPauses(ctx, subs)
 }
 
+// ErrOwnerChanged возвращается, если при обновлении подписки передан другой владелец
+var ErrOwnerChanged = newError(ErrInvalidInput, "user_id of a subscription cannot be changed")
+
+// UpdateSubscription не меняет владельца подписки: пустой sub.UserID заполняется
+// сохраненным, а другой возвращает ErrOwnerChanged
 func (s *Storage) UpdateSubscription(ctx context.Context, sub *models.Subscription) error {
+	owner, err := s.subscriptionOwner(ctx, sub.ID)
+	if err != nil {
+		return err
+	}
+	if sub.UserID == uuid.Nil {
+		// Владелец нужен и для ответа, и для расчета долей участников
+		sub.UserID = owner
+	} else if sub.UserID != owner {
+		return ErrOwnerChanged
+	}
+
+	serviceName, err := s.canonicalServiceName(ctx, sub.ServiceName)
+	if err != nil {
+		return err
+	}
+	sub.ServiceName = serviceName
+
+	tags, err := s.subscriptionTags(ctx, sub)
+	if err != nil {
+		return err
+	}
+	sub.Tags = tags
+
+	if err := NormalizeMembers(sub); err != nil {
+		return err
+	}
+
+	if err := ValidatePhases(sub.Phases); err != nil {
+		return err
+	}
+
 	startDate := time.Time(sub.StartDate)
 
 	var endDate *time.Time
@@ -122,30 +336,95 @@ func (s *Storage) UpdateSubscription(ctx context.Context, sub *models.Subscripti
 		Set("price", sub.Price).
 		Set("start_date", startDate).
 		Set("end_date", endDate).
+		Set("split_mode", sub.SplitMode).
+		Set("version", sq.Expr("version + 1")).
 		Set("updated_at", sq.Expr("NOW()")).
 		Where(sq.Eq{"id": sub.ID}).
+		Where(tenantEq(ctx, "tenant_id")).
+		Suffix("RETURNING version, created_at, updated_at").
 		PlaceholderFormat(sq.Dollar)
+	if sub.Version > 0 {
+		query = query.Where(sq.Eq{"version": sub.Version})
+	}
 
 	sqlStr, args, err := query.ToSql()
 	if err != nil {
 		return err
 	}
 
-	_, err = s.db.ExecContext(ctx, sqlStr, args...)
-	return err
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var updated struct {
+		Version   int             `db:"version"`
+		CreatedAt models.DataOnly `db:"created_at"`
+		UpdatedAt models.DataOnly `db:"updated_at"`
+	}
+	if err := tx.GetContext(ctx, &updated, sqlStr, args...); err != nil {
+		if err = mapError(err); err == ErrNotFound {
+			return subscriptionMissing(ctx, tx, sub.ID, sub.Version)
+		}
+		return err
+	}
+
+	if err := setSubscriptionTags(ctx, tx, sub.ID, sub.Tags); err != nil {
+		return err
+	}
+
+	if err := setSubscriptionMembers(ctx, tx, sub.ID, sub.Members); err != nil {
+		return err
+	}
+
+	if err := setSubscriptionPhases(ctx, tx, sub.ID, sub.Phases); err != nil {
+		return err
+	}
+
+	// Поля, которые не меняются запросом, заполняются сохраненными значениями
+	pauses, err := subscriptionPauses(ctx, tx, sub.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	sub.Version = updated.Version
+	sub.CreatedAt, sub.UpdatedAt = updated.CreatedAt, updated.UpdatedAt
+	sub.Pauses = pauses
+	sub.TrialEndDate = nil
+	if end := TrialEndDate(startDate, sub.Phases); end != nil {
+		trialEnd := models.DataOnly(*end)
+		sub.TrialEndDate = &trialEnd
+	}
+	return nil
 }
 
-func (s *Storage) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+// DeleteSubscription удаляет подписку. Если version больше нуля, подписка удаляется
+// только в этой версии, иначе возвращается ErrVersionMismatch.
+func (s *Storage) DeleteSubscription(ctx context.Context, id uuid.UUID, version int) error {
 	query := sq.Delete("subscriptions").
 		Where(sq.Eq{"id": id}).
+		Where(tenantEq(ctx, "tenant_id")).
 		PlaceholderFormat(sq.Dollar)
+	if version > 0 {
+		query = query.Where(sq.Eq{"version": version})
+	}
 
 	sqlStr, args, err := query.ToSql()
 	if err != nil {
 		return err
 	}
 
-	res, err := s.db.ExecContext(ctx, sqlStr, args...)
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, sqlStr, args...)
 	if err != nil {
 		return err
 	}
@@ -155,65 +434,236 @@ func (s *Storage) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
 		return err
 	}
 	if rowsAffected == 0 {
-		return sql.ErrNoRows
+		return subscriptionMissing(ctx, tx, id, version)
 	}
 
-	return nil
+	return tx.Commit()
+}
+
+// subscriptionOwner возвращает user_id владельца подписки
+func (s *Storage) subscriptionOwner(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	query := sq.Select("user_id").
+		From("subscriptions").
+		Where(sq.Eq{"id": id}).
+		Where(tenantEq(ctx, "tenant_id")).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var owner uuid.UUID
+	if err := s.db.GetContext(ctx, &owner, sqlStr, args...); err != nil {
+		return uuid.Nil, mapError(err)
+	}
+	return owner, nil
+}
+
+// subscriptionMissing объясняет, почему изменение подписки не затронуло ни одной строки:
+// ErrVersionMismatch, если подписка есть, но изменилась после чтения клиентом, иначе ErrNotFound
+func subscriptionMissing(ctx context.Context, tx *sqlx.Tx, id uuid.UUID, version int) error {
+	if version <= 0 {
+		return ErrNotFound
+	}
+
+	query := sq.Select("1").
+		From("subscriptions").
+		Where(sq.Eq{"id": id}).
+		Where(tenantEq(ctx, "tenant_id")).
+		Prefix("SELECT EXISTS (").
+		Suffix(")").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	var exists bool
+	if err := tx.GetContext(ctx, &exists, sqlStr, args...); err != nil {
+		return err
+	}
+	if exists {
+		return newError(ErrVersionMismatch, "subscription was modified since it was read")
+	}
+	return ErrNotFound
+}
+
+// bumpSubscriptionVersion отмечает изменение подписки, чтобы ранее выданные ETag перестали совпадать,
+// и возвращает новую версию
+func bumpSubscriptionVersion(ctx context.Context, tx *sqlx.Tx, id uuid.UUID) (int, error) {
+	query := sq.Update("subscriptions").
+		Set("version", sq.Expr("version + 1")).
+		Set("updated_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id}).
+		Where(tenantEq(ctx, "tenant_id")).
+		Suffix("RETURNING version").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var version int
+	if err := tx.GetContext(ctx, &version, sqlStr, args...); err != nil {
+		return 0, mapError(err)
+	}
+	return version, nil
 }
 
 func (s *Storage) SumSubscriptionsCost(ctx context.Context, userID, serviceName string, filterStart, filterEnd time.Time) (int64, error) {
+	subs, err := s.selectPeriods(ctx, userID, serviceName, filterStart, filterEnd)
+	if err != nil {
+		return 0, err
+	}
+	return totalCost(subs, filterStart, filterEnd), nil
+}
+
+// SumSubscriptionsCostByCategory считает стоимость подписок отдельно по каждому тегу.
+// Подписка с несколькими тегами учитывается в каждом из них,
+// подписки без тегов попадают в категорию UncategorizedTag.
+func (s *Storage) SumSubscriptionsCostByCategory(ctx context.Context, userID, serviceName string, filterStart, filterEnd time.Time) (map[string]int64, error) {
+	subs, err := s.selectPeriods(ctx, userID, serviceName, filterStart, filterEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(subs))
+	for _, sub := range subs {
+		ids = append(ids, sub.ID)
+	}
+	tagsByID, err := s.tagsBySubscription(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]SubscriptionPeriod)
+	for _, sub := range subs {
+		tags := tagsByID[sub.ID]
+		if len(tags) == 0 {
+			tags = []string{UncategorizedTag}
+		}
+		for _, tag := range tags {
+			groups[tag] = append(groups[tag], sub)
+		}
+	}
+
+	totals := make(map[string]int64, len(groups))
+	for tag, group := range groups {
+		totals[tag] = totalCost(group, filterStart, filterEnd)
+	}
+	return totals, nil
+}
+
+// selectPeriods выбирает периоды подписок, пересекающиеся с интервалом фильтра
+func (s *Storage) selectPeriods(ctx context.Context, userID, serviceName string, filterStart, filterEnd time.Time) ([]SubscriptionPeriod, error) {
 	var subs []SubscriptionPeriod
 
-	query := sq.Select("price", "start_date", "end_date").
-		From("subscriptions").
+	query := sq.Select("s.id", "s.service_name", "s.start_date", "s.end_date").
+		From("subscriptions s").
+		Where(tenantEq(ctx, "s.tenant_id")).
 		Where(
 			sq.And{
-				sq.Eq{"user_id": userID},           // Пример фильтрации по userID
-				sq.Eq{"service_name": serviceName}, // Пример фильтрации по serviceName
+				sq.LtOrEq{"s.start_date": filterEnd},
 				sq.Or{
-					sq.And{
-						sq.GtOrEq{"start_date": filterStart},
-						sq.LtOrEq{"end_date": filterEnd},
-					},
-					sq.And{
-						sq.LtOrEq{"start_date": filterEnd},
-						sq.GtOrEq{"end_date": filterStart},
-					},
+					sq.Eq{"s.end_date": nil},
+					sq.GtOrEq{"s.end_date": filterStart},
 				},
 			},
 		).
 		PlaceholderFormat(sq.Dollar)
 	if userID != "" {
-		query = query.Where(sq.Eq{"user_id": userID})
+		// Пользователь платит полную цену за личные подписки
+		// и только свою долю за совместные
+		query = query.
+			Columns("COALESCE(m.user_id, s.user_id) AS user_id", "s.price", "COALESCE(m.share, 1)::FLOAT8 AS share").
+			LeftJoin("subscription_members m ON m.subscription_id = s.id AND m.user_id = ?", userID).
+			Where(sq.Or{
+				sq.NotEq{"m.user_id": nil},
+				sq.And{
+					sq.Eq{"s.user_id": userID},
+					sq.Eq{"s.split_mode": models.SplitNone},
+				},
+			})
+	} else {
+		query = query.Columns("s.user_id", "s.price", "1::FLOAT8 AS share")
 	}
 	if serviceName != "" {
-		query = query.Where(sq.Eq{"service_name": serviceName})
+		// Фильтр по сервису учитывает алиасы из каталога
+		name, _, err := s.lookupServiceName(ctx, serviceName)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(sq.Eq{"s.service_name": name})
 	}
 
 	sqlStr, args, err := query.ToSql()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	log.Printf("SumSubscripionsCost SQL: %s\nARGS:%v|n", sqlStr, args)
+	logger := logging.GetLogger()
+	logger.DebugContext(ctx, "selectPeriods: query",
+		slog.String("sql", sqlStr),
+		slog.String("user_id", userID),
+		slog.String("service_name", serviceName),
+		slog.Time("start", filterStart),
+		slog.Time("end", filterEnd),
+	)
 
 	err = s.db.SelectContext(ctx, &subs, sqlStr, args...)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	if logger.Enabled(ctx, slog.LevelDebug) {
+		rows := make([]slog.Attr, len(subs))
+		for i, sub := range subs {
+			rows[i] = slog.Any(strconv.Itoa(i), sub)
+		}
+		logger.DebugContext(ctx, "selectPeriods: fetched periods", slog.Int("count", len(subs)), slog.Attr{Key: "periods", Value: slog.GroupValue(rows...)})
+	}
+
+	for i := range subs {
+		subs[i].Price = applyShare(subs[i].Price, subs[i].Share)
+	}
+	if err := s.attachPhases(ctx, subs); err != nil {
+		return nil, err
+	}
+	if err := s.attachPauses(ctx, subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// totalCost суммирует стоимость периодов. Пересекающиеся периоды
+// объединяются только в рамках одной пары пользователь + сервис,
+// поэтому разные сервисы одного пользователя складываются.
+func totalCost(subs []SubscriptionPeriod, filterStart, filterEnd time.Time) int64 {
+	type groupKey struct {
+		userID      uuid.UUID
+		serviceName string
+	}
+	groups := make(map[groupKey][]SubscriptionPeriod)
+	for _, sub := range subs {
+		key := groupKey{userID: sub.UserID, serviceName: sub.ServiceName}
+		groups[key] = append(groups[key], sub)
 	}
-	log.Printf("Fetched subscriptions: %+v\n", subs)
 
 	total := int64(0)
-	merged := MergeIntervals(subs, filterStart, filterEnd)
-	for _, sub := range merged {
-		// Обработка EndDate в случае nil - подставляем filterEnd
-		end := filterEnd
-		if sub.EndDate != nil {
-			end = *sub.EndDate
+	for _, group := range groups {
+		merged := MergeIntervals(group, filterStart, filterEnd)
+		for _, sub := range merged {
+			// Обработка EndDate в случае nil - подставляем filterEnd
+			end := filterEnd
+			if sub.EndDate != nil {
+				end = *sub.EndDate
+			}
+			months := MonthsBetween(sub.StartDate, end)
+			total += sub.Price * int64(months)
 		}
-		months := MonthsBetween(sub.StartDate, end)
-		total += sub.Price * int64(months)
 	}
-	return total, nil
+	return total
 }
 
 // MonthsBetween считает количество месяцев между датами start и end
@@ -233,7 +683,16 @@ func MonthsBetween(start, end time.Time) int {
 	return months + 1
 }
 
-func MergeIntervals(subs []SubscriptionPeriod, filterStart, filterEnd time.Time) []SubscriptionPeriod {
+// MergeIntervals объединяет пересекающиеся периоды, выбирая максимальную цену.
+// Пробные периоды предварительно выделяются в отрезки со своей ценой,
+// а паузы вырезаются из периодов и в результат не попадают.
+func MergeIntervals(periods []SubscriptionPeriod, filterStart, filterEnd time.Time) []SubscriptionPeriod {
+	var subs []SubscriptionPeriod
+	for _, period := range periods {
+		for _, segment := range splitByPhases(period) {
+			subs = append(subs, splitByPauses(segment)...)
+		}
+	}
 	if len(subs) == 0 {
 		return nil
 	}
@@ -245,17 +704,24 @@ func MergeIntervals(subs []SubscriptionPeriod, filterStart, filterEnd time.Time)
 	var merged []SubscriptionPeriod
 
 	current := SubscriptionPeriod{
-		Price:     subs[0].Price,
-		StartDate: maxTime(subs[0].StartDate, filterStart),
-		EndDate:   minTimePtr(subs[0].EndDate, &filterEnd),
+		UserID:      subs[0].UserID,
+		ServiceName: subs[0].ServiceName,
+		Price:       subs[0].Price,
+		StartDate:   maxTime(subs[0].StartDate, filterStart),
+		EndDate:     minTimePtr(subs[0].EndDate, &filterEnd),
 	}
 
 	for i := 1; i < len(subs); i++ {
 		start := maxTime(subs[i].StartDate, filterStart)
 		end := minTimePtr(subs[i].EndDate, &filterEnd)
 
-		// Проверяем пересечение интервалов (с допуском в 1 день)
-		if !start.After(addOneDay(current.EndDate)) {
+		// Проверяем пересечение интервалов (с допуском в 1 день).
+		// Соседние отрезки с разной ценой, например пробный период и полная цена, не объединяются.
+		// Отрезки до и после паузы внутри одного месяца объединяются, иначе MonthsBetween
+		// посчитал бы этот месяц дважды и пауза сделала бы подписку дороже.
+		overlaps := !start.After(*current.EndDate)
+		adjacent := !start.After(addOneDay(current.EndDate)) || sameMonth(start, *current.EndDate)
+		if overlaps || (adjacent && subs[i].Price == current.Price) {
 			if end.After(*current.EndDate) {
 				current.EndDate = end
 			}
@@ -265,9 +731,11 @@ func MergeIntervals(subs []SubscriptionPeriod, filterStart, filterEnd time.Time)
 		} else {
 			merged = append(merged, current)
 			current = SubscriptionPeriod{
-				Price:     subs[i].Price,
-				StartDate: start,
-				EndDate:   end,
+				UserID:      subs[i].UserID,
+				ServiceName: subs[i].ServiceName,
+				Price:       subs[i].Price,
+				StartDate:   start,
+				EndDate:     end,
 			}
 		}
 	}
@@ -275,6 +743,11 @@ func MergeIntervals(subs []SubscriptionPeriod, filterStart, filterEnd time.Time)
 	return merged
 }
 
+// sameMonth сообщает, что даты приходятся на один календарный месяц
+func sameMonth(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.Month() == b.Month()
+}
+
 func maxTime(a, b time.Time) time.Time {
 	if a.After(b) {
 		return a
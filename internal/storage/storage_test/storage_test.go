@@ -2,13 +2,19 @@ package storage
 
 import (
 	"context"
-	"database/sql"
+	"errors"
+	"io"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"subscribe_aggregation-main/internal/migrations"
 	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/ratelimit"
 	"subscribe_aggregation-main/internal/storage"
+	"subscribe_aggregation-main/internal/tenant"
 
 	"testing"
 	"time"
@@ -37,26 +43,14 @@ func setupTestDB(t *testing.T) *sqlx.DB {
 		t.Fatalf("Failed to connect to test db: %v", err)
 	}
 
-	// Выполните миграции таблиц подписок, например:
-	_, err = db.Exec(`
-        CREATE TABLE IF NOT EXISTS subscriptions (
-            id UUID PRIMARY KEY,
-            user_id TEXT NOT NULL,
-            service_name TEXT NOT NULL,
-            price BIGINT NOT NULL,
-            start_date TIMESTAMPTZ NOT NULL,
-            end_date TIMESTAMPTZ,
-            created_at TIMESTAMPTZ NOT NULL,
-            updated_at TIMESTAMPTZ NOT NULL
-        )
-    `)
-
-	if err != nil {
-		t.Fatalf("Failed creating subscriptions table: %v", err)
+	// Схема создается теми же встроенными миграциями, что и у сервера, поэтому
+	// тесты проверяют и сами миграции, включая политики row-level security
+	if _, err := migrations.Up(context.Background(), db.DB); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
 	}
 
 	// Очистка таблицы перед каждым тестом
-	_, err = db.Exec("TRUNCATE TABLE subscriptions")
+	_, err = db.Exec("TRUNCATE TABLE subscriptions, services, tags, subscription_tags, subscription_members, subscription_pauses, subscription_phases, api_keys, rate_limits, idempotency_keys")
 	if err != nil {
 		t.Fatalf("Failed to truncate subscriptions table: %v", err)
 	}
@@ -128,7 +122,7 @@ func TestStorage_GetSubscriptionByID(t *testing.T) {
 		wantErr bool
 	}{
 		{name: "found", id: testSub.ID, want: testSub, wantErr: false},
-		{name: "not found", id: uuid.New(), want: nil, wantErr: false},
+		{name: "not found", id: uuid.New(), want: nil, wantErr: true},
 	}
 
 	for _, tt := range tests {
@@ -225,7 +219,7 @@ func TestStorage_ListSubscriptions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := store.ListSubscriptions(context.Background(), tt.page, tt.limit)
+			got, err := store.ListSubscriptions(context.Background(), storage.ListFilter{Page: tt.page, Limit: tt.limit})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ListSubscriptions() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -241,6 +235,31 @@ func TestStorage_ListSubscriptions(t *testing.T) {
 			}
 		})
 	}
+
+	// Обход по страницам возвращает каждую подписку ровно один раз
+	t.Run("all pages", func(t *testing.T) {
+		seen := map[uuid.UUID]int{}
+		for page := 1; ; page++ {
+			got, err := store.ListSubscriptions(context.Background(), storage.ListFilter{Page: page, Limit: 2})
+			if err != nil {
+				t.Fatalf("ListSubscriptions() page %d error = %v", page, err)
+			}
+			for _, sub := range got {
+				seen[sub.ID]++
+			}
+			if len(got) < 2 {
+				break
+			}
+		}
+		if len(seen) != len(subs) {
+			t.Errorf("pages returned %d subscriptions, want %d", len(seen), len(subs))
+		}
+		for _, sub := range subs {
+			if seen[sub.ID] != 1 {
+				t.Errorf("subscription %s returned %d times, want 1", sub.ID, seen[sub.ID])
+			}
+		}
+	})
 }
 func TestStorage_DeleteSubscription(t *testing.T) {
 	db := setupTestDB(t)
@@ -259,14 +278,14 @@ func TestStorage_DeleteSubscription(t *testing.T) {
 	}
 
 	t.Run("Delete existing subscription", func(t *testing.T) {
-		err := store.DeleteSubscription(context.Background(), sub.ID)
+		err := store.DeleteSubscription(context.Background(), sub.ID, 0)
 		if err != nil {
 			t.Fatalf("DeleteSubscription() error = %v", err)
 		}
 		// Проверить что(subscription реально удалена)
 		got, err := store.GetSubscriptionByID(context.Background(), sub.ID)
-		if err != nil && err != sql.ErrNoRows {
-			t.Fatalf("GetSubscriptionByID() error = %v", err)
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("GetSubscriptionByID() error = %v, want ErrNotFound", err)
 		}
 		if got != nil {
 			t.Error("expected subscription to be deleted, but it still exists")
@@ -274,9 +293,9 @@ func TestStorage_DeleteSubscription(t *testing.T) {
 	})
 
 	t.Run("Delete non-existing subscription", func(t *testing.T) {
-		err := store.DeleteSubscription(context.Background(), uuid.New())
-		if err != sql.ErrNoRows {
-			t.Errorf("expected sql.ErrNoRows, got %v", err)
+		err := store.DeleteSubscription(context.Background(), uuid.New(), 0)
+		if err != storage.ErrNotFound {
+			t.Errorf("expected ErrNotFound, got %v", err)
 		}
 	})
 }
@@ -291,9 +310,9 @@ func TestStorage_SumSubscriptionsCost(t *testing.T) {
 
 	// Создаем подписки с пересекающимися периодами
 	start1 := models.DataOnly(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
-	end1 := models.DataOnly(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	end1 := models.EndDate(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
 	start2 := models.DataOnly(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
-	end2 := models.DataOnly(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+	end2 := models.EndDate(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
 
 	subs := []models.Subscription{
 		{
@@ -399,6 +418,168 @@ func TestMergeIntervals(t *testing.T) {
 	}
 }
 
+func TestMergeIntervalsWithPauses(t *testing.T) {
+	filterStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	filterEnd := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	pauseEnd := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	subs := []storage.SubscriptionPeriod{
+		{
+			Price:     100,
+			StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Pauses: []storage.PausePeriod{
+				// Март, апрель и май не оплачиваются
+				{StartDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), EndDate: &pauseEnd},
+				// Открытая пауза исключает все месяцы с октября
+				{StartDate: time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+	}
+
+	merged := storage.MergeIntervals(subs, filterStart, filterEnd)
+
+	if len(merged) != 2 {
+		t.Fatalf("Expected 2 active intervals, got %d: %+v", len(merged), merged)
+	}
+	months := 0
+	for _, m := range merged {
+		months += storage.MonthsBetween(m.StartDate, *m.EndDate)
+	}
+	// Январь, февраль и июнь-сентябрь
+	if months != 6 {
+		t.Errorf("Expected 6 paid months, got %d", months)
+	}
+}
+
+func TestMergeIntervalsWithMidMonthPauses(t *testing.T) {
+	filterStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	filterEnd := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		pauseStart time.Time
+		pauseEnd   time.Time
+		want       int64
+	}{
+		// Март и май оплачиваются как частичные, апрель — нет
+		{"across months", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC), 1100},
+		// Пауза внутри марта не освобождает месяц, но и не оплачивает его дважды
+		{"within one month", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC), 1200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs := []storage.SubscriptionPeriod{{
+				Price:     100,
+				StartDate: filterStart,
+				EndDate:   &filterEnd,
+				Pauses:    []storage.PausePeriod{{StartDate: tt.pauseStart, EndDate: &tt.pauseEnd}},
+			}}
+
+			total := int64(0)
+			for _, m := range storage.MergeIntervals(subs, filterStart, filterEnd) {
+				total += m.Price * int64(storage.MonthsBetween(m.StartDate, *m.EndDate))
+			}
+			if total != tt.want {
+				t.Errorf("Expected total %d, got %d", tt.want, total)
+			}
+		})
+	}
+}
+
+func TestMergeIntervalsWithPhases(t *testing.T) {
+	filterStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	filterEnd := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	subs := []storage.SubscriptionPeriod{
+		{
+			Price:     300,
+			StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Phases: []storage.PricePhase{
+				{Months: 1, Price: 0},   // бесплатный январь
+				{Months: 2, Price: 100}, // февраль и март со скидкой
+			},
+		},
+	}
+
+	merged := storage.MergeIntervals(subs, filterStart, filterEnd)
+
+	total := int64(0)
+	for _, m := range merged {
+		total += m.Price * int64(storage.MonthsBetween(m.StartDate, *m.EndDate))
+	}
+	// 0 + 2*100 + 3*300 (апрель-июнь)
+	if total != 1100 {
+		t.Errorf("Expected total 1100, got %d (%+v)", total, merged)
+	}
+}
+
+func TestTrialEndDate(t *testing.T) {
+	start := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	if got := storage.TrialEndDate(start, nil); got != nil {
+		t.Errorf("expected nil without phases, got %v", got)
+	}
+
+	got := storage.TrialEndDate(start, []models.PricePhase{{Months: 1}, {Months: 2, Price: 99}})
+	want := time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC)
+	if got == nil || !got.Equal(want) {
+		t.Errorf("TrialEndDate() = %v, want %v", got, want)
+	}
+
+	// Как и в PostgreSQL, день ограничивается концом месяца, а не переносится на следующий
+	for _, tt := range []struct {
+		start  time.Time
+		months int
+		want   time.Time
+	}{
+		{time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), 1, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC), 1, time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), 3, time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 10, 31, 0, 0, 0, 0, time.UTC), 3, time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)},
+	} {
+		got := storage.TrialEndDate(tt.start, []models.PricePhase{{Months: tt.months}})
+		if got == nil || !got.Equal(tt.want) {
+			t.Errorf("TrialEndDate(%v, %d months) = %v, want %v", tt.start, tt.months, got, tt.want)
+		}
+	}
+}
+
+func TestStorage_PauseResume(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := storage.NewStorage(db)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	start := models.DataOnly(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sub := &models.Subscription{UserID: userID, ServiceName: "svc1", Price: 100, StartDate: start}
+	if err := store.CreateSubscription(ctx, sub); err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+
+	if _, _, err := store.ResumeSubscription(ctx, sub.ID, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)); err != storage.ErrNotPaused {
+		t.Errorf("expected ErrNotPaused, got %v", err)
+	}
+	if _, _, err := store.PauseSubscription(ctx, sub.ID, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("PauseSubscription failed: %v", err)
+	}
+	if _, _, err := store.PauseSubscription(ctx, sub.ID, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)); err != storage.ErrAlreadyPaused {
+		t.Errorf("expected ErrAlreadyPaused, got %v", err)
+	}
+	if _, _, err := store.ResumeSubscription(ctx, sub.ID, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("ResumeSubscription failed: %v", err)
+	}
+
+	// Январь-июнь без марта и апреля
+	got, err := store.SumSubscriptionsCost(ctx, userID.String(), "", start.ToTime(), time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("SumSubscriptionsCost failed: %v", err)
+	}
+	if got != 400 {
+		t.Errorf("SumSubscriptionsCost() = %d, want 400", got)
+	}
+}
+
 func TestMonthsBetween(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -429,3 +610,707 @@ func TestMonthsBetween(t *testing.T) {
 		})
 	}
 }
+
+func TestStorage_ServiceNameNormalization(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := storage.NewStorage(db)
+	ctx := context.Background()
+
+	svc := &models.Service{
+		Name:    "Yandex Plus",
+		Aliases: []string{"Яндекс  Плюс"},
+	}
+	if err := store.CreateService(ctx, svc); err != nil {
+		t.Fatalf("CreateService failed: %v", err)
+	}
+
+	userID := uuid.New()
+	start := models.DataOnly(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	for _, name := range []string{"Yandex Plus", "yandex plus ", "Яндекс Плюс"} {
+		sub := &models.Subscription{
+			UserID:      userID,
+			ServiceName: name,
+			Price:       100,
+			StartDate:   start,
+		}
+		if err := store.CreateSubscription(ctx, sub); err != nil {
+			t.Fatalf("CreateSubscription failed: %v", err)
+		}
+		if sub.ServiceName != "Yandex Plus" {
+			t.Errorf("ServiceName = %q, want %q", sub.ServiceName, "Yandex Plus")
+		}
+	}
+
+	// Три записи одного сервиса объединяются в один период
+	filterEnd := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	got, err := store.SumSubscriptionsCost(ctx, userID.String(), "YANDEX PLUS", start.ToTime(), filterEnd)
+	if err != nil {
+		t.Fatalf("SumSubscriptionsCost failed: %v", err)
+	}
+	if got != 200 {
+		t.Errorf("SumSubscriptionsCost() = %d, want 200", got)
+	}
+
+	err = store.CreateService(ctx, &models.Service{Name: "Кинопоиск", Aliases: []string{"yandex plus"}})
+	if err != storage.ErrServiceAliasTaken {
+		t.Errorf("expected ErrServiceAliasTaken, got %v", err)
+	}
+}
+
+func TestStorage_Tags(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := storage.NewStorage(db)
+	ctx := context.Background()
+
+	if err := store.CreateService(ctx, &models.Service{Name: "Spotify", Category: "Music"}); err != nil {
+		t.Fatalf("CreateService failed: %v", err)
+	}
+
+	userID := uuid.New()
+	start := models.DataOnly(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	subs := []models.Subscription{
+		{UserID: userID, ServiceName: "Netflix", Price: 500, StartDate: start, Tags: []string{"Video", "family"}},
+		{UserID: userID, ServiceName: "Spotify", Price: 200, StartDate: start},
+		{UserID: userID, ServiceName: "Dropbox", Price: 300, StartDate: start},
+	}
+	for i := range subs {
+		if err := store.CreateSubscription(ctx, &subs[i]); err != nil {
+			t.Fatalf("CreateSubscription failed: %v", err)
+		}
+	}
+
+	// Подписка без тегов получает категорию из каталога
+	got, err := store.GetSubscriptionByID(ctx, subs[1].ID)
+	if err != nil {
+		t.Fatalf("GetSubscriptionByID failed: %v", err)
+	}
+	if len(got.Tags) != 1 || got.Tags[0] != "music" {
+		t.Errorf("Tags = %v, want [music]", got.Tags)
+	}
+
+	list, err := store.ListSubscriptions(ctx, storage.ListFilter{Tags: []string{"video", "FAMILY"}})
+	if err != nil {
+		t.Fatalf("ListSubscriptions failed: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != subs[0].ID {
+		t.Errorf("ListSubscriptions by tags = %+v, want only %s", list, subs[0].ID)
+	}
+
+	filterEnd := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	totals, err := store.SumSubscriptionsCostByCategory(ctx, userID.String(), "", start.ToTime(), filterEnd)
+	if err != nil {
+		t.Fatalf("SumSubscriptionsCostByCategory failed: %v", err)
+	}
+	want := map[string]int64{"video": 1000, "family": 1000, "music": 400, storage.UncategorizedTag: 600}
+	for tag, total := range want {
+		if totals[tag] != total {
+			t.Errorf("total for %q = %d, want %d", tag, totals[tag], total)
+		}
+	}
+}
+
+func TestStorage_SharedSubscriptionCost(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := storage.NewStorage(db)
+	ctx := context.Background()
+
+	owner, member := uuid.New(), uuid.New()
+	start := models.DataOnly(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	shared := &models.Subscription{
+		UserID:      owner,
+		ServiceName: "Family Plan",
+		Price:       900,
+		StartDate:   start,
+		SplitMode:   models.SplitCustom,
+		Members: []models.SubscriptionMember{
+			{UserID: owner, Share: 0.5},
+			{UserID: member, Share: 0.5},
+		},
+	}
+	if err := store.CreateSubscription(ctx, shared); err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+
+	filterEnd := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	for _, userID := range []uuid.UUID{owner, member} {
+		got, err := store.SumSubscriptionsCost(ctx, userID.String(), "", start.ToTime(), filterEnd)
+		if err != nil {
+			t.Fatalf("SumSubscriptionsCost failed: %v", err)
+		}
+		if got != 900 {
+			t.Errorf("SumSubscriptionsCost(%s) = %d, want 900", userID, got)
+		}
+	}
+
+	// Без фильтра по пользователю подписка учитывается один раз целиком
+	got, err := store.SumSubscriptionsCost(ctx, "", "", start.ToTime(), filterEnd)
+	if err != nil {
+		t.Fatalf("SumSubscriptionsCost failed: %v", err)
+	}
+	if got != 1800 {
+		t.Errorf("SumSubscriptionsCost(all) = %d, want 1800", got)
+	}
+
+	list, err := store.ListSubscriptions(ctx, storage.ListFilter{UserID: member.String()})
+	if err != nil {
+		t.Fatalf("ListSubscriptions failed: %v", err)
+	}
+	if len(list) != 1 || len(list[0].Members) != 2 {
+		t.Errorf("expected shared subscription with 2 members for member, got %+v", list)
+	}
+}
+
+func TestNormalizeMembers(t *testing.T) {
+	owner, member := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name       string
+		sub        models.Subscription
+		wantErr    bool
+		wantShares int
+	}{
+		{
+			name:       "personal",
+			sub:        models.Subscription{UserID: owner},
+			wantShares: 0,
+		},
+		{
+			name: "equal adds owner",
+			sub: models.Subscription{
+				UserID:  owner,
+				Members: []models.SubscriptionMember{{UserID: member}},
+			},
+			wantShares: 2,
+		},
+		{
+			name: "custom shares",
+			sub: models.Subscription{
+				UserID:    owner,
+				SplitMode: models.SplitCustom,
+				Members: []models.SubscriptionMember{
+					{UserID: owner, Share: 0.7},
+					{UserID: member, Share: 0.3},
+				},
+			},
+			wantShares: 2,
+		},
+		{
+			name: "custom shares do not sum to one",
+			sub: models.Subscription{
+				UserID:    owner,
+				SplitMode: models.SplitCustom,
+				Members: []models.SubscriptionMember{
+					{UserID: owner, Share: 0.7},
+					{UserID: member, Share: 0.7},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate member",
+			sub: models.Subscription{
+				UserID:  owner,
+				Members: []models.SubscriptionMember{{UserID: member}, {UserID: member}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := tt.sub
+			err := storage.NormalizeMembers(&sub)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeMembers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(sub.Members) != tt.wantShares {
+				t.Errorf("members = %d, want %d", len(sub.Members), tt.wantShares)
+			}
+			total := 0.0
+			for _, m := range sub.Members {
+				total += m.Share
+			}
+			if len(sub.Members) > 0 && (total < 0.9999 || total > 1.0001) {
+				t.Errorf("shares sum = %f, want 1", total)
+			}
+		})
+	}
+}
+
+func TestServiceKey(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Yandex Plus", "yandex plus"},
+		{"  yandex   plus ", "yandex plus"},
+		{"Яндекс Плюс", "яндекс плюс"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := storage.ServiceKey(tt.input); got != tt.want {
+			t.Errorf("ServiceKey(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestStorage_APIKeys(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := storage.NewStorage(db)
+	ctx := context.Background()
+
+	key := &models.APIKey{Name: "billing-export", Prefix: "sak_aaaaaaaa", Hash: strings.Repeat("a", 64), Scopes: []string{"reports:read"}}
+	if err := store.CreateAPIKey(ctx, key); err != nil {
+		t.Fatalf("CreateAPIKey failed: %v", err)
+	}
+
+	got, err := store.GetAPIKeyByHash(ctx, key.Hash)
+	if err != nil {
+		t.Fatalf("GetAPIKeyByHash failed: %v", err)
+	}
+	if got.ID != key.ID || len(got.Scopes) != 1 || got.LastUsedAt != nil {
+		t.Errorf("unexpected key %+v", got)
+	}
+
+	if err := store.TouchAPIKey(ctx, key.ID, time.Now().UTC()); err != nil {
+		t.Fatalf("TouchAPIKey failed: %v", err)
+	}
+	if got, _ = store.GetAPIKeyByHash(ctx, key.Hash); got.LastUsedAt == nil {
+		t.Error("expected last_used_at to be set")
+	}
+
+	rotated := &models.APIKey{ID: key.ID, Prefix: "sak_bbbbbbbb", Hash: strings.Repeat("b", 64)}
+	if err := store.RotateAPIKey(ctx, rotated); err != nil {
+		t.Fatalf("RotateAPIKey failed: %v", err)
+	}
+	if rotated.Name != key.Name {
+		t.Errorf("RotateAPIKey() name = %q, want %q", rotated.Name, key.Name)
+	}
+	if _, err := store.GetAPIKeyByHash(ctx, key.Hash); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected old hash to be rejected, got %v", err)
+	}
+
+	if err := store.RevokeAPIKey(ctx, key.ID); err != nil {
+		t.Fatalf("RevokeAPIKey failed: %v", err)
+	}
+	if _, err := store.GetAPIKeyByHash(ctx, rotated.Hash); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected revoked key to be rejected, got %v", err)
+	}
+	if err := store.RevokeAPIKey(ctx, key.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for repeated revoke, got %v", err)
+	}
+}
+
+func TestStorage_TenantIsolation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := storage.NewStorage(db)
+	acme := tenant.NewContext(context.Background(), "acme")
+	globex := tenant.NewContext(context.Background(), "globex")
+
+	userID := uuid.New()
+	start := models.DataOnly(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sub := &models.Subscription{UserID: userID, ServiceName: "Netflix", Price: 100, StartDate: start, Tags: []string{"video"}}
+	if err := store.CreateSubscription(acme, sub); err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+
+	if _, err := store.GetSubscriptionByID(globex, sub.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from another tenant, got %v", err)
+	}
+	if err := store.DeleteSubscription(globex, sub.ID, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound when deleting from another tenant, got %v", err)
+	}
+
+	subs, err := store.ListSubscriptions(globex, storage.ListFilter{UserID: userID.String()})
+	if err != nil {
+		t.Fatalf("ListSubscriptions failed: %v", err)
+	}
+	if len(subs) != 0 {
+		t.Errorf("expected no subscriptions for another tenant, got %d", len(subs))
+	}
+
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	total, err := store.SumSubscriptionsCost(globex, userID.String(), "", start.ToTime(), end)
+	if err != nil {
+		t.Fatalf("SumSubscriptionsCost failed: %v", err)
+	}
+	if total != 0 {
+		t.Errorf("SumSubscriptionsCost() for another tenant = %d, want 0", total)
+	}
+	if total, _ = store.SumSubscriptionsCost(acme, userID.String(), "", start.ToTime(), end); total != 300 {
+		t.Errorf("SumSubscriptionsCost() = %d, want 300", total)
+	}
+
+	// Каталог и теги у организаций свои
+	for ctx, want := range map[context.Context]int{acme: 1, globex: 0} {
+		services, err := store.ListServices(ctx, "")
+		if err != nil {
+			t.Fatalf("ListServices failed: %v", err)
+		}
+		tags, err := store.ListTags(ctx)
+		if err != nil {
+			t.Fatalf("ListTags failed: %v", err)
+		}
+		if len(services) != want || len(tags) != want {
+			t.Errorf("got %d services and %d tags, want %d", len(services), len(tags), want)
+		}
+	}
+}
+
+func TestStorage_RateLimits(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := storage.NewRateLimitStore(db)
+	ctx := context.Background()
+
+	limit := ratelimit.Limit{Rate: 1, Burst: 2}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	for i, allowed := range []bool{true, true, false} {
+		result, err := store.Take(ctx, "api:user:a", limit, now)
+		if err != nil {
+			t.Fatalf("Take failed: %v", err)
+		}
+		if result.Allowed != allowed {
+			t.Errorf("request %d: Allowed = %v, want %v", i, result.Allowed, allowed)
+		}
+	}
+
+	result, err := store.Take(ctx, "api:user:a", limit, now.Add(time.Second))
+	if err != nil {
+		t.Fatalf("Take failed: %v", err)
+	}
+	if !result.Allowed {
+		t.Error("expected request after refill to be allowed")
+	}
+}
+
+func TestStorage_Idempotency(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := storage.NewStorage(db)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	newRecord := func(at time.Time) *models.IdempotencyRecord {
+		return &models.IdempotencyRecord{
+			Client:      "user:a",
+			Key:         "key-1",
+			RequestHash: strings.Repeat("a", 64),
+			CreatedAt:   at,
+			ExpiresAt:   at.Add(time.Hour),
+			Lease:       time.Minute,
+		}
+	}
+
+	rec := newRecord(now)
+	existing, err := store.ReserveIdempotencyKey(ctx, rec)
+	if err != nil || existing != nil {
+		t.Fatalf("ReserveIdempotencyKey() = %v, %v, want nil, nil", existing, err)
+	}
+
+	// Пока первый запрос выполняется, повтор видит запись без ответа
+	existing, err = store.ReserveIdempotencyKey(ctx, newRecord(now))
+	if err != nil || existing == nil || existing.StatusCode != 0 {
+		t.Fatalf("expected in-progress record, got %+v, %v", existing, err)
+	}
+
+	rec.StatusCode = http.StatusCreated
+	rec.ContentType = "application/json"
+	rec.ETag = `"1"`
+	rec.ResponseBody = []byte(`{"id":"1"}`)
+	if err := store.CompleteIdempotencyKey(ctx, rec); err != nil {
+		t.Fatalf("CompleteIdempotencyKey failed: %v", err)
+	}
+
+	existing, err = store.ReserveIdempotencyKey(ctx, newRecord(now.Add(time.Minute)))
+	if err != nil || existing == nil {
+		t.Fatalf("expected stored record, got %+v, %v", existing, err)
+	}
+	if existing.StatusCode != http.StatusCreated || existing.ETag != `"1"` || string(existing.ResponseBody) != `{"id":"1"}` {
+		t.Errorf("unexpected stored response %+v", existing)
+	}
+
+	// Завершенный запрос не освобождается, а истекший ключ можно занять снова
+	if err := store.ReleaseIdempotencyKey(ctx, rec); err != nil {
+		t.Fatalf("ReleaseIdempotencyKey failed: %v", err)
+	}
+	existing, err = store.ReserveIdempotencyKey(ctx, newRecord(now.Add(2*time.Hour)))
+	if err != nil || existing != nil {
+		t.Fatalf("expected expired key to be reserved again, got %+v, %v", existing, err)
+	}
+
+	if err := store.DeleteExpiredIdempotencyKeys(ctx, now.Add(4*time.Hour)); err != nil {
+		t.Fatalf("DeleteExpiredIdempotencyKeys failed: %v", err)
+	}
+	existing, err = store.ReserveIdempotencyKey(ctx, newRecord(now.Add(4*time.Hour)))
+	if err != nil || existing != nil {
+		t.Fatalf("expected deleted key to be reserved again, got %+v, %v", existing, err)
+	}
+
+	// Незавершенный запрос держит ключ только в пределах Lease
+	later := now.Add(4 * time.Hour)
+	existing, err = store.ReserveIdempotencyKey(ctx, newRecord(later.Add(30*time.Second)))
+	if err != nil || existing == nil || existing.StatusCode != 0 {
+		t.Fatalf("expected in-progress record within lease, got %+v, %v", existing, err)
+	}
+	existing, err = store.ReserveIdempotencyKey(ctx, newRecord(later.Add(2*time.Minute)))
+	if err != nil || existing != nil {
+		t.Fatalf("expected abandoned key to be reserved again, got %+v, %v", existing, err)
+	}
+}
+
+func TestStorage_IdempotencyReclaimedKey(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := storage.NewStorage(db)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	stale := &models.IdempotencyRecord{
+		Client:      "user:a",
+		Key:         "key-reclaimed",
+		RequestHash: strings.Repeat("a", 64),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Hour),
+		Lease:       time.Minute,
+	}
+	if existing, err := store.ReserveIdempotencyKey(ctx, stale); err != nil || existing != nil {
+		t.Fatalf("ReserveIdempotencyKey() = %v, %v, want nil, nil", existing, err)
+	}
+
+	// Первый запрос не уложился в lease, и ключ занял повтор
+	fresh := *stale
+	fresh.CreatedAt = now.Add(2 * time.Minute)
+	fresh.ExpiresAt = fresh.CreatedAt.Add(time.Hour)
+	if existing, err := store.ReserveIdempotencyKey(ctx, &fresh); err != nil || existing != nil {
+		t.Fatalf("expected abandoned key to be reserved again, got %+v, %v", existing, err)
+	}
+
+	// Опоздавший первый запрос не может ни освободить, ни заполнить чужую резервацию
+	if err := store.ReleaseIdempotencyKey(ctx, stale); err != nil {
+		t.Fatalf("ReleaseIdempotencyKey failed: %v", err)
+	}
+	stale.StatusCode = http.StatusCreated
+	stale.ResponseBody = []byte(`{"id":"stale"}`)
+	if err := store.CompleteIdempotencyKey(ctx, stale); err != nil {
+		t.Fatalf("CompleteIdempotencyKey failed: %v", err)
+	}
+	existing, err := store.ReserveIdempotencyKey(ctx, &fresh)
+	if err != nil || existing == nil || existing.StatusCode != 0 {
+		t.Fatalf("expected reclaimed key to stay in progress, got %+v, %v", existing, err)
+	}
+
+	fresh.StatusCode = http.StatusCreated
+	fresh.ResponseBody = []byte(`{"id":"fresh"}`)
+	if err := store.CompleteIdempotencyKey(ctx, &fresh); err != nil {
+		t.Fatalf("CompleteIdempotencyKey failed: %v", err)
+	}
+	existing, err = store.ReserveIdempotencyKey(ctx, &fresh)
+	if err != nil || existing == nil || string(existing.ResponseBody) != `{"id":"fresh"}` {
+		t.Fatalf("expected response of the reclaiming request, got %+v, %v", existing, err)
+	}
+
+	// Завершенный ответ не перезаписывается повторным Complete
+	stale.CreatedAt = fresh.CreatedAt
+	if err := store.CompleteIdempotencyKey(ctx, stale); err != nil {
+		t.Fatalf("CompleteIdempotencyKey failed: %v", err)
+	}
+	existing, err = store.ReserveIdempotencyKey(ctx, &fresh)
+	if err != nil || existing == nil || string(existing.ResponseBody) != `{"id":"fresh"}` {
+		t.Fatalf("expected completed response to stay, got %+v, %v", existing, err)
+	}
+}
+
+func TestStorage_SubscriptionVersion(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := storage.NewStorage(db)
+	ctx := context.Background()
+
+	sub := &models.Subscription{
+		UserID:      uuid.New(),
+		ServiceName: "svc " + uuid.NewString(),
+		Price:       100,
+		StartDate:   models.DataOnly(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+	if err := store.CreateSubscription(ctx, sub); err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	if sub.Version != 1 {
+		t.Fatalf("Version = %d after create, want 1", sub.Version)
+	}
+
+	first, second := *sub, *sub
+	first.Price = 200
+	if err := store.UpdateSubscription(ctx, &first); err != nil {
+		t.Fatalf("UpdateSubscription failed: %v", err)
+	}
+	if first.Version != 2 {
+		t.Errorf("Version = %d after update, want 2", first.Version)
+	}
+	// Поля, которые не меняются запросом, возвращаются сохраненными
+	if time.Time(first.CreatedAt).IsZero() || time.Time(first.UpdatedAt).IsZero() {
+		t.Errorf("CreatedAt and UpdatedAt must be filled after update, got %v and %v", first.CreatedAt, first.UpdatedAt)
+	}
+
+	// Владелец не передается в обновлении и не может быть изменен
+	ownerless := first
+	ownerless.UserID = uuid.Nil
+	if err := store.UpdateSubscription(ctx, &ownerless); err != nil {
+		t.Fatalf("UpdateSubscription without user_id failed: %v", err)
+	}
+	if ownerless.UserID != sub.UserID {
+		t.Errorf("UserID = %s after update, want stored %s", ownerless.UserID, sub.UserID)
+	}
+	stranger := ownerless
+	stranger.UserID = uuid.New()
+	if err := store.UpdateSubscription(ctx, &stranger); !errors.Is(err, storage.ErrOwnerChanged) {
+		t.Fatalf("expected ErrOwnerChanged, got %v", err)
+	}
+	first = ownerless
+
+	// Вторая правка сделана по устаревшей версии и не должна затереть первую
+	second.Price = 300
+	if err := store.UpdateSubscription(ctx, &second); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch, got %v", err)
+	}
+	if err := store.DeleteSubscription(ctx, sub.ID, 1); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch on delete, got %v", err)
+	}
+
+	// Пауза тоже меняет версию
+	if _, _, err := store.PauseSubscription(ctx, sub.ID, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("PauseSubscription failed: %v", err)
+	}
+	got, err := store.GetSubscriptionByID(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetSubscriptionByID failed: %v", err)
+	}
+	if got.Version != 4 || got.Price != 200 {
+		t.Errorf("got version %d and price %d, want 4 and 200", got.Version, got.Price)
+	}
+
+	// Переименование по алиасу из каталога тоже меняет версию
+	svc := &models.Service{Name: "Renamed " + uuid.NewString(), Aliases: []string{got.ServiceName}}
+	if err := store.CreateService(ctx, svc); err != nil {
+		t.Fatalf("CreateService failed: %v", err)
+	}
+	got, err = store.GetSubscriptionByID(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetSubscriptionByID failed: %v", err)
+	}
+	if got.Version != 5 || got.ServiceName != svc.Name {
+		t.Errorf("got version %d and service %q, want 5 and %q", got.Version, got.ServiceName, svc.Name)
+	}
+
+	if err := store.DeleteSubscription(ctx, sub.ID, got.Version); err != nil {
+		t.Fatalf("DeleteSubscription failed: %v", err)
+	}
+	if err := store.DeleteSubscription(ctx, sub.ID, got.Version); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for deleted subscription, got %v", err)
+	}
+}
+
+func TestStorage_SubscriptionStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := storage.NewStorage(db)
+	tenantID := "stats-" + uuid.NewString()
+	ctx := tenant.NewContext(context.Background(), tenantID)
+
+	start := models.DataOnly(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	ended := models.EndDate(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC))
+	active := &models.Subscription{UserID: uuid.New(), ServiceName: "svc1", Price: 100, StartDate: start}
+	paused := &models.Subscription{UserID: uuid.New(), ServiceName: "svc2", Price: 200, StartDate: start}
+	expired := &models.Subscription{UserID: uuid.New(), ServiceName: "svc3", Price: 300, StartDate: start, EndDate: &ended}
+	// На 15 апреля еще действует пробная цена
+	trial := &models.Subscription{UserID: uuid.New(), ServiceName: "svc4", Price: 500, StartDate: start,
+		Phases: []models.PricePhase{{Months: 6, Price: 50}}}
+	// Совместная подписка учитывается один раз, а стоимость складывается из долей
+	shared := &models.Subscription{UserID: uuid.New(), ServiceName: "svc5", Price: 300, StartDate: start,
+		SplitMode: models.SplitEqual, Members: []models.SubscriptionMember{{UserID: uuid.New()}}}
+	for _, sub := range []*models.Subscription{active, paused, expired, trial, shared} {
+		if err := store.CreateSubscription(ctx, sub); err != nil {
+			t.Fatalf("CreateSubscription failed: %v", err)
+		}
+	}
+	if _, _, err := store.PauseSubscription(ctx, paused.ID, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("PauseSubscription failed: %v", err)
+	}
+
+	stats, err := store.SubscriptionStats(context.Background(), time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("SubscriptionStats failed: %v", err)
+	}
+	var got *storage.SubscriptionStats
+	for i := range stats {
+		if stats[i].TenantID == tenantID {
+			got = &stats[i]
+		}
+	}
+	if got == nil || got.Active != 3 || got.MonthlyTotal != 100+50+300 {
+		t.Errorf("SubscriptionStats() for tenant = %+v, want 3 active with monthly total 450", got)
+	}
+}
+
+// Откат организаций сливает одинаковые теги и сервисы разных организаций,
+// иначе глобальные ограничения уникальности не создались бы
+func TestMigrations_TenantDown(t *testing.T) {
+	db := setupTestDB(t)
+	store := storage.NewStorage(db)
+	start := models.DataOnly(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var ids []uuid.UUID
+	for _, tenantID := range []string{"acme", "default"} {
+		ctx := tenant.NewContext(context.Background(), tenantID)
+		sub := &models.Subscription{UserID: uuid.New(), ServiceName: "Netflix", Price: 100, StartDate: start, Tags: []string{"video"}}
+		if err := store.CreateSubscription(ctx, sub); err != nil {
+			t.Fatalf("CreateSubscription failed: %v", err)
+		}
+		ids = append(ids, sub.ID)
+	}
+
+	ctx := context.Background()
+	if err := migrations.Run(ctx, db.DB, io.Discard, "down-to", []string{"202510181700"}); err != nil {
+		t.Fatalf("down-to failed: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := migrations.Up(context.Background(), db.DB); err != nil {
+			t.Errorf("Failed to apply migrations again: %v", err)
+		}
+	})
+
+	var tags, services int
+	if err := db.Get(&tags, "SELECT COUNT(*) FROM tags WHERE name = 'video'"); err != nil {
+		t.Fatalf("count tags: %v", err)
+	}
+	if err := db.Get(&services, "SELECT COUNT(*) FROM services WHERE lower(name) = 'netflix'"); err != nil {
+		t.Fatalf("count services: %v", err)
+	}
+	if tags != 1 || services != 1 {
+		t.Errorf("got %d tags and %d services after down, want 1 and 1", tags, services)
+	}
+
+	// Обе подписки сохранили тег
+	var tagged int
+	query, args, err := sqlx.In("SELECT COUNT(*) FROM subscription_tags WHERE subscription_id IN (?)", ids)
+	if err != nil {
+		t.Fatalf("build query: %v", err)
+	}
+	if err := db.Get(&tagged, db.Rebind(query), args...); err != nil {
+		t.Fatalf("count subscription tags: %v", err)
+	}
+	if tagged != 2 {
+		t.Errorf("got %d tagged subscriptions after down, want 2", tagged)
+	}
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/tenant"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// UncategorizedTag — категория для подписок без тегов при группировке стоимости
+const UncategorizedTag = "uncategorized"
+
+// normalizeTags приводит теги к нижнему регистру, схлопывает пробелы
+// и убирает пустые значения и дубликаты
+func normalizeTags(tags []string) pq.StringArray {
+	seen := make(map[string]struct{}, len(tags))
+	result := pq.StringArray{}
+	for _, tag := range tags {
+		key := ServiceKey(tag)
+		if key == "" {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		result = append(result, key)
+	}
+	return result
+}
+
+// subscriptionTags возвращает нормализованные теги подписки.
+// Если теги не заданы, подписка получает категорию сервиса из каталога.
+func (s *Storage) subscriptionTags(ctx context.Context, sub *models.Subscription) ([]string, error) {
+	tags := normalizeTags(sub.Tags)
+	if len(tags) > 0 {
+		return tags, nil
+	}
+
+	query := sq.Select("category").
+		From("services").
+		Where(sq.Eq{"name": sub.ServiceName}).
+		Where(tenantEq(ctx, "tenant_id")).
+		Where(sq.NotEq{"category": ""}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var categories []string
+	if err := s.db.SelectContext(ctx, &categories, sqlStr, args...); err != nil {
+		return nil, err
+	}
+	return normalizeTags(categories), nil
+}
+
+// setSubscriptionTags заменяет набор тегов подписки, создавая недостающие теги
+func setSubscriptionTags(ctx context.Context, tx *sqlx.Tx, subscriptionID uuid.UUID, tags []string) error {
+	deleteQuery := sq.Delete("subscription_tags").
+		Where(sq.Eq{"subscription_id": subscriptionID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := deleteQuery.ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		// DO UPDATE нужен, чтобы RETURNING вернул id и для существующего тега
+		upsert := sq.Insert("tags").
+			Columns("id", "tenant_id", "name").
+			Values(uuid.New(), tenant.FromContext(ctx), tag).
+			Suffix("ON CONFLICT (tenant_id, name) DO UPDATE SET name = EXCLUDED.name RETURNING id").
+			PlaceholderFormat(sq.Dollar)
+
+		sqlStr, args, err := upsert.ToSql()
+		if err != nil {
+			return err
+		}
+
+		var tagID uuid.UUID
+		if err := tx.GetContext(ctx, &tagID, sqlStr, args...); err != nil {
+			return err
+		}
+
+		link := sq.Insert("subscription_tags").
+			Columns("subscription_id", "tag_id").
+			Values(subscriptionID, tagID).
+			Suffix("ON CONFLICT DO NOTHING").
+			PlaceholderFormat(sq.Dollar)
+
+		sqlStr, args, err = link.ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagsBySubscription возвращает теги для каждой из переданных подписок
+func (s *Storage) tagsBySubscription(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]string, error) {
+	result := make(map[uuid.UUID][]string)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	query := sq.Select("st.subscription_id", "t.name").
+		From("subscription_tags st").
+		Join("tags t ON t.id = st.tag_id").
+		Where(sq.Eq{"st.subscription_id": ids}).
+		OrderBy("t.name").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		SubscriptionID uuid.UUID `db:"subscription_id"`
+		Name           string    `db:"name"`
+	}
+	if err := s.db.SelectContext(ctx, &rows, sqlStr, args...); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		result[row.SubscriptionID] = append(result[row.SubscriptionID], row.Name)
+	}
+	return result, nil
+}
+
+// loadTags заполняет поле Tags у переданных подписок
+func (s *Storage) loadTags(ctx context.Context, subs []models.Subscription) error {
+	ids := make([]uuid.UUID, 0, len(subs))
+	for _, sub := range subs {
+		ids = append(ids, sub.ID)
+	}
+
+	tagsByID, err := s.tagsBySubscription(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range subs {
+		subs[i].Tags = tagsByID[subs[i].ID]
+	}
+	return nil
+}
+
+func (s *Storage) ListTags(ctx context.Context) ([]models.Tag, error) {
+	query := sq.Select("*").
+		From("tags").
+		Where(tenantEq(ctx, "tenant_id")).
+		OrderBy("name").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []models.Tag{}
+	err = s.db.SelectContext(ctx, &tags, sqlStr, args...)
+	return tags, err
+}
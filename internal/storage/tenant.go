@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"subscribe_aggregation-main/internal/tenant"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// tenantEq ограничивает запрос организацией из контекста.
+// Каждый запрос к subscriptions, services, tags и api_keys должен содержать это условие.
+func tenantEq(ctx context.Context, column string) sq.Eq {
+	return sq.Eq{column: tenant.FromContext(ctx)}
+}
+
+// tenantDB выполняет запросы хранилища. С включенной row-level security
+// каждый запрос идет в транзакции с app.tenant_id организации из контекста,
+// и политики PostgreSQL отсекают чужие строки даже при ошибке в условиях запроса.
+type tenantDB struct {
+	*sqlx.DB
+	rowLevelSecurity bool
+}
+
+// setTenant устанавливает организацию для политик до конца транзакции
+func setTenant(ctx context.Context, tx *sqlx.Tx) error {
+	_, err := tx.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, true)", tenant.FromContext(ctx))
+	return err
+}
+
+func (d *tenantDB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
+	tx, err := d.DB.BeginTxx(ctx, opts)
+	if err != nil || !d.rowLevelSecurity {
+		return tx, err
+	}
+	if err := setTenant(ctx, tx); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// inTx выполняет запрос в отдельной транзакции с установленной организацией
+func (d *tenantDB) inTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := d.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *tenantDB) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	if !d.rowLevelSecurity {
+		return d.DB.GetContext(ctx, dest, query, args...)
+	}
+	return d.inTx(ctx, func(tx *sqlx.Tx) error {
+		return tx.GetContext(ctx, dest, query, args...)
+	})
+}
+
+func (d *tenantDB) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	if !d.rowLevelSecurity {
+		return d.DB.SelectContext(ctx, dest, query, args...)
+	}
+	return d.inTx(ctx, func(tx *sqlx.Tx) error {
+		return tx.SelectContext(ctx, dest, query, args...)
+	})
+}
+
+func (d *tenantDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if !d.rowLevelSecurity {
+		return d.DB.ExecContext(ctx, query, args...)
+	}
+	var res sql.Result
+	err := d.inTx(ctx, func(tx *sqlx.Tx) error {
+		var err error
+		res, err = tx.ExecContext(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
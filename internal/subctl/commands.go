@@ -0,0 +1,355 @@
+package subctl
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"subscribe_aggregation-main/pkg/client"
+
+	"github.com/google/uuid"
+)
+
+// usageErrorf возвращает ошибку в аргументах команды
+func usageErrorf(format string, args ...any) error {
+	return fmt.Errorf(format+": %w", append(args, errUsage)...)
+}
+
+// stringList — флаг, который можно передать несколько раз
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseFlags разбирает флаги команды и запрещает лишние аргументы
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return usageErrorf("%s", err)
+	}
+	if fs.NArg() > 0 {
+		return usageErrorf("unexpected argument %q", fs.Arg(0))
+	}
+	return nil
+}
+
+// parseID разбирает ID подписки — первый аргумент команды — и возвращает остальные аргументы
+func parseID(args []string) (uuid.UUID, []string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return uuid.Nil, nil, usageErrorf("subscription ID is required")
+	}
+	id, err := uuid.Parse(args[0])
+	if err != nil {
+		return uuid.Nil, nil, usageErrorf("invalid subscription ID %q", args[0])
+	}
+	return id, args[1:], nil
+}
+
+func parseUserID(s string) (uuid.UUID, error) {
+	if s == "" {
+		return uuid.Nil, nil
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, usageErrorf("invalid --user-id %q", s)
+	}
+	return id, nil
+}
+
+// periodFlags — фильтры пользователя, сервиса и периода, общие для list, export и sum
+type periodFlags struct {
+	userID  string
+	service string
+	start   string
+	end     string
+}
+
+func (f *periodFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.userID, "user-id", "", "user ID")
+	fs.StringVar(&f.service, "service", "", "service name")
+	fs.StringVar(&f.start, "start", "", "period start: YYYY-MM-DD, YYYY-MM or MM-YYYY")
+	fs.StringVar(&f.end, "end", "", "period end: YYYY-MM-DD, YYYY-MM or MM-YYYY")
+}
+
+func (f *periodFlags) sumOptions() (client.SumOptions, error) {
+	var opts client.SumOptions
+	var err error
+	if opts.UserID, err = parseUserID(f.userID); err != nil {
+		return opts, err
+	}
+	opts.ServiceName = f.service
+	if f.start != "" {
+		if opts.StartDate, err = client.ParseDate(f.start); err != nil {
+			return opts, usageErrorf("--start: %s", err)
+		}
+	}
+	if f.end != "" {
+		if opts.EndDate, err = client.ParseEndDate(f.end); err != nil {
+			return opts, usageErrorf("--end: %s", err)
+		}
+	}
+	return opts, nil
+}
+
+// listFlags — фильтры списка подписок
+type listFlags struct {
+	periodFlags
+	tags stringList
+}
+
+func (f *listFlags) register(fs *flag.FlagSet) {
+	f.periodFlags.register(fs)
+	fs.Var(&f.tags, "tag", "tag, subscription must have all of them (repeatable)")
+}
+
+func (f *listFlags) listOptions() (client.ListOptions, error) {
+	period, err := f.sumOptions()
+	if err != nil {
+		return client.ListOptions{}, err
+	}
+	return client.ListOptions{
+		UserID:      period.UserID,
+		ServiceName: period.ServiceName,
+		Tags:        f.tags,
+		StartDate:   period.StartDate,
+		EndDate:     period.EndDate,
+	}, nil
+}
+
+// collect загружает все страницы списка
+func collect(ctx context.Context, c *client.Client, opts client.ListOptions) ([]client.Subscription, error) {
+	var subs []client.Subscription
+	for sub, err := range c.Subscriptions(ctx, opts) {
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+func runList(ctx context.Context, e *env, args []string) error {
+	var filters listFlags
+	fs := newFlagSet("list")
+	filters.register(fs)
+	page := fs.Int("page", 1, "page number")
+	limit := fs.Int("limit", 0, "page size")
+	all := fs.Bool("all", false, "fetch all pages")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	opts, err := filters.listOptions()
+	if err != nil {
+		return err
+	}
+	opts.Page, opts.Limit = *page, *limit
+
+	var subs []client.Subscription
+	if *all {
+		subs, err = collect(ctx, e.client, opts)
+	} else {
+		subs, err = e.client.ListSubscriptions(ctx, opts)
+	}
+	if err != nil {
+		return err
+	}
+	return writeSubscriptions(e.stdout, e.format, subs)
+}
+
+func runGet(ctx context.Context, e *env, args []string) error {
+	id, rest, err := parseID(args)
+	if err != nil {
+		return err
+	}
+	if err := parseFlags(newFlagSet("get"), rest); err != nil {
+		return err
+	}
+
+	sub, err := e.client.GetSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	return writeSubscription(e.stdout, e.format, sub)
+}
+
+// openInput открывает файл или stdin для пути "-"
+func openInput(e *env, path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(e.stdin), nil
+	}
+	return os.Open(path)
+}
+
+func runCreate(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("create")
+	file := fs.String("file", "", "JSON file with the subscription, - for stdin")
+	service := fs.String("service", "", "service name")
+	price := fs.Int("price", 0, "monthly price")
+	start := fs.String("start", "", "start date")
+	end := fs.String("end", "", "end date")
+	userID := fs.String("user-id", "", "owner, defaults to the authenticated user")
+	var tags stringList
+	fs.Var(&tags, "tag", "tag (repeatable)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var sub client.Subscription
+	if *file != "" {
+		in, err := openInput(e, *file)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		if err := json.NewDecoder(in).Decode(&sub); err != nil {
+			return fmt.Errorf("decode %s: %w", *file, err)
+		}
+	} else {
+		if *service == "" || *start == "" {
+			return usageErrorf("--service and --start are required without --file")
+		}
+		var err error
+		sub = client.Subscription{ServiceName: *service, Price: *price, Tags: tags}
+		if sub.UserID, err = parseUserID(*userID); err != nil {
+			return err
+		}
+		if sub.StartDate, err = client.ParseDate(*start); err != nil {
+			return usageErrorf("--start: %s", err)
+		}
+		if *end != "" {
+			endDate, err := client.ParseEndDate(*end)
+			if err != nil {
+				return usageErrorf("--end: %s", err)
+			}
+			sub.EndDate = &endDate
+		}
+	}
+
+	created, err := e.client.CreateSubscription(ctx, sub)
+	if err != nil {
+		return err
+	}
+	return writeSubscription(e.stdout, e.format, created)
+}
+
+// runUpdate изменяет только переданные поля через PATCH. Пустой --end удаляет дату окончания
+func runUpdate(ctx context.Context, e *env, args []string) error {
+	id, rest, err := parseID(args)
+	if err != nil {
+		return err
+	}
+
+	fs := newFlagSet("update")
+	service := fs.String("service", "", "service name")
+	price := fs.Int("price", 0, "monthly price")
+	start := fs.String("start", "", "start date")
+	end := fs.String("end", "", "end date, empty to remove it")
+	version := fs.Int("version", 0, "expected subscription version (If-Match)")
+	var tags stringList
+	fs.Var(&tags, "tag", "tag, replaces all tags (repeatable)")
+	if err := parseFlags(fs, rest); err != nil {
+		return err
+	}
+
+	patch := map[string]any{}
+	var parseErr error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "service":
+			patch["service_name"] = *service
+		case "price":
+			patch["price"] = *price
+		case "start":
+			d, err := client.ParseDate(*start)
+			if err != nil {
+				parseErr = usageErrorf("--start: %s", err)
+			}
+			patch["start_date"] = d
+		case "end":
+			if *end == "" {
+				patch["end_date"] = nil
+				return
+			}
+			d, err := client.ParseEndDate(*end)
+			if err != nil {
+				parseErr = usageErrorf("--end: %s", err)
+			}
+			patch["end_date"] = d
+		case "tag":
+			patch["tags"] = tags
+		}
+	})
+	if parseErr != nil {
+		return parseErr
+	}
+	if len(patch) == 0 {
+		return usageErrorf("nothing to update")
+	}
+
+	updated, err := e.client.PatchSubscription(ctx, id, patch, *version)
+	if err != nil {
+		return err
+	}
+	return writeSubscription(e.stdout, e.format, updated)
+}
+
+func runDelete(ctx context.Context, e *env, args []string) error {
+	id, rest, err := parseID(args)
+	if err != nil {
+		return err
+	}
+	fs := newFlagSet("delete")
+	version := fs.Int("version", 0, "expected subscription version (If-Match)")
+	if err := parseFlags(fs, rest); err != nil {
+		return err
+	}
+
+	if err := e.client.DeleteSubscription(ctx, id, *version); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stderr, "deleted %s\n", id)
+	return nil
+}
+
+func runSum(ctx context.Context, e *env, args []string) error {
+	var filters periodFlags
+	fs := newFlagSet("sum")
+	filters.register(fs)
+	byCategory := fs.Bool("by-category", false, "group totals by category")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	opts, err := filters.sumOptions()
+	if err != nil {
+		return err
+	}
+
+	if *byCategory {
+		categories, err := e.client.SumSubscriptionsCostByCategory(ctx, opts)
+		if err != nil {
+			return err
+		}
+		return writeCategories(e.stdout, e.format, categories)
+	}
+
+	total, err := e.client.SumSubscriptionsCost(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return writeTotal(e.stdout, e.format, total)
+}
@@ -0,0 +1,91 @@
+package subctl
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config — адрес API и учетные данные subctl
+type Config struct {
+	URL     string
+	Token   string
+	APIKey  string
+	Tenant  string
+	Timeout time.Duration
+}
+
+// Переменные окружения и ключи файла конфигурации
+const (
+	envConfig  = "SUBCTL_CONFIG"
+	envURL     = "SUBCTL_URL"
+	envToken   = "SUBCTL_TOKEN"
+	envAPIKey  = "SUBCTL_API_KEY"
+	envTenant  = "SUBCTL_TENANT"
+	envTimeout = "SUBCTL_TIMEOUT"
+)
+
+const defaultURL = "http://localhost:8080"
+
+// defaultConfigPath — файл конфигурации по умолчанию, например ~/.config/subctl/config
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "subctl", "config")
+}
+
+// loadConfig читает настройки из файла KEY=VALUE (тот же формат, что .env),
+// затем из переменных окружения, которые главнее файла.
+// Отсутствие файла по умолчанию не ошибка, а явно указанного — ошибка.
+func loadConfig(path string, getenv func(string) string) (Config, error) {
+	explicit := path != "" || getenv(envConfig) != ""
+	if path == "" {
+		path = getenv(envConfig)
+	}
+	if path == "" {
+		path = defaultConfigPath()
+	}
+
+	values := map[string]string{}
+	if path != "" {
+		file, err := godotenv.Read(path)
+		switch {
+		case err == nil:
+			values = file
+		case explicit || !errors.Is(err, fs.ErrNotExist):
+			return Config{}, fmt.Errorf("read config %s: %w", path, err)
+		}
+	}
+
+	get := func(key string) string {
+		if v := getenv(key); v != "" {
+			return v
+		}
+		return values[key]
+	}
+
+	cfg := Config{
+		URL:    get(envURL),
+		Token:  get(envToken),
+		APIKey: get(envAPIKey),
+		Tenant: get(envTenant),
+	}
+	if cfg.URL == "" {
+		cfg.URL = defaultURL
+	}
+	if v := get(envTimeout); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s %q: %w", envTimeout, v, err)
+		}
+		cfg.Timeout = timeout
+	}
+	return cfg, nil
+}
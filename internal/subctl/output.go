@@ -0,0 +1,126 @@
+package subctl
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"subscribe_aggregation-main/pkg/client"
+)
+
+// Форматы вывода
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+func validFormat(format string) bool {
+	return format == formatTable || format == formatJSON || format == formatCSV
+}
+
+// csvHeader — колонки подписки в CSV. Тот же формат принимает import
+var csvHeader = []string{"id", "user_id", "service_name", "price", "start_date", "end_date", "tags", "version"}
+
+// tagSeparator разделяет теги в одной колонке CSV и таблицы
+const tagSeparator = ";"
+
+func optionalDate(d *client.Date) string {
+	if d == nil {
+		return ""
+	}
+	return d.String()
+}
+
+// subscriptionRow возвращает поля подписки в порядке csvHeader
+func subscriptionRow(sub client.Subscription) []string {
+	return []string{
+		sub.ID.String(),
+		sub.UserID.String(),
+		sub.ServiceName,
+		strconv.Itoa(sub.Price),
+		sub.StartDate.String(),
+		optionalDate(sub.EndDate),
+		strings.Join(sub.Tags, tagSeparator),
+		strconv.Itoa(sub.Version),
+	}
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeTable выводит строки колонками, заголовок — в верхнем регистре
+func writeTable(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	upper := make([]string, len(header))
+	for i, h := range header {
+		upper[i] = strings.ToUpper(h)
+	}
+	fmt.Fprintln(tw, strings.Join(upper, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(rows)
+	return cw.Error()
+}
+
+// writeRows выводит строки таблицей или CSV, а для JSON — значение v
+func writeRows(w io.Writer, format string, v any, header []string, rows [][]string) error {
+	switch format {
+	case formatJSON:
+		return writeJSON(w, v)
+	case formatCSV:
+		return writeCSV(w, header, rows)
+	default:
+		return writeTable(w, header, rows)
+	}
+}
+
+func writeSubscriptions(w io.Writer, format string, subs []client.Subscription) error {
+	rows := make([][]string, len(subs))
+	for i, sub := range subs {
+		rows[i] = subscriptionRow(sub)
+	}
+	if subs == nil {
+		subs = []client.Subscription{}
+	}
+	return writeRows(w, format, subs, csvHeader, rows)
+}
+
+func writeSubscription(w io.Writer, format string, sub *client.Subscription) error {
+	return writeRows(w, format, sub, csvHeader, [][]string{subscriptionRow(*sub)})
+}
+
+func writeTotal(w io.Writer, format string, total int64) error {
+	return writeRows(w, format, map[string]int64{"total_price": total},
+		[]string{"total_price"}, [][]string{{strconv.FormatInt(total, 10)}})
+}
+
+// writeCategories выводит суммы по категориям, отсортированные по названию
+func writeCategories(w io.Writer, format string, categories map[string]int64) error {
+	names := make([]string, 0, len(categories))
+	for name := range categories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	rows := make([][]string, len(names))
+	for i, name := range names {
+		rows[i] = []string{name, strconv.FormatInt(categories[name], 10)}
+	}
+	return writeRows(w, format, map[string]any{"categories": categories}, []string{"category", "total_price"}, rows)
+}
@@ -0,0 +1,131 @@
+// Package subctl — командная строка для API подписок. Команды вызывают REST API
+// через pkg/client и выводят результат таблицей, JSON или CSV.
+package subctl
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"subscribe_aggregation-main/pkg/client"
+)
+
+// env — общее окружение команд
+type env struct {
+	client *client.Client
+	format string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, e *env, args []string) error
+}
+
+var commands = map[string]command{
+	"list":   {"list [--user-id ID] [--service NAME] [--tag TAG]... [--start DATE] [--end DATE] [--page N] [--limit N] [--all]", runList},
+	"get":    {"get ID", runGet},
+	"create": {"create (--file FILE | --service NAME --price N --start DATE [--end DATE] [--user-id ID] [--tag TAG]...)", runCreate},
+	"update": {"update ID [--service NAME] [--price N] [--start DATE] [--end DATE|\"\"] [--tag TAG]... [--version N]", runUpdate},
+	"delete": {"delete ID [--version N]", runDelete},
+	"sum":    {"sum [--user-id ID] [--service NAME] [--start DATE] [--end DATE] [--by-category]", runSum},
+	"import": {"import --file FILE [--format csv|json]", runImport},
+	"export": {"export [--file FILE] [--format csv|json] [list filters]", runExport},
+}
+
+// errUsage — ошибка в аргументах, после нее печатается справка
+var errUsage = errors.New("usage error")
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: subctl [--config FILE] [--url URL] [-o table|json|csv] COMMAND [ARGS]")
+	fmt.Fprintln(w, "\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(w, "  subctl "+commands[name].usage)
+	}
+	fmt.Fprintln(w, "\nConfiguration: SUBCTL_URL, SUBCTL_TOKEN, SUBCTL_API_KEY, SUBCTL_TENANT and SUBCTL_TIMEOUT")
+	fmt.Fprintln(w, "from the environment or KEY=VALUE lines in the config file (default ~/.config/subctl/config, or SUBCTL_CONFIG).")
+}
+
+// newClient создает клиент API по конфигурации
+func newClient(cfg Config) (*client.Client, error) {
+	opts := []client.Option{client.WithUserAgent("subctl")}
+	if cfg.Timeout > 0 {
+		opts = append(opts, client.WithTimeout(cfg.Timeout))
+	}
+	if cfg.Token != "" {
+		opts = append(opts, client.WithBearerToken(cfg.Token))
+	}
+	if cfg.APIKey != "" {
+		opts = append(opts, client.WithAPIKey(cfg.APIKey))
+	}
+	if cfg.Tenant != "" {
+		opts = append(opts, client.WithTenant(cfg.Tenant))
+	}
+	return client.New(cfg.URL, opts...)
+}
+
+// Run выполняет команду subctl и возвращает код завершения процесса
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer, getenv func(string) string) int {
+	global := flag.NewFlagSet("subctl", flag.ContinueOnError)
+	global.SetOutput(io.Discard)
+	configPath := global.String("config", "", "config file")
+	url := global.String("url", "", "API base URL")
+	format := global.String("o", formatTable, "output format: table, json or csv")
+
+	if err := global.Parse(args); err != nil || global.NArg() == 0 {
+		if err != nil && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(stderr, "subctl:", err)
+		}
+		usage(stderr)
+		return 2
+	}
+	if !validFormat(*format) {
+		fmt.Fprintf(stderr, "subctl: unknown output format %q\n", *format)
+		return 2
+	}
+
+	name := global.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "subctl: unknown command %q\n", name)
+		usage(stderr)
+		return 2
+	}
+
+	cfg, err := loadConfig(*configPath, getenv)
+	if err != nil {
+		fmt.Fprintln(stderr, "subctl:", err)
+		return 1
+	}
+	if *url != "" {
+		cfg.URL = *url
+	}
+	c, err := newClient(cfg)
+	if err != nil {
+		fmt.Fprintln(stderr, "subctl:", err)
+		return 1
+	}
+
+	e := &env{client: c, format: *format, stdin: stdin, stdout: stdout, stderr: stderr}
+	if err := cmd.run(ctx, e, global.Args()[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(stderr, "subctl:", strings.TrimSuffix(err.Error(), ": "+errUsage.Error()))
+			fmt.Fprintln(stderr, "Usage: subctl "+cmd.usage)
+			return 2
+		}
+		fmt.Fprintln(stderr, "subctl:", err)
+		return 1
+	}
+	return 0
+}
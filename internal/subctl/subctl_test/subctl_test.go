@@ -0,0 +1,186 @@
+package subctl_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"subscribe_aggregation-main/internal/subctl"
+	"subscribe_aggregation-main/pkg/client"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPI хранит подписки в памяти и запоминает Idempotency-Key созданных подписок
+type fakeAPI struct {
+	mu      sync.Mutex
+	subs    []client.Subscription
+	byKey   map[string]client.Subscription
+	patches []map[string]any
+	auth    []string
+}
+
+func (f *fakeAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.auth = append(f.auth, r.Header.Get("X-API-Key"))
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		page, limit = max(page, 1), max(limit, 10)
+		from := min((page-1)*limit, len(f.subs))
+		json.NewEncoder(w).Encode(f.subs[from:min(from+limit, len(f.subs))])
+	})
+	mux.HandleFunc("POST /subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		key := r.Header.Get("Idempotency-Key")
+		if sub, ok := f.byKey[key]; ok {
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(sub)
+			return
+		}
+		var sub client.Subscription
+		json.NewDecoder(r.Body).Decode(&sub)
+		sub.ID, sub.Version = uuid.New(), 1
+		f.subs = append(f.subs, sub)
+		f.byKey[key] = sub
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(sub)
+	})
+	mux.HandleFunc("PATCH /subscriptions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var patch map[string]any
+		json.NewDecoder(r.Body).Decode(&patch)
+		f.mu.Lock()
+		f.patches = append(f.patches, patch)
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(client.Subscription{ID: uuid.MustParse(r.PathValue("id")), ServiceName: "patched"})
+	})
+	mux.HandleFunc("GET /subscriptions/sum", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]int64{"total_price": 1200})
+	})
+	return mux
+}
+
+func setup(t *testing.T) (*fakeAPI, func(args ...string) (int, string, string)) {
+	t.Helper()
+	api := &fakeAPI{byKey: map[string]client.Subscription{}}
+	srv := httptest.NewServer(api.handler())
+	t.Cleanup(srv.Close)
+
+	getenv := func(key string) string {
+		return map[string]string{
+			"SUBCTL_URL":     srv.URL,
+			"SUBCTL_API_KEY": "sak_test",
+		}[key]
+	}
+	run := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		// Файл конфигурации по умолчанию не должен влиять на тест
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		t.Setenv("HOME", t.TempDir())
+		code := subctl.Run(context.Background(), args, strings.NewReader(""), &stdout, &stderr, getenv)
+		return code, stdout.String(), stderr.String()
+	}
+	return api, run
+}
+
+func TestSubctl(t *testing.T) {
+	api, run := setup(t)
+
+	code, out, errOut := run("-o", "json", "create", "--service", "Netflix", "--price", "500", "--start", "2025-01", "--tag", "video")
+	require.Equal(t, 0, code, errOut)
+	var created client.Subscription
+	require.NoError(t, json.Unmarshal([]byte(out), &created))
+	assert.Equal(t, "2025-01-01", created.StartDate.String())
+	assert.Equal(t, []string{"video"}, created.Tags)
+
+	code, out, _ = run("list")
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "SERVICE_NAME")
+	assert.Contains(t, out, "Netflix")
+	assert.Equal(t, "sak_test", api.auth[0])
+
+	code, _, errOut = run("update", created.ID.String(), "--price", "700", "--end", "")
+	require.Equal(t, 0, code, errOut)
+	assert.Equal(t, map[string]any{"price": float64(700), "end_date": nil}, api.patches[0])
+
+	code, out, _ = run("-o", "csv", "sum", "--start", "01-2025")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "total_price\n1200\n", out)
+
+	code, _, errOut = run("get", "not-a-uuid")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, "invalid subscription ID")
+
+	code, _, _ = run("unknown")
+	assert.Equal(t, 2, code)
+}
+
+func TestSubctlImportExport(t *testing.T) {
+	api, run := setup(t)
+	dir := t.TempDir()
+
+	input := filepath.Join(dir, "subs.csv")
+	require.NoError(t, os.WriteFile(input, []byte(
+		"service_name,price,start_date,end_date,tags\n"+
+			"Netflix,500,2025-01-01,,video;hd\n"+
+			"Spotify,200,2025-02,2025-12,music\n"+
+			"Broken,abc,2025-01-01,,\n"), 0o600))
+
+	code, _, errOut := run("import", "--file", input)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "line 4")
+
+	require.NoError(t, os.WriteFile(input, []byte(
+		"service_name,price,start_date,end_date,tags\n"+
+			"Netflix,500,2025-01-01,,video;hd\n"+
+			"Spotify,200,2025-02,2025-12,music\n"), 0o600))
+
+	code, _, errOut = run("import", "--file", input)
+	require.Equal(t, 0, code, errOut)
+	assert.Contains(t, errOut, "imported 2 of 2")
+
+	// Повторный импорт того же файла не создает дублей
+	code, _, _ = run("import", "--file", input)
+	require.Equal(t, 0, code)
+	require.Len(t, api.subs, 2)
+	assert.Equal(t, "2025-12-31", api.subs[1].EndDate.String())
+
+	output := filepath.Join(dir, "export.csv")
+	code, _, errOut = run("export", "--file", output)
+	require.Equal(t, 0, code, errOut)
+
+	exported, err := os.ReadFile(output)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(exported)), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "id,user_id,service_name,price,start_date,end_date,tags,version", lines[0])
+	assert.Contains(t, lines[1], "Netflix,500,2025-01-01,,video;hd,1")
+}
+
+func TestSubctlConfigFile(t *testing.T) {
+	_, run := setup(t)
+
+	config := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(config, []byte("SUBCTL_URL=http://127.0.0.1:1\nSUBCTL_TIMEOUT=nope\n"), 0o600))
+
+	code, _, errOut := run("--config", config, "list")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "invalid SUBCTL_TIMEOUT")
+
+	code, _, errOut = run("--config", filepath.Join(t.TempDir(), "missing"), "list")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "read config")
+}
@@ -0,0 +1,201 @@
+package subctl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"subscribe_aggregation-main/pkg/client"
+)
+
+// fileFormat возвращает формат файла: явно заданный или по расширению, по умолчанию CSV
+func fileFormat(format, path string) (string, error) {
+	if format == "" {
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			return formatJSON, nil
+		}
+		return formatCSV, nil
+	}
+	if format != formatCSV && format != formatJSON {
+		return "", usageErrorf("unknown --format %q, expected csv or json", format)
+	}
+	return format, nil
+}
+
+// readCSV читает подписки из CSV с заголовком в формате export.
+// Колонки id и version игнорируются: при импорте подписки создаются заново.
+func readCSV(r io.Reader) ([]client.Subscription, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"service_name", "price", "start_date"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV column %s is required", required)
+		}
+	}
+
+	var subs []client.Subscription
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return subs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		sub, err := parseRecord(record, columns)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		subs = append(subs, sub)
+	}
+}
+
+func parseRecord(record []string, columns map[string]int) (client.Subscription, error) {
+	get := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	sub := client.Subscription{ServiceName: get("service_name")}
+	var err error
+	if sub.Price, err = strconv.Atoi(get("price")); err != nil {
+		return sub, fmt.Errorf("invalid price %q", get("price"))
+	}
+	if sub.StartDate, err = client.ParseDate(get("start_date")); err != nil {
+		return sub, err
+	}
+	if s := get("end_date"); s != "" {
+		end, err := client.ParseEndDate(s)
+		if err != nil {
+			return sub, err
+		}
+		sub.EndDate = &end
+	}
+	if sub.UserID, err = parseUserID(get("user_id")); err != nil {
+		return sub, fmt.Errorf("invalid user_id %q", get("user_id"))
+	}
+	if s := get("tags"); s != "" {
+		sub.Tags = strings.Split(s, tagSeparator)
+	}
+	return sub, nil
+}
+
+// importKey — Idempotency-Key строки импорта. Зависит от номера и содержимого строки,
+// поэтому повторный импорт того же файла в пределах IDEMPOTENCY_TTL не создает дублей.
+func importKey(i int, sub client.Subscription) string {
+	body, _ := json.Marshal(sub)
+	sum := sha256.Sum256(append([]byte(strconv.Itoa(i)+":"), body...))
+	return "subctl-import-" + hex.EncodeToString(sum[:16])
+}
+
+// runImport создает подписки из CSV или JSON файла. Ошибки отдельных строк
+// выводятся в stderr, импорт продолжается со следующей строки.
+func runImport(ctx context.Context, e *env, args []string) error {
+	fs := newFlagSet("import")
+	file := fs.String("file", "", "CSV or JSON file, - for stdin")
+	format := fs.String("format", "", "file format: csv or json (default by extension, csv for stdin)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return usageErrorf("--file is required")
+	}
+	fileFmt, err := fileFormat(*format, *file)
+	if err != nil {
+		return err
+	}
+
+	in, err := openInput(e, *file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var subs []client.Subscription
+	if fileFmt == formatJSON {
+		if err := json.NewDecoder(in).Decode(&subs); err != nil {
+			return fmt.Errorf("decode %s: %w", *file, err)
+		}
+	} else if subs, err = readCSV(in); err != nil {
+		return err
+	}
+
+	var created []client.Subscription
+	failed := 0
+	for i, sub := range subs {
+		result, err := e.client.CreateSubscriptionWithKey(ctx, sub, importKey(i, sub))
+		if err != nil {
+			fmt.Fprintf(e.stderr, "record %d (%s): %v\n", i+1, sub.ServiceName, err)
+			failed++
+			continue
+		}
+		created = append(created, *result)
+	}
+
+	if err := writeSubscriptions(e.stdout, e.format, created); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stderr, "imported %d of %d subscriptions\n", len(created), len(subs))
+	if failed > 0 {
+		return fmt.Errorf("%d subscriptions failed to import", failed)
+	}
+	return nil
+}
+
+// runExport выгружает все подписки по фильтрам в CSV (формат import) или JSON
+func runExport(ctx context.Context, e *env, args []string) error {
+	var filters listFlags
+	fs := newFlagSet("export")
+	filters.register(fs)
+	file := fs.String("file", "", "output file, stdout by default")
+	format := fs.String("format", "", "file format: csv or json (default by extension, csv for stdout)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	fileFmt, err := fileFormat(*format, *file)
+	if err != nil {
+		return err
+	}
+
+	opts, err := filters.listOptions()
+	if err != nil {
+		return err
+	}
+	subs, err := collect(ctx, e.client, opts)
+	if err != nil {
+		return err
+	}
+
+	out := e.stdout
+	if *file != "" && *file != "-" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := writeSubscriptions(out, fileFmt, subs); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stderr, "exported %d subscriptions\n", len(subs))
+	return nil
+}
@@ -0,0 +1,34 @@
+package tenant
+
+import (
+	"context"
+	"regexp"
+)
+
+// Default — организация для данных, созданных до разделения на организации,
+// и для установок с одной организацией
+const Default = "default"
+
+// idPattern — строчные латинские буквы, цифры, дефис и подчеркивание, как в slug
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Valid проверяет формат идентификатора организации
+func Valid(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// ctxKeyTenant — ключ организации в контексте запроса
+type ctxKeyTenant struct{}
+
+// NewContext возвращает контекст с организацией, данными которой ограничен запрос
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKeyTenant{}, id)
+}
+
+// FromContext возвращает организацию из контекста или Default, если она не задана
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(ctxKeyTenant{}).(string); ok && id != "" {
+		return id
+	}
+	return Default
+}
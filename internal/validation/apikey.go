@@ -0,0 +1,22 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"subscribe_aggregation-main/internal/auth"
+	"subscribe_aggregation-main/internal/models"
+)
+
+// MaxAPIKeyNameLength совпадает с размером колонки api_keys.name
+const MaxAPIKeyNameLength = 255
+
+// APIKey проверяет название и права ключа при выпуске
+func APIKey(key *models.APIKey) error {
+	violations := Field("name", strings.TrimSpace(key.Name), Required[string](), MaxLength(MaxAPIKeyNameLength))
+	violations = append(violations, Field("scopes", len(key.Scopes), Required[int]())...)
+	for i, scope := range key.Scopes {
+		violations = append(violations, Field(fmt.Sprintf("scopes[%d]", i), scope, OneOf(auth.Scopes...))...)
+	}
+	return Validate(violations)
+}
@@ -0,0 +1,80 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"subscribe_aggregation-main/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxServiceNameLength совпадает с размером колонки service_name
+	MaxServiceNameLength = 255
+	// MaxCategoryLength совпадает с размером колонки services.category
+	MaxCategoryLength = 100
+	// MaxPrice — верхняя граница цены в месяц, защищает от опечаток и переполнения суммы
+	MaxPrice = 1_000_000
+)
+
+// serviceNamePattern — буквы, цифры, пробелы и знаки, встречающиеся в названиях сервисов
+var serviceNamePattern = regexp.MustCompile(`^[\p{L}\p{N} .,:;!?&+'()/_-]+$`)
+
+const serviceNameCharset = "letters, digits, spaces and . , : ; ! ? & + ' ( ) / _ -"
+
+// serviceName проверяет название сервиса без учета пробелов по краям
+func serviceName(field, name string) []Violation {
+	return Field(field, strings.TrimSpace(name),
+		Required[string](),
+		MaxLength(MaxServiceNameLength),
+		Matches(serviceNamePattern, serviceNameCharset),
+	)
+}
+
+// NewSubscription проверяет подписку при создании: кроме общих правил требуется владелец
+func NewSubscription(sub *models.Subscription) error {
+	return Validate(
+		Field("user_id", sub.UserID, Required[uuid.UUID]()),
+		subscription(sub),
+	)
+}
+
+// Subscription проверяет подписку при обновлении
+func Subscription(sub *models.Subscription) error {
+	return Validate(subscription(sub))
+}
+
+// subscription возвращает нарушения общих для создания и обновления правил
+func subscription(sub *models.Subscription) []Violation {
+	start := sub.StartDate.ToTime()
+
+	violations := serviceName("service_name", sub.ServiceName)
+	violations = append(violations, Field("price", sub.Price, Min(1), Max(MaxPrice))...)
+	violations = append(violations, Field("start_date", start, Required[time.Time]())...)
+	if sub.EndDate != nil && !start.IsZero() {
+		violations = append(violations, Field("end_date", sub.EndDate.ToTime(), NotBefore("start_date", start))...)
+	}
+	for i, phase := range sub.Phases {
+		prefix := fmt.Sprintf("phases[%d].", i)
+		violations = append(violations, Field(prefix+"months", phase.Months, Min(1))...)
+		violations = append(violations, Field(prefix+"price", phase.Price, Min(0), Max(MaxPrice))...)
+	}
+	for i, tag := range sub.Tags {
+		violations = append(violations, Field(fmt.Sprintf("tags[%d]", i), tag, MaxLength(MaxCategoryLength))...)
+	}
+	return violations
+}
+
+// Service проверяет запись каталога сервисов
+func Service(svc *models.Service) error {
+	violations := serviceName("name", svc.Name)
+	violations = append(violations, Field("default_price", svc.DefaultPrice, Min(0), Max(MaxPrice))...)
+	violations = append(violations, Field("category", svc.Category, MaxLength(MaxCategoryLength))...)
+	for i, alias := range svc.Aliases {
+		violations = append(violations, Field(fmt.Sprintf("aliases[%d]", i), alias, MaxLength(MaxServiceNameLength))...)
+	}
+	return Validate(violations)
+}
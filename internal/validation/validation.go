@@ -0,0 +1,138 @@
+package validation
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Коды нарушений, которые попадают в ответ API
+const (
+	CodeRequired = "required"
+	CodeMin      = "min"
+	CodeMax      = "max"
+	CodeTooLong  = "too_long"
+	CodeCharset  = "invalid_charset"
+	CodeOrder    = "invalid_order"
+	CodeOneOf    = "not_allowed"
+)
+
+// Violation — нарушение правила проверки для одного поля
+type Violation struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+// Errors — все нарушения, найденные при проверке
+type Errors []Violation
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, v := range e {
+		messages = append(messages, v.Message)
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// Rule — правило проверки значения поля. Возвращает nil, если значение корректно
+type Rule[T any] func(field string, value T) *Violation
+
+// Field проверяет значение поля по правилам в указанном порядке.
+// После первого нарушения остальные правила поля не проверяются,
+// чтобы не сообщать, например, о минимальной цене для незаданной цены.
+func Field[T any](field string, value T, rules ...Rule[T]) []Violation {
+	for _, rule := range rules {
+		if v := rule(field, value); v != nil {
+			return []Violation{*v}
+		}
+	}
+	return nil
+}
+
+// Validate собирает нарушения всех полей и возвращает Errors или nil
+func Validate(fields ...[]Violation) error {
+	var errs Errors
+	for _, violations := range fields {
+		errs = append(errs, violations...)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Required требует ненулевое значение
+func Required[T comparable]() Rule[T] {
+	return func(field string, value T) *Violation {
+		var zero T
+		if value == zero {
+			return &Violation{Field: field, Code: CodeRequired, Message: field + " is required"}
+		}
+		return nil
+	}
+}
+
+// Min требует значение не меньше min
+func Min[T cmp.Ordered](min T) Rule[T] {
+	return func(field string, value T) *Violation {
+		if value < min {
+			return &Violation{Field: field, Code: CodeMin, Message: fmt.Sprintf("%s must be at least %v", field, min)}
+		}
+		return nil
+	}
+}
+
+// Max требует значение не больше max
+func Max[T cmp.Ordered](max T) Rule[T] {
+	return func(field string, value T) *Violation {
+		if value > max {
+			return &Violation{Field: field, Code: CodeMax, Message: fmt.Sprintf("%s must be at most %v", field, max)}
+		}
+		return nil
+	}
+}
+
+// MaxLength ограничивает длину строки в символах
+func MaxLength(n int) Rule[string] {
+	return func(field string, value string) *Violation {
+		if utf8.RuneCountInString(value) > n {
+			return &Violation{Field: field, Code: CodeTooLong, Message: fmt.Sprintf("%s must be at most %d characters", field, n)}
+		}
+		return nil
+	}
+}
+
+// Matches требует, чтобы строка соответствовала шаблону; allowed описывает допустимые символы
+func Matches(pattern *regexp.Regexp, allowed string) Rule[string] {
+	return func(field string, value string) *Violation {
+		if !pattern.MatchString(value) {
+			return &Violation{Field: field, Code: CodeCharset, Message: field + " may contain only " + allowed}
+		}
+		return nil
+	}
+}
+
+// NotBefore требует дату не раньше даты поля other
+func NotBefore(other string, date time.Time) Rule[time.Time] {
+	return func(field string, value time.Time) *Violation {
+		if value.Before(date) {
+			return &Violation{Field: field, Code: CodeOrder, Message: field + " must not be before " + other}
+		}
+		return nil
+	}
+}
+
+// OneOf требует одно из допустимых значений
+func OneOf(allowed ...string) Rule[string] {
+	return func(field string, value string) *Violation {
+		if !slices.Contains(allowed, value) {
+			return &Violation{Field: field, Code: CodeOneOf, Message: field + " must be one of " + strings.Join(allowed, ", ")}
+		}
+		return nil
+	}
+}
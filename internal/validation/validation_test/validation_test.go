@@ -0,0 +1,131 @@
+package validation_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"subscribe_aggregation-main/internal/models"
+	"subscribe_aggregation-main/internal/validation"
+
+	"github.com/google/uuid"
+)
+
+func date(year int, month time.Month, day int) models.DataOnly {
+	return models.DataOnly(time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+}
+
+func fieldsOf(t *testing.T, err error) []string {
+	t.Helper()
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected validation.Errors, got %v", err)
+	}
+	fields := make([]string, 0, len(errs))
+	for _, v := range errs {
+		fields = append(fields, v.Field)
+	}
+	return fields
+}
+
+func TestNewSubscription(t *testing.T) {
+	end := models.EndDate(date(2024, 1, 1))
+	valid := models.Subscription{
+		UserID:      uuid.New(),
+		ServiceName: "Yandex Plus",
+		Price:       299,
+		StartDate:   date(2024, 1, 1),
+		EndDate:     &end,
+	}
+
+	if err := validation.NewSubscription(&valid); err != nil {
+		t.Fatalf("NewSubscription() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(sub *models.Subscription)
+		want   []string
+	}{
+		{
+			name:   "empty",
+			modify: func(sub *models.Subscription) { *sub = models.Subscription{} },
+			want:   []string{"user_id", "service_name", "price", "start_date"},
+		},
+		{
+			name: "end_before_start",
+			modify: func(sub *models.Subscription) {
+				end := models.EndDate(date(2023, 12, 31))
+				sub.EndDate = &end
+			},
+			want: []string{"end_date"},
+		},
+		{
+			name: "price_bounds_and_long_name",
+			modify: func(sub *models.Subscription) {
+				sub.Price = validation.MaxPrice + 1
+				sub.ServiceName = strings.Repeat("a", validation.MaxServiceNameLength+1)
+			},
+			want: []string{"service_name", "price"},
+		},
+		{
+			name: "invalid_phases",
+			modify: func(sub *models.Subscription) {
+				sub.Phases = []models.PricePhase{{Months: 0, Price: 0}, {Months: 1, Price: -1}}
+			},
+			want: []string{"phases[0].months", "phases[1].price"},
+		},
+		{
+			name:   "charset",
+			modify: func(sub *models.Subscription) { sub.ServiceName = "Netflix\x00" },
+			want:   []string{"service_name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := valid
+			tt.modify(&sub)
+			got := fieldsOf(t, validation.NewSubscription(&sub))
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("NewSubscription() fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscriptionDoesNotRequireUser(t *testing.T) {
+	sub := models.Subscription{ServiceName: "Netflix", Price: 100, StartDate: date(2024, 1, 1)}
+	if err := validation.Subscription(&sub); err != nil {
+		t.Errorf("Subscription() unexpected error: %v", err)
+	}
+}
+
+func TestService(t *testing.T) {
+	svc := models.Service{Name: " ", DefaultPrice: -1}
+	got := fieldsOf(t, validation.Service(&svc))
+	want := []string{"name", "default_price"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Service() fields = %v, want %v", got, want)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	valid := models.APIKey{Name: "billing-export", Scopes: []string{"subscriptions:read", "reports:read"}}
+	if err := validation.APIKey(&valid); err != nil {
+		t.Errorf("APIKey() unexpected error: %v", err)
+	}
+
+	key := models.APIKey{Name: "", Scopes: []string{"subscriptions:read", "admin"}}
+	got := fieldsOf(t, validation.APIKey(&key))
+	want := []string{"name", "scopes[1]"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("APIKey() fields = %v, want %v", got, want)
+	}
+
+	got = fieldsOf(t, validation.APIKey(&models.APIKey{Name: "job"}))
+	if strings.Join(got, ",") != "scopes" {
+		t.Errorf("APIKey() fields = %v, want [scopes]", got)
+	}
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// Права API ключей
+const (
+	ScopeSubscriptionsRead  = "subscriptions:read"
+	ScopeSubscriptionsWrite = "subscriptions:write"
+	ScopeReportsRead        = "reports:read"
+)
+
+func apiKeyPath(id uuid.UUID) string {
+	return "/api-keys/" + id.String()
+}
+
+// ListAPIKeys возвращает ключи организации без открытых значений. Требует роль администратора
+func (c *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var keys []APIKey
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api-keys", retryable: true}, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CreateAPIKey выпускает ключ. Открытый ключ в поле Key возвращается только здесь
+// и в RotateAPIKey. Требует роль администратора
+func (c *Client) CreateAPIKey(ctx context.Context, name string, scopes []string) (*APIKey, error) {
+	body := struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}{name, scopes}
+
+	var key APIKey
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api-keys", body: body}, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RevokeAPIKey отзывает ключ. Требует роль администратора
+func (c *Client) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: apiKeyPath(id)}, nil)
+}
+
+// RotateAPIKey выпускает новое значение ключа с теми же правами. Требует роль администратора
+func (c *Client) RotateAPIKey(ctx context.Context, id uuid.UUID) (*APIKey, error) {
+	var key APIKey
+	if err := c.do(ctx, request{method: http.MethodPost, path: apiKeyPath(id) + "/rotate"}, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
@@ -0,0 +1,285 @@
+// Package client — типизированный клиент REST API подписок для других сервисов.
+// Клиент повторяет запросы при временных ошибках, передает учетные данные
+// через настраиваемые хуки, а request ID и traceparent — из контекста вызова,
+// и возвращает ошибки сервера как *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"subscribe_aggregation-main/pkg/logging"
+)
+
+// RetryPolicy — настройки повторов. Повторяются запросы, которые безопасно выполнить
+// дважды (GET, PUT, DELETE и создание подписки с Idempotency-Key), при сетевой ошибке
+// и ответах 429, 502, 503 и 504.
+type RetryPolicy struct {
+	// MaxAttempts — наибольшее число попыток, включая первую. 1 отключает повторы
+	MaxAttempts int
+	// MinBackoff и MaxBackoff — пределы экспоненциальной паузы между попытками.
+	// Retry-After из ответа сервера главнее.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy — повторы по умолчанию
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, MinBackoff: 100 * time.Millisecond, MaxBackoff: 2 * time.Second}
+
+// DefaultTimeout — ограничение времени одной попытки по умолчанию
+const DefaultTimeout = 10 * time.Second
+
+// RequestHook изменяет запрос перед каждой попыткой, например добавляет токен
+type RequestHook func(req *http.Request) error
+
+// Client — клиент API подписок. Безопасен для одновременного использования
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	retry      RetryPolicy
+	hooks      []RequestHook
+	userAgent  string
+}
+
+// Option настраивает Client
+type Option func(*Client)
+
+// WithHTTPClient задает HTTP клиент, например с собственным транспортом.
+// Его Timeout не меняется, если WithTimeout не передан после этой опции.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithTimeout ограничивает время одной попытки
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		hc := *c.httpClient
+		hc.Timeout = d
+		c.httpClient = &hc
+	}
+}
+
+// WithRetryPolicy задает настройки повторов
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) { c.retry = p }
+}
+
+// WithRequestHook добавляет хук, вызываемый перед каждой попыткой
+func WithRequestHook(hook RequestHook) Option {
+	return func(c *Client) { c.hooks = append(c.hooks, hook) }
+}
+
+// WithBearerToken передает JWT в заголовке Authorization
+func WithBearerToken(token string) Option {
+	return WithTokenSource(func(context.Context) (string, error) { return token, nil })
+}
+
+// WithTokenSource получает JWT перед каждой попыткой, например чтобы обновлять истекающий токен
+func WithTokenSource(source func(ctx context.Context) (string, error)) Option {
+	return WithRequestHook(func(req *http.Request) error {
+		token, err := source(req.Context())
+		if err != nil {
+			return fmt.Errorf("get token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// WithAPIKey передает API ключ сервиса в заголовке X-API-Key
+func WithAPIKey(key string) Option {
+	return WithRequestHook(func(req *http.Request) error {
+		req.Header.Set("X-API-Key", key)
+		return nil
+	})
+}
+
+// WithTenant выбирает организацию заголовком X-Tenant-ID
+func WithTenant(id string) Option {
+	return WithRequestHook(func(req *http.Request) error {
+		req.Header.Set("X-Tenant-ID", id)
+		return nil
+	})
+}
+
+// WithUserAgent задает заголовок User-Agent
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// New создает клиент для API по адресу baseURL, например http://subscriptions:8080
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("parse base URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("base URL %q must be absolute", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		retry:      DefaultRetryPolicy,
+		userAgent:  "subscriptions-go-client",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c, nil
+}
+
+// request — параметры одного вызова API
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	header http.Header
+	// retryable — запрос безопасно повторить
+	retryable bool
+}
+
+// do выполняет запрос с повторами и декодирует JSON ответа в out, если out не nil.
+// Ответы 304 и 204 не декодируются.
+func (c *Client) do(ctx context.Context, r request, out any) error {
+	var body []byte
+	if r.body != nil {
+		var err error
+		if body, err = json.Marshal(r.body); err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	u := *c.baseURL
+	u.Path += r.path
+	u.RawQuery = r.query.Encode()
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, r, u.String(), body)
+		if err == nil && resp.StatusCode < 300 {
+			defer resp.Body.Close()
+			return decodeBody(resp, out)
+		}
+
+		var retryAfter time.Duration
+		if err == nil {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			err = decodeError(resp)
+			resp.Body.Close()
+		}
+
+		if !r.retryable || attempt >= c.retry.MaxAttempts || !shouldRetry(ctx, resp, err) {
+			return err
+		}
+		if waitErr := sleep(ctx, c.backoff(attempt, retryAfter)); waitErr != nil {
+			return err
+		}
+	}
+}
+
+// send выполняет одну попытку запроса
+func (c *Client) send(ctx context.Context, r request, u string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	// Запрос сервиса-клиента и вызванный им запрос API находятся в логах по одному request ID
+	logging.SetRequestHeaders(ctx, req.Header)
+
+	for _, hook := range c.hooks {
+		if err := hook(req); err != nil {
+			return nil, err
+		}
+	}
+	return c.httpClient.Do(req)
+}
+
+// decodeBody декодирует ответ в out
+func decodeBody(resp *http.Response, out any) error {
+	if out == nil || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// shouldRetry решает, стоит ли повторить попытку
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if resp == nil {
+		// Сетевая ошибка; ошибки хуков и построения запроса не повторяются
+		var urlErr *url.Error
+		return errors.As(err, &urlErr)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff возвращает паузу перед следующей попыткой: Retry-After сервера
+// или экспоненциальную паузу со случайным разбросом
+func (c *Client) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	d := c.retry.MinBackoff << (attempt - 1)
+	if d <= 0 || d > c.retry.MaxBackoff {
+		d = c.retry.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// parseRetryAfter разбирает Retry-After в секундах
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,227 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"subscribe_aggregation-main/pkg/client"
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastRetries — повторы без заметных пауз для тестов
+var fastRetries = client.WithRetryPolicy(client.RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+func newClient(t *testing.T, handler http.Handler, opts ...client.Option) *client.Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	c, err := client.New(srv.URL, append([]client.Option{fastRetries}, opts...)...)
+	require.NoError(t, err)
+	return c
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestClientSubscriptions(t *testing.T) {
+	userID := uuid.New()
+	subs := make([]client.Subscription, 5)
+	for i := range subs {
+		subs[i] = client.Subscription{ID: uuid.New(), UserID: userID, ServiceName: "svc" + strconv.Itoa(i), Price: 100, Version: 1}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, "acme", r.Header.Get("X-Tenant-ID"))
+		assert.NotEmpty(t, r.Header.Get("Idempotency-Key"))
+
+		var sub client.Subscription
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&sub))
+		assert.Equal(t, "2025-01-01", sub.StartDate.String())
+		sub.ID, sub.Version = uuid.New(), 1
+		writeJSON(w, http.StatusCreated, sub)
+	})
+	mux.HandleFunc("GET /subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, userID.String(), r.URL.Query().Get("user_id"))
+		assert.Equal(t, []string{"video", "hd"}, r.URL.Query()["tag"])
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		from := min((page-1)*limit, len(subs))
+		writeJSON(w, http.StatusOK, subs[from:min(from+limit, len(subs))])
+	})
+	mux.HandleFunc("PUT /subscriptions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Match") != `"1"` {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusPreconditionFailed)
+			w.Write([]byte(`{"status":412,"code":"precondition_failed","detail":"subscription was modified"}`))
+			return
+		}
+		var sub client.Subscription
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&sub))
+		sub.Version++
+		writeJSON(w, http.StatusOK, sub)
+	})
+	mux.HandleFunc("GET /subscriptions/sum", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("group_by") == "category" {
+			writeJSON(w, http.StatusOK, map[string]any{"categories": map[string]int64{"video": 300}})
+			return
+		}
+		assert.Equal(t, "2025-01-01", r.URL.Query().Get("start_date"))
+		writeJSON(w, http.StatusOK, map[string]int64{"total_price": 500})
+	})
+
+	c := newClient(t, mux, client.WithBearerToken("token"), client.WithTenant("acme"))
+	ctx := context.Background()
+
+	created, err := c.CreateSubscription(ctx, client.Subscription{ServiceName: "svc", Price: 100, StartDate: client.NewDate(2025, time.January, 1)})
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, created.ID)
+
+	var names []string
+	for sub, err := range c.Subscriptions(ctx, client.ListOptions{Limit: 2, UserID: userID, Tags: []string{"video", "hd"}}) {
+		require.NoError(t, err)
+		names = append(names, sub.ServiceName)
+	}
+	assert.Equal(t, []string{"svc0", "svc1", "svc2", "svc3", "svc4"}, names)
+
+	updated, err := c.UpdateSubscription(ctx, subs[0])
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+
+	_, err = c.UpdateSubscription(ctx, *updated)
+	assert.ErrorIs(t, err, client.ErrPreconditionFailed)
+	assert.True(t, client.HasCode(err, client.CodePreconditionFailed))
+
+	total, err := c.SumSubscriptionsCost(ctx, client.SumOptions{StartDate: client.NewDate(2025, time.January, 1)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(500), total)
+
+	categories, err := c.SumSubscriptionsCostByCategory(ctx, client.SumOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"video": 300}, categories)
+}
+
+func TestClientRetries(t *testing.T) {
+	var attempts atomic.Int32
+	var keys []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, http.StatusCreated, client.Subscription{ID: uuid.New()})
+	})
+	mux.HandleFunc("POST /subscriptions/{id}/pause", func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("GET /subscriptions/sum", func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.Header().Set("Retry-After", "0")
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"status":429,"code":"rate_limited","detail":"rate limit exceeded"}`))
+	})
+
+	c := newClient(t, mux)
+	ctx := context.Background()
+
+	// Создание повторяется с тем же Idempotency-Key
+	_, err := c.CreateSubscription(ctx, client.Subscription{ServiceName: "svc"})
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	assert.Equal(t, keys[0], keys[2])
+
+	// Пауза не идемпотентна и не повторяется
+	attempts.Store(0)
+	_, err = c.PauseSubscription(ctx, uuid.New(), nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), attempts.Load())
+
+	// После исчерпания попыток возвращается последняя ошибка сервера
+	attempts.Store(0)
+	_, err = c.SumSubscriptionsCost(ctx, client.SumOptions{})
+	assert.ErrorIs(t, err, client.ErrRateLimited)
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestClientErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /subscriptions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"type":"/problems/not_found","title":"Not Found","status":404,"detail":"subscription not found","code":"not_found","request_id":"req-1"}`))
+	})
+	mux.HandleFunc("POST /services", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status":400,"code":"validation_failed","errors":[{"field":"name","code":"required","message":"name is required"}]}`))
+	})
+	mux.HandleFunc("GET /tags", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	})
+
+	c := newClient(t, mux, client.WithAPIKey("sak_test"))
+	ctx := context.Background()
+
+	_, err := c.GetSubscription(ctx, uuid.New())
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	var apiErr *client.Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "req-1", apiErr.RequestID)
+	assert.Equal(t, "subscription not found", apiErr.Detail)
+
+	_, err = c.CreateService(ctx, client.Service{})
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, client.CodeValidationFailed, apiErr.Code)
+	assert.Equal(t, "name", apiErr.Errors[0].Field)
+
+	// Ответ не в формате RFC 7807, например от прокси
+	_, err = c.ListTags(ctx)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	assert.Equal(t, "bad gateway", apiErr.Detail)
+}
+
+func TestClientForwardsRequestID(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	var requestID, gotTraceparent string
+	c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = r.Header.Get(logging.RequestIDHeader)
+		gotTraceparent = r.Header.Get(logging.TraceparentHeader)
+		writeJSON(w, http.StatusOK, []string{})
+	}))
+
+	ctx := logging.ContextWithRequestID(context.Background(), "req-42")
+	ctx = logging.ContextWithTraceparent(ctx, traceparent)
+	_, err := c.ListTags(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "req-42", requestID)
+	assert.Equal(t, traceparent, gotTraceparent)
+
+	// Без request ID в контексте заголовки не передаются
+	_, err = c.ListTags(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, requestID)
+	assert.Empty(t, gotTraceparent)
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Коды ошибок сервера из поля code ответа
+const (
+	CodeInvalidBody           = "invalid_body"
+	CodeInvalidParameter      = "invalid_parameter"
+	CodeValidationFailed      = "validation_failed"
+	CodeNotFound              = "not_found"
+	CodeConflict              = "conflict"
+	CodeServiceAliasTaken     = "service_alias_taken"
+	CodeAlreadyPaused         = "subscription_already_paused"
+	CodeNotPaused             = "subscription_not_paused"
+	CodeInvalidPauseDate      = "invalid_pause_date"
+	CodeInvalidSplit          = "invalid_split"
+	CodeInvalidPhases         = "invalid_phases"
+	CodeUnauthorized          = "unauthorized"
+	CodeForbidden             = "forbidden"
+	CodeRateLimited           = "rate_limited"
+	CodeIdempotencyKeyReused  = "idempotency_key_reused"
+	CodeIdempotencyInProgress = "idempotency_request_in_progress"
+	CodePreconditionFailed    = "precondition_failed"
+	CodePreconditionRequired  = "precondition_required"
+	CodeInternal              = "internal_error"
+)
+
+// Ошибки для проверки через errors.Is, соответствуют статусам ответа
+var (
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrForbidden          = errors.New("forbidden")
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrRateLimited        = errors.New("rate limited")
+)
+
+var statusErrors = map[int]error{
+	http.StatusUnauthorized:       ErrUnauthorized,
+	http.StatusForbidden:          ErrForbidden,
+	http.StatusNotFound:           ErrNotFound,
+	http.StatusConflict:           ErrConflict,
+	http.StatusPreconditionFailed: ErrPreconditionFailed,
+	http.StatusTooManyRequests:    ErrRateLimited,
+}
+
+// FieldError — ошибка валидации отдельного поля запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error — ошибка API в формате RFC 7807, как ее возвращает сервер
+type Error struct {
+	StatusCode int          `json:"status"`
+	Type       string       `json:"type"`
+	Title      string       `json:"title"`
+	Detail     string       `json:"detail,omitempty"`
+	Instance   string       `json:"instance,omitempty"`
+	Code       string       `json:"code"`
+	RequestID  string       `json:"request_id,omitempty"`
+	Errors     []FieldError `json:"errors,omitempty"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("subscription api: %d", e.StatusCode)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	for _, f := range e.Errors {
+		msg += fmt.Sprintf("; %s: %s", f.Field, f.Message)
+	}
+	return msg
+}
+
+// Is позволяет сравнивать ошибку с ErrNotFound и другими ошибками статусов
+func (e *Error) Is(target error) bool {
+	return statusErrors[e.StatusCode] == target
+}
+
+// HasCode проверяет, что err — ошибка API с кодом code
+func HasCode(err error, code string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// maxErrorBody — сколько байт ответа читается, если он не в формате RFC 7807
+const maxErrorBody = 4096
+
+// decodeError читает ошибку из ответа. Ответ не в формате RFC 7807,
+// например от прокси, сохраняется в Detail как текст.
+func decodeError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	apiErr := &Error{}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "json") || json.Unmarshal(body, apiErr) != nil {
+		apiErr = &Error{Title: http.StatusText(resp.StatusCode), Detail: strings.TrimSpace(string(body))}
+	}
+	apiErr.StatusCode = resp.StatusCode
+	return apiErr
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// dateLayout — формат дат API
+const dateLayout = "2006-01-02"
+
+// Date — календарная дата без времени, в JSON передается как "2006-01-02".
+// Нулевая дата в фильтрах означает, что фильтр не задан.
+type Date struct {
+	time.Time
+}
+
+// NewDate создает дату из года, месяца и дня
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// String возвращает дату в формате API
+func (d Date) String() string {
+	return d.Format(dateLayout)
+}
+
+// dateFormats — форматы, которые принимает ParseDate, как на сервере
+var dateFormats = []string{"01-2006", "2006-01", dateLayout, time.RFC3339}
+
+// parseDate разбирает дату и сообщает, был ли указан только месяц
+func parseDate(s string) (Date, bool, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateFormats {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return NewDate(t.Year(), t.Month(), t.Day()), layout == "01-2006" || layout == "2006-01", nil
+		}
+	}
+	return Date{}, false, fmt.Errorf("invalid date %q, expected MM-YYYY, YYYY-MM, YYYY-MM-DD or RFC 3339", s)
+}
+
+// ParseDate разбирает дату начала периода. Месяц без дня означает первый день месяца
+func ParseDate(s string) (Date, error) {
+	d, _, err := parseDate(s)
+	return d, err
+}
+
+// ParseEndDate разбирает дату окончания периода. Месяц без дня означает последний день месяца
+func ParseEndDate(s string) (Date, error) {
+	d, monthOnly, err := parseDate(s)
+	if err != nil || !monthOnly {
+		return d, err
+	}
+	return Date{d.AddDate(0, 1, -1)}, nil
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		return nil
+	}
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return err
+	}
+	d.Time = t
+	return nil
+}
+
+// Subscription — подписка пользователя на сервис
+type Subscription struct {
+	ID          uuid.UUID `json:"id,omitzero"`
+	ServiceName string    `json:"service_name"`
+	Price       int       `json:"price"`
+	UserID      uuid.UUID `json:"user_id,omitzero"`
+	StartDate   Date      `json:"start_date"`
+	EndDate     *Date     `json:"end_date,omitempty"`
+	// SplitMode — режим разделения стоимости между участниками: "", equal или custom
+	SplitMode string               `json:"split_mode,omitempty"`
+	Tags      []string             `json:"tags,omitempty"`
+	Members   []SubscriptionMember `json:"members,omitempty"`
+	Phases    []PricePhase         `json:"phases,omitempty"`
+	// Поля ниже заполняет сервер
+	Pauses       []Pause `json:"pauses,omitempty"`
+	TrialEndDate *Date   `json:"trial_end_date,omitempty"`
+	CreatedAt    Date    `json:"created_at,omitzero"`
+	UpdatedAt    Date    `json:"updated_at,omitzero"`
+	// Version — версия подписки. Если она больше нуля, изменение и удаление
+	// выполняются, только если подписка не изменилась с момента чтения.
+	Version int `json:"version,omitempty"`
+}
+
+// SubscriptionMember — участник совместной подписки и его доля (от 0 до 1)
+type SubscriptionMember struct {
+	UserID uuid.UUID `json:"user_id"`
+	Share  float64   `json:"share"`
+}
+
+// PricePhase — пробный или вводный период: длительность в месяцах и цена в месяц
+type PricePhase struct {
+	Months int `json:"months"`
+	Price  int `json:"price"`
+}
+
+// Pause — период приостановки подписки. EndDate пуст, пока подписка приостановлена
+type Pause struct {
+	ID             uuid.UUID `json:"id"`
+	SubscriptionID uuid.UUID `json:"subscription_id"`
+	StartDate      Date      `json:"start_date"`
+	EndDate        *Date     `json:"end_date,omitempty"`
+}
+
+// Service — запись каталога сервисов с каноническим названием и алиасами
+type Service struct {
+	ID           uuid.UUID `json:"id,omitzero"`
+	Name         string    `json:"name"`
+	Aliases      []string  `json:"aliases"`
+	Category     string    `json:"category"`
+	DefaultPrice int       `json:"default_price"`
+	Homepage     string    `json:"homepage"`
+	CreatedAt    time.Time `json:"created_at,omitzero"`
+	UpdatedAt    time.Time `json:"updated_at,omitzero"`
+}
+
+// Tag — тег (категория) подписки
+type Tag struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// APIKey — ключ доступа сервиса. Key заполняется только в ответе на выпуск и ротацию
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	TenantID   string     `json:"tenant_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	Key        string     `json:"key,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
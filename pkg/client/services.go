@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+func servicePath(id uuid.UUID) string {
+	return "/services/" + id.String()
+}
+
+// ListServices возвращает каталог сервисов, category ограничивает его одной категорией
+func (c *Client) ListServices(ctx context.Context, category string) ([]Service, error) {
+	q := url.Values{}
+	if category != "" {
+		q.Set("category", category)
+	}
+
+	var services []Service
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/services", query: q, retryable: true}, &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// GetService возвращает сервис каталога
+func (c *Client) GetService(ctx context.Context, id uuid.UUID) (*Service, error) {
+	var svc Service
+	if err := c.do(ctx, request{method: http.MethodGet, path: servicePath(id), retryable: true}, &svc); err != nil {
+		return nil, err
+	}
+	return &svc, nil
+}
+
+// CreateService добавляет сервис в каталог. Требует роль администратора
+func (c *Client) CreateService(ctx context.Context, svc Service) (*Service, error) {
+	var created Service
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/services", body: svc}, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateService заменяет сервис svc.ID. Требует роль администратора
+func (c *Client) UpdateService(ctx context.Context, svc Service) (*Service, error) {
+	var updated Service
+	if err := c.do(ctx, request{method: http.MethodPut, path: servicePath(svc.ID), body: svc, retryable: true}, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteService удаляет сервис из каталога. Требует роль администратора
+func (c *Client) DeleteService(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: servicePath(id), retryable: true}, nil)
+}
+
+// ListTags возвращает теги подписок организации
+func (c *Client) ListTags(ctx context.Context) ([]Tag, error) {
+	var tags []Tag
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/tags", retryable: true}, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
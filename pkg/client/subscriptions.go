@@ -0,0 +1,256 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// ListOptions — фильтры и страница списка подписок. Пустые поля не ограничивают список
+type ListOptions struct {
+	// Page — номер страницы с 1, Limit — размер страницы (по умолчанию на сервере 10)
+	Page  int
+	Limit int
+
+	UserID      uuid.UUID
+	ServiceName string
+	// Tags — подписка должна иметь все перечисленные теги
+	Tags []string
+	// StartDate и EndDate — подписки, активные в этом периоде
+	StartDate Date
+	EndDate   Date
+}
+
+func (o ListOptions) query() url.Values {
+	q := url.Values{}
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	setPeriod(q, o.UserID, o.ServiceName, o.StartDate, o.EndDate)
+	for _, tag := range o.Tags {
+		q.Add("tag", tag)
+	}
+	return q
+}
+
+// SumOptions — фильтры суммы стоимости. Без EndDate период заканчивается сегодня
+type SumOptions struct {
+	UserID      uuid.UUID
+	ServiceName string
+	StartDate   Date
+	EndDate     Date
+}
+
+func (o SumOptions) query() url.Values {
+	q := url.Values{}
+	setPeriod(q, o.UserID, o.ServiceName, o.StartDate, o.EndDate)
+	return q
+}
+
+func setPeriod(q url.Values, userID uuid.UUID, serviceName string, start, end Date) {
+	if userID != uuid.Nil {
+		q.Set("user_id", userID.String())
+	}
+	if serviceName != "" {
+		q.Set("service_name", serviceName)
+	}
+	if !start.IsZero() {
+		q.Set("start_date", start.String())
+	}
+	if !end.IsZero() {
+		q.Set("end_date", end.String())
+	}
+}
+
+// ifMatch возвращает заголовок If-Match для версии подписки или nil для версии 0
+func ifMatch(version int) http.Header {
+	if version <= 0 {
+		return nil
+	}
+	return http.Header{"If-Match": {`"` + strconv.Itoa(version) + `"`}}
+}
+
+func subscriptionPath(id uuid.UUID) string {
+	return "/subscriptions/" + id.String()
+}
+
+// CreateSubscription создает подписку. Запрос передается с новым Idempotency-Key,
+// поэтому повтор после сетевой ошибки не создаст вторую подписку.
+func (c *Client) CreateSubscription(ctx context.Context, sub Subscription) (*Subscription, error) {
+	return c.CreateSubscriptionWithKey(ctx, sub, uuid.NewString())
+}
+
+// CreateSubscriptionWithKey создает подписку с заданным Idempotency-Key,
+// чтобы повторы между перезапусками клиента тоже не создавали дублей
+func (c *Client) CreateSubscriptionWithKey(ctx context.Context, sub Subscription, idempotencyKey string) (*Subscription, error) {
+	var created Subscription
+	err := c.do(ctx, request{
+		method:    http.MethodPost,
+		path:      "/subscriptions",
+		body:      sub,
+		header:    http.Header{"Idempotency-Key": {idempotencyKey}},
+		retryable: true,
+	}, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// GetSubscription возвращает подписку с текущей версией
+func (c *Client) GetSubscription(ctx context.Context, id uuid.UUID) (*Subscription, error) {
+	var sub Subscription
+	if err := c.do(ctx, request{method: http.MethodGet, path: subscriptionPath(id), retryable: true}, &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// ListSubscriptions возвращает одну страницу подписок
+func (c *Client) ListSubscriptions(ctx context.Context, opts ListOptions) ([]Subscription, error) {
+	var subs []Subscription
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/subscriptions", query: opts.query(), retryable: true}, &subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// Subscriptions перебирает все подписки по страницам, начиная с opts.Page.
+// Перебор останавливается на неполной странице или на первой ошибке.
+func (c *Client) Subscriptions(ctx context.Context, opts ListOptions) iter.Seq2[Subscription, error] {
+	return func(yield func(Subscription, error) bool) {
+		if opts.Page < 1 {
+			opts.Page = 1
+		}
+		if opts.Limit < 1 {
+			opts.Limit = 100
+		}
+		for {
+			subs, err := c.ListSubscriptions(ctx, opts)
+			if err != nil {
+				yield(Subscription{}, err)
+				return
+			}
+			for _, sub := range subs {
+				if !yield(sub, nil) {
+					return
+				}
+			}
+			if len(subs) < opts.Limit {
+				return
+			}
+			opts.Page++
+		}
+	}
+}
+
+// UpdateSubscription заменяет подписку sub.ID. Если sub.Version больше нуля,
+// изменение выполняется, только если подписка не изменилась (иначе ErrPreconditionFailed).
+func (c *Client) UpdateSubscription(ctx context.Context, sub Subscription) (*Subscription, error) {
+	var updated Subscription
+	err := c.do(ctx, request{
+		method:    http.MethodPut,
+		path:      subscriptionPath(sub.ID),
+		body:      sub,
+		header:    ifMatch(sub.Version),
+		retryable: true,
+	}, &updated)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// PatchSubscription изменяет только переданные поля (JSON Merge Patch), null удаляет поле.
+// version работает как в UpdateSubscription, 0 отключает проверку.
+func (c *Client) PatchSubscription(ctx context.Context, id uuid.UUID, patch map[string]any, version int) (*Subscription, error) {
+	var updated Subscription
+	err := c.do(ctx, request{
+		method: http.MethodPatch,
+		path:   subscriptionPath(id),
+		body:   patch,
+		header: ifMatch(version),
+	}, &updated)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteSubscription удаляет подписку. version работает как в UpdateSubscription
+func (c *Client) DeleteSubscription(ctx context.Context, id uuid.UUID, version int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: subscriptionPath(id), header: ifMatch(version), retryable: true}, nil)
+}
+
+// pauseRequest — тело запроса паузы и возобновления
+type pauseRequest struct {
+	Date *Date `json:"date,omitempty"`
+}
+
+// PauseSubscription приостанавливает подписку с даты date (nil — сегодня)
+func (c *Client) PauseSubscription(ctx context.Context, id uuid.UUID, date *Date) (*Pause, error) {
+	var pause Pause
+	if err := c.do(ctx, request{method: http.MethodPost, path: subscriptionPath(id) + "/pause", body: pauseRequest{date}}, &pause); err != nil {
+		return nil, err
+	}
+	return &pause, nil
+}
+
+// ResumeSubscription возобновляет подписку с даты date (nil — сегодня)
+func (c *Client) ResumeSubscription(ctx context.Context, id uuid.UUID, date *Date) (*Pause, error) {
+	var pause Pause
+	if err := c.do(ctx, request{method: http.MethodPost, path: subscriptionPath(id) + "/resume", body: pauseRequest{date}}, &pause); err != nil {
+		return nil, err
+	}
+	return &pause, nil
+}
+
+// ListTrialsEndingSoon возвращает подписки, у которых пробный период заканчивается
+// в ближайшие days дней (0 — по умолчанию сервера, 7)
+func (c *Client) ListTrialsEndingSoon(ctx context.Context, days int, userID uuid.UUID) ([]Subscription, error) {
+	q := url.Values{}
+	if days > 0 {
+		q.Set("days", strconv.Itoa(days))
+	}
+	if userID != uuid.Nil {
+		q.Set("user_id", userID.String())
+	}
+
+	var subs []Subscription
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/subscriptions/trials", query: q, retryable: true}, &subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// SumSubscriptionsCost возвращает суммарную стоимость подписок за период
+func (c *Client) SumSubscriptionsCost(ctx context.Context, opts SumOptions) (int64, error) {
+	var resp struct {
+		TotalPrice int64 `json:"total_price"`
+	}
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/subscriptions/sum", query: opts.query(), retryable: true}, &resp); err != nil {
+		return 0, err
+	}
+	return resp.TotalPrice, nil
+}
+
+// SumSubscriptionsCostByCategory возвращает стоимость подписок за период по категориям
+func (c *Client) SumSubscriptionsCostByCategory(ctx context.Context, opts SumOptions) (map[string]int64, error) {
+	q := opts.query()
+	q.Set("group_by", "category")
+
+	var resp struct {
+		Categories map[string]int64 `json:"categories"`
+	}
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/subscriptions/sum", query: q, retryable: true}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Categories, nil
+}
@@ -1,21 +1,67 @@
 package logging
 
 import (
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
-	"sync"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 var (
-	//глобальный синглтон логгера
-	logger *slog.Logger
+	//глобальный логгер, по умолчанию JSON в stderr до вызова Setup
+	logger atomic.Pointer[slog.Logger]
 	level  = new(slog.LevelVar)
-	//синхронизация инициализации логгера
-	once sync.Once
 )
 
+func init() {
+	logger.Store(slog.New(newHandler(os.Stderr, Options{
+		Format:            FormatJSON,
+		RedactFields:      DefaultRedactFields,
+		RedactQueryParams: DefaultRedactQueryParams,
+	})))
+}
+
+// Форматы логов
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Куда пишутся логи
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+	OutputFile   = "file"
+)
+
+// Options — настройки вывода логов
+type Options struct {
+	// Output — stdout, stderr или file
+	Output string
+	// Format — json или text
+	Format string
+	// Level — минимальный уровень: debug, info, warn или error
+	Level string
+
+	// File — путь к файлу логов для Output file
+	File string
+	// MaxSizeMB и MaxAge — при превышении размера или возраста файл переименовывается
+	// и создается новый. Нулевые значения отключают соответствующую ротацию.
+	MaxSizeMB int
+	MaxAge    time.Duration
+	// MaxBackups — сколько старых файлов хранить, 0 хранит все
+	MaxBackups int
+
+	// RedactFields и RedactQueryParams — атрибуты и параметры URL, значения которых скрываются
+	RedactFields      []string
+	RedactQueryParams []string
+}
+
 func parseLevel(levelStr string) slog.Level {
-	switch levelStr {
+	switch strings.ToUpper(levelStr) {
 	case "DEBUG":
 		return slog.LevelDebug
 	case "INFO":
@@ -29,22 +75,64 @@ func parseLevel(levelStr string) slog.Level {
 	}
 }
 
-// Логгер создается один раз с JSON обработчиком,
-// записывающим логи в файл app.log и добавляющим исходник вызова (source).
-func GetLogger() *slog.Logger {
-	once.Do(func() {
-		f, err := os.OpenFile("app.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// newHandler создает обработчик с общим уровнем, информацией об источнике вызова (source),
+// request ID из контекста и скрытием чувствительных данных
+func newHandler(w io.Writer, opts Options) slog.Handler {
+	handlerOpts := &slog.HandlerOptions{AddSource: true, Level: level}
+	var handler slog.Handler
+	if opts.Format == FormatText {
+		handler = slog.NewTextHandler(w, handlerOpts)
+	} else {
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	}
+	return NewRedactHandler(NewContextHandler(handler), opts.RedactFields, opts.RedactQueryParams)
+}
+
+// Setup настраивает вывод логов по opts и делает логгер стандартным для slog и log.
+// Возвращаемый Closer закрывает файл логов.
+func Setup(opts Options) (io.Closer, error) {
+	if opts.Format != FormatJSON && opts.Format != FormatText {
+		return nil, fmt.Errorf("unknown log format %q, expected json or text", opts.Format)
+	}
+
+	var w io.WriteCloser
+	switch opts.Output {
+	case OutputStdout:
+		w = nopCloser{os.Stdout}
+	case OutputStderr:
+		w = nopCloser{os.Stderr}
+	case OutputFile:
+		f, err := openRotatingFile(opts.File, int64(opts.MaxSizeMB)<<20, opts.MaxAge, opts.MaxBackups)
 		if err != nil {
-			panic("cannot open log file: " + err.Error())
+			return nil, err
 		}
-		// Инициализация JSON хендлера с добавлением информации об источнике вызова
-		handler := slog.NewJSONHandler(f, &slog.HandlerOptions{AddSource: true})
+		w = f
+	default:
+		return nil, fmt.Errorf("unknown log output %q, expected stdout, stderr or file", opts.Output)
+	}
+
+	SetLevel(opts.Level)
+	SetLogger(slog.New(newHandler(w, opts)))
+	slog.SetDefault(GetLogger())
+	return w, nil
+}
 
-		logger = slog.New(handler)
-	})
-	return logger
+// SetLogger заменяет глобальный логгер, например на логгер в буфер в тестах
+func SetLogger(l *slog.Logger) {
+	logger.Store(l)
 }
+
+// GetLogger возвращает глобальный логгер
+func GetLogger() *slog.Logger {
+	return logger.Load()
+}
+
+// SetLevel меняет минимальный уровень логов на лету
 func SetLevel(levelStr string) {
 	lvl := parseLevel(levelStr)
 	level.Set(lvl)
 }
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,202 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"subscribe_aggregation-main/pkg/logging"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// restoreLogger возвращает логгеры, замененные Setup, после теста
+func restoreLogger(t *testing.T) {
+	previous, previousDefault := logging.GetLogger(), slog.Default()
+	t.Cleanup(func() {
+		logging.SetLogger(previous)
+		slog.SetDefault(previousDefault)
+	})
+}
+
+func TestSetupFileRotation(t *testing.T) {
+	restoreLogger(t)
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	closer, err := logging.Setup(logging.Options{
+		Output:     logging.OutputFile,
+		Format:     logging.FormatJSON,
+		Level:      "warn",
+		File:       path,
+		MaxSizeMB:  1,
+		MaxBackups: 2,
+	})
+	require.NoError(t, err)
+	defer closer.Close()
+
+	logger := logging.GetLogger()
+	logger.Info("skipped below warn")
+	padding := strings.Repeat("x", 16<<10)
+	for range 200 {
+		logger.Warn("padding", slog.String("data", padding))
+	}
+	require.NoError(t, closer.Close())
+
+	backups, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	assert.Len(t, backups, 2, "older backups should be removed")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(data), 1<<20)
+	assert.NotContains(t, string(data), "skipped below warn")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(bytes.SplitN(data, []byte("\n"), 2)[0], &entry))
+	assert.Equal(t, "WARN", entry["level"])
+}
+
+func TestSetupFileRotationAge(t *testing.T) {
+	restoreLogger(t)
+	path := filepath.Join(t.TempDir(), "app.log")
+	// Текущий файл создан при ротации два часа назад, хотя менялся только что
+	rotated := time.Now().Add(-2 * time.Hour).Format("20060102-150405.000")
+	require.NoError(t, os.WriteFile(path+"."+rotated, []byte("old\n"), 0o644))
+	require.NoError(t, os.WriteFile(path, []byte("current\n"), 0o644))
+
+	closer, err := logging.Setup(logging.Options{
+		Output:     logging.OutputFile,
+		Format:     logging.FormatText,
+		Level:      "info",
+		File:       path,
+		MaxAge:     time.Hour,
+		MaxBackups: 5,
+	})
+	require.NoError(t, err)
+	defer closer.Close()
+
+	logging.GetLogger().Info("after restart")
+	require.NoError(t, closer.Close())
+
+	backups, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	assert.Len(t, backups, 2, "file older than max age should be rotated on first write")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "current")
+	assert.Contains(t, string(data), "after restart")
+}
+
+func TestSetupValidation(t *testing.T) {
+	restoreLogger(t)
+	_, err := logging.Setup(logging.Options{Output: "syslog", Format: logging.FormatJSON})
+	assert.ErrorContains(t, err, "unknown log output")
+
+	_, err = logging.Setup(logging.Options{Output: logging.OutputStdout, Format: "xml"})
+	assert.ErrorContains(t, err, "unknown log format")
+
+	_, err = logging.Setup(logging.Options{Output: logging.OutputFile, Format: logging.FormatText})
+	assert.ErrorContains(t, err, "path is required")
+}
+
+func TestSetLogger(t *testing.T) {
+	restoreLogger(t)
+
+	var buf bytes.Buffer
+	logging.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	logging.GetLogger().Info("captured")
+	assert.Contains(t, buf.String(), "msg=captured")
+}
+
+type account struct{ email string }
+
+func (a account) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("email", a.email), slog.String("plan", "pro"))
+}
+
+func TestRedactHandler(t *testing.T) {
+	var buf bytes.Buffer
+	handler := logging.NewRedactHandler(slog.NewJSONHandler(&buf, nil),
+		logging.DefaultRedactFields, logging.DefaultRedactQueryParams)
+	logger := slog.New(handler).With(slog.String("Authorization", "Bearer abc"))
+
+	logger.Info("request",
+		slog.String("user_id", "6f1c"),
+		slog.String("url", "/subscriptions?service_name=Netflix&user_id=6f1c&page=2"),
+		slog.Group("filter", slog.String("token", "secret"), slog.Int("limit", 10)),
+		slog.Any("account", account{email: "a@example.com"}),
+		slog.String("query", "SELECT * FROM subscriptions WHERE user_id = ?"),
+	)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "[REDACTED]", entry["Authorization"])
+	assert.Equal(t, "[REDACTED]", entry["user_id"])
+	assert.Equal(t, "/subscriptions?service_name=Netflix&user_id=[REDACTED]&page=2", entry["url"])
+	assert.Equal(t, map[string]any{"token": "[REDACTED]", "limit": float64(10)}, entry["filter"])
+	assert.Equal(t, map[string]any{"email": "[REDACTED]", "plan": "pro"}, entry["account"])
+	assert.Equal(t, "SELECT * FROM subscriptions WHERE user_id = ?", entry["query"])
+	assert.NotContains(t, buf.String(), "6f1c")
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+	restoreLogger(t)
+	var buf bytes.Buffer
+	logging.SetLogger(slog.New(logging.NewContextHandler(slog.NewJSONHandler(&buf, nil))))
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	var outgoing http.Header
+	handler := logging.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.GetLogger().InfoContext(r.Context(), "inside handler")
+		outgoing = http.Header{}
+		logging.SetRequestHeaders(r.Context(), outgoing)
+	}))
+
+	tests := []struct {
+		name        string
+		requestID   string
+		traceparent string
+		want        string
+	}{
+		{"client request ID", "client-42", traceparent, "client-42"},
+		{"traceparent", "", traceparent, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"invalid request ID", "bad id\n", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+			req.Header.Set(logging.RequestIDHeader, tt.requestID)
+			req.Header.Set(logging.TraceparentHeader, tt.traceparent)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(logging.RequestIDHeader)
+			if tt.want != "" {
+				assert.Equal(t, tt.want, got)
+			} else {
+				_, err := uuid.Parse(got)
+				assert.NoError(t, err, "expected a generated UUID, got %q", got)
+			}
+			assert.Equal(t, got, outgoing.Get(logging.RequestIDHeader))
+			assert.Equal(t, tt.traceparent, outgoing.Get(logging.TraceparentHeader))
+
+			// Каждая запись, включая запись обработчика, содержит request ID
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			require.Len(t, lines, 3)
+			for _, line := range lines {
+				var entry map[string]any
+				require.NoError(t, json.Unmarshal([]byte(line), &entry))
+				assert.Equal(t, got, entry["request_id"])
+			}
+		})
+	}
+}
@@ -5,8 +5,6 @@ import (
 	"log/slog"
 	"net/http"
 	"time"
-
-	"github.com/google/uuid"
 )
 
 // ctxKeyRequestID — тип для ключа request ID в контексте запроса,
@@ -23,20 +21,28 @@ func RequestIDFromContext(ctx context.Context) string {
 	return id
 }
 
-// Middleware с логированием request ID, HTTP статуса и продолжительности
+// ContextWithRequestID возвращает контекст с request ID для логов.
+// Используется обработчиками, которые не проходят через Middleware, например gRPC.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKeyRequestID{}, id)
+}
+
+// Middleware с логированием request ID, HTTP статуса и продолжительности.
+// Request ID берется из X-Request-ID или traceparent запроса и возвращается в X-Request-ID ответа.
 func Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		reqID := uuid.New().String()
+		reqID := IncomingRequestID(r.Header.Get(RequestIDHeader), r.Header.Get(TraceparentHeader))
+		w.Header().Set(RequestIDHeader, reqID)
 
-		// Добавляем request ID в контекст
-		ctx := context.WithValue(r.Context(), ctxKeyRequestID{}, reqID)
+		// Добавляем request ID в контекст, ContextHandler добавит его в каждую запись лога
+		ctx := ContextWithRequestID(r.Context(), reqID)
+		ctx = ContextWithTraceparent(ctx, r.Header.Get(TraceparentHeader))
 		r = r.WithContext(ctx)
 
 		logger := GetLogger()
 		start := time.Now()
 
-		logger.Info("Request started",
-			slog.String("request_id", reqID),
+		logger.InfoContext(ctx, "Request started",
 			slog.String("method", r.Method),
 			slog.String("url", r.URL.String()),
 			slog.String("remote_addr", r.RemoteAddr),
@@ -49,8 +55,7 @@ func Middleware(next http.Handler) http.Handler {
 		// Вычисляем длительность обработки запроса
 		duration := time.Since(start).Milliseconds()
 
-		logger.Info("Request completed",
-			slog.String("request_id", reqID),
+		logger.InfoContext(ctx, "Request completed",
 			slog.String("method", r.Method),
 			slog.String("url", r.URL.String()),
 			slog.String("remote_addr", r.RemoteAddr),
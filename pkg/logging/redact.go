@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// redacted заменяет значения скрытых полей и параметров запроса
+const redacted = "[REDACTED]"
+
+// DefaultRedactFields — атрибуты логов, значения которых скрываются по умолчанию
+var DefaultRedactFields = []string{
+	"user_id", "email", "token", "access_token", "refresh_token",
+	"authorization", "password", "secret", "api_key",
+}
+
+// DefaultRedactQueryParams — параметры URL, значения которых скрываются по умолчанию
+var DefaultRedactQueryParams = []string{"user_id", "email", "token", "access_token", "api_key"}
+
+// RedactHandler скрывает значения атрибутов с заданными именами, в том числе во вложенных
+// группах и LogValuer, и значения заданных параметров запроса в URL из строковых атрибутов
+type RedactHandler struct {
+	next        slog.Handler
+	fields      []string
+	queryParams []string
+}
+
+// NewRedactHandler оборачивает next. Имена полей и параметров сравниваются без учета регистра
+func NewRedactHandler(next slog.Handler, fields, queryParams []string) *RedactHandler {
+	lower := func(names []string) []string {
+		out := make([]string, len(names))
+		for i, name := range names {
+			out[i] = strings.ToLower(strings.TrimSpace(name))
+		}
+		return out
+	}
+	return &RedactHandler{next: next, fields: lower(fields), queryParams: lower(queryParams)}
+}
+
+func (h *RedactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RedactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redactedAttrs[i] = h.redact(a)
+	}
+	return &RedactHandler{next: h.next.WithAttrs(redactedAttrs), fields: h.fields, queryParams: h.queryParams}
+}
+
+func (h *RedactHandler) WithGroup(name string) slog.Handler {
+	return &RedactHandler{next: h.next.WithGroup(name), fields: h.fields, queryParams: h.queryParams}
+}
+
+// redact возвращает атрибут со скрытыми значениями
+func (h *RedactHandler) redact(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if slices.Contains(h.fields, strings.ToLower(a.Key)) {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		group := a.Value.Group()
+		attrs := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			attrs[i] = h.redact(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(attrs...)}
+	case slog.KindString:
+		return slog.String(a.Key, h.redactURL(a.Value.String()))
+	}
+	return a
+}
+
+// redactURL скрывает значения параметров запроса в строке, если это URL или путь с запросом
+func (h *RedactHandler) redactURL(s string) string {
+	if len(h.queryParams) == 0 || !strings.Contains(s, "?") {
+		return s
+	}
+	u, err := url.ParseRequestURI(s)
+	if err != nil || u.RawQuery == "" {
+		return s
+	}
+	// Запрос разбирается вручную, чтобы сохранить порядок и кодирование остальных параметров
+	pairs := strings.Split(u.RawQuery, "&")
+	changed := false
+	for i, pair := range pairs {
+		rawName, _, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			name = rawName
+		}
+		if slices.Contains(h.queryParams, strings.ToLower(name)) {
+			pairs[i] = rawName + "=" + redacted
+			changed = true
+		}
+	}
+	if !changed {
+		return s
+	}
+	u.RawQuery = strings.Join(pairs, "&")
+	return u.String()
+}
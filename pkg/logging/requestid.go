@@ -0,0 +1,127 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Заголовки, по которым request ID передается между сервисами
+const (
+	RequestIDHeader   = "X-Request-ID"
+	TraceparentHeader = "traceparent"
+)
+
+// maxRequestIDLength ограничивает длину request ID из запроса
+const maxRequestIDLength = 128
+
+// ctxKeyTraceparent — ключ входящего W3C traceparent в контексте запроса
+type ctxKeyTraceparent struct{}
+
+// traceparentPattern — W3C traceparent: версия, trace-id, parent-id и флаги в hex
+var traceparentPattern = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+// traceID возвращает trace-id из корректного traceparent или пустую строку
+func traceID(traceparent string) string {
+	m := traceparentPattern.FindStringSubmatch(strings.TrimSpace(traceparent))
+	if m == nil || m[1] == "ff" || m[2] == strings.Repeat("0", 32) || m[3] == strings.Repeat("0", 16) {
+		return ""
+	}
+	return m[2]
+}
+
+// validRequestID допускает только печатные ASCII символы без пробелов, чтобы
+// request ID клиента нельзя было использовать для подделки строк логов
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range []byte(id) {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// IncomingRequestID выбирает request ID запроса: X-Request-ID клиента, иначе trace-id
+// из W3C traceparent, иначе новый UUID
+func IncomingRequestID(requestID, traceparent string) string {
+	if requestID = strings.TrimSpace(requestID); validRequestID(requestID) {
+		return requestID
+	}
+	if id := traceID(traceparent); id != "" {
+		return id
+	}
+	return uuid.New().String()
+}
+
+// ContextWithTraceparent сохраняет корректный входящий traceparent для логов и исходящих запросов
+func ContextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	if traceID(traceparent) == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, ctxKeyTraceparent{}, strings.TrimSpace(traceparent))
+}
+
+// TraceparentFromContext возвращает входящий traceparent или пустую строку
+func TraceparentFromContext(ctx context.Context) string {
+	traceparent, _ := ctx.Value(ctxKeyTraceparent{}).(string)
+	return traceparent
+}
+
+// SetRequestHeaders передает request ID и traceparent из контекста в исходящий запрос,
+// чтобы вызов можно было найти в логах обоих сервисов
+func SetRequestHeaders(ctx context.Context, h http.Header) {
+	if id := RequestIDFromContext(ctx); id != "" {
+		h.Set(RequestIDHeader, id)
+	}
+	if traceparent := TraceparentFromContext(ctx); traceparent != "" {
+		h.Set(TraceparentHeader, traceparent)
+	}
+}
+
+// ContextHandler добавляет к каждой записи request_id и trace_id из контекста,
+// если они есть в контексте и не заданы в записи явно
+type ContextHandler struct {
+	next slog.Handler
+}
+
+// NewContextHandler оборачивает next
+func NewContextHandler(next slog.Handler) *ContextHandler {
+	return &ContextHandler{next: next}
+}
+
+func (h *ContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx == nil {
+		return h.next.Handle(ctx, r)
+	}
+	hasRequestID := false
+	r.Attrs(func(a slog.Attr) bool {
+		hasRequestID = a.Key == "request_id"
+		return !hasRequestID
+	})
+	if id := RequestIDFromContext(ctx); id != "" && !hasRequestID {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if id := traceID(TraceparentFromContext(ctx)); id != "" {
+		r.AddAttrs(slog.String("trace_id", id))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,152 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupLayout — суффикс старого файла логов: app.log.20251018-210500.000
+const backupLayout = "20060102-150405.000"
+
+// rotateRetry — через сколько повторить неудавшуюся ротацию. До этого запись идет в текущий файл.
+const rotateRetry = time.Minute
+
+// rotatingFile пишет в файл и переименовывает его в резервную копию, когда файл
+// превышает maxSize байт или становится старше maxAge
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	file       *os.File
+	size       int64
+	created    time.Time
+	nextRotate time.Time // не ротировать раньше, если прошлая попытка не удалась
+	now        func() time.Time
+}
+
+func openRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	if path == "" {
+		return nil, fmt.Errorf("log file path is required")
+	}
+	r := &rotatingFile{path: path, maxSize: maxSize, maxAge: maxAge, maxBackups: maxBackups, now: time.Now}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open открывает файл для дозаписи. Существующий файл создан при последней ротации,
+// время которой записано в суффиксе самой новой копии. Если копий нет, возраст
+// файла считается от открытия: время создания файла в ФС узнать переносимо нельзя.
+func (r *rotatingFile) open() error {
+	if dir := filepath.Dir(r.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("create log directory: %w", err)
+		}
+	}
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("open log file: %w", err)
+	}
+	r.file, r.size, r.created = f, info.Size(), r.now()
+	if backups := r.backups(); info.Size() > 0 && len(backups) > 0 {
+		suffix := strings.TrimPrefix(backups[0], r.path+".")
+		if rotated, err := time.ParseInLocation(backupLayout, suffix, time.Local); err == nil {
+			r.created = rotated
+		}
+	}
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && !r.now().Before(r.nextRotate) &&
+		(r.maxSize > 0 && r.size+int64(len(p)) > r.maxSize || r.maxAge > 0 && r.now().Sub(r.created) >= r.maxAge) {
+		if err := r.rotate(); err != nil {
+			if r.file == nil {
+				return 0, err
+			}
+			// Логи важнее ограничения размера: пишем в прежний файл и повторим позже
+			fmt.Fprintf(os.Stderr, "log rotation failed, retry in %s: %v\n", rotateRetry, err)
+			r.nextRotate = r.now().Add(rotateRetry)
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate переименовывает текущий файл, открывает новый и удаляет лишние копии
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+	if err := os.Rename(r.path, r.path+"."+r.now().Format(backupLayout)); err != nil {
+		// Файл закрыт до переименования, поэтому открываем его снова, чтобы не терять логи
+		created := r.created
+		if openErr := r.open(); openErr != nil {
+			return errors.Join(fmt.Errorf("rotate log file: %w", err), openErr)
+		}
+		r.created = created
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.created = r.now()
+	r.removeOldBackups()
+	return nil
+}
+
+// removeOldBackups оставляет maxBackups самых новых копий
+func (r *rotatingFile) removeOldBackups() {
+	if r.maxBackups <= 0 {
+		return
+	}
+	backups := r.backups()
+	for _, name := range backups[min(r.maxBackups, len(backups)):] {
+		os.Remove(name)
+	}
+}
+
+// backups возвращает копии файла от самой новой к самой старой
+func (r *rotatingFile) backups() []string {
+	backups, err := filepath.Glob(r.path + ".*-*")
+	if err != nil {
+		return nil
+	}
+	// Суффикс — время ротации, поэтому лексикографический порядок совпадает с хронологическим
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}